@@ -239,7 +239,8 @@ func TestProtocolOverhead_FlagImpact(t *testing.T) {
 		var expectedOverhead int
 		if ft.flags&codec.BalancedFlagEncrypted != 0 {
 			// 加密会增加 AES-GCM 的 nonce(12字节) + tag(16字节) = 28字节
-			expectedOverhead = 21 + 28 // 基础开销 + 加密开销
+			// 以及序号TLV(3+8字节) + 扩展区结束标志(3字节) = 14字节
+			expectedOverhead = 21 + 28 + 14 // 基础开销 + 加密开销 + 防重放序号
 		} else {
 			expectedOverhead = 21 // Balanced协议固定开销
 		}
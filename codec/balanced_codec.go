@@ -25,6 +25,7 @@
 // - Flags: 标志位 (4bit)
 //   - Bit 0: BalancedFlagCompressed (0x1) - 压缩标志
 //   - Bit 1: BalancedFlagEncrypted (0x2) - 加密标志
+//   - Bit 2: BalancedFlagSigned (0x4) - 签名已校验，只出现在解码结果中，不在帧头中传输
 //   - Bit 3: BalancedFlagExtended (0x8) - 扩展区标志
 //
 // - Total Length: 整个消息的总长度 (24bit, 最大16MB)
@@ -36,7 +37,7 @@
 // 特性:
 // - 高性能: 固定头部结构，快速解析
 // - 可扩展: 支持TLV扩展字段
-// - 安全: 支持AES-GCM加密
+// - 安全: 支持AES-GCM加密，AEADEncryptor 把密文绑定到帧头并防止重放；支持 HMAC-SHA256/Ed25519 帧签名
 // - 压缩: 预留压缩标志位
 // - 类型优化: 32位类型ID，提升匹配性能
 package codec
@@ -47,8 +48,11 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"slices"
 	"sync"
+	"sync/atomic"
 
 	"github.com/BadKid90s/chilix-msg/serializer"
 )
@@ -98,6 +102,10 @@ const (
 	// 当设置时，Payload 数据已使用 AES-GCM 加密
 	BalancedFlagEncrypted = 0x2
 
+	// BalancedFlagSigned 签名已校验标志
+	// 只由 DecodeWithFlags 在签名校验通过后设置，编码时忽略，帧头中的该位在解码时被清除
+	BalancedFlagSigned = 0x4
+
 	// BalancedFlagExtended 扩展区标志
 	// 当设置时，消息包含 TLV 扩展字段
 	BalancedFlagExtended = 0x8
@@ -127,13 +135,42 @@ type TLV struct {
 	Value  []byte // 扩展数据内容
 }
 
+// 框架保留的TLV类型
+// 解码时 Length=0 的TLV被视为扩展区结束标志，因此TLV的值不能为空
+const (
+	// TLVTypeError 协议错误，Value 为 错误码(16bit, 大端序) + UTF-8错误信息
+	TLVTypeError uint8 = 0x01
+	// TLVTypeSequence 加密帧的序号，Value 为 序号(64bit, 大端序)，由 BalancedCodec 自动添加
+	TLVTypeSequence uint8 = 0x02
+	// TLVTypeKeyID 加密帧使用的密钥ID，Value 为 密钥ID(32bit, 大端序)，使用 KeyRingEncryptor 时自动添加
+	TLVTypeKeyID uint8 = 0x03
+	// TLVTypeSignature 帧签名，Value 为 密钥ID(32bit, 大端序) + 签名，设置 Signer 时自动添加，总是最后一个扩展字段
+	TLVTypeSignature uint8 = 0x04
+	// TLVTypeName 消息类型名称，Value 为 UTF-8 名称，由处理器在连接上首次发送某类型时添加，
+	// 接收方据此识别未注册的类型ID，用于通配路由
+	TLVTypeName uint8 = 0x05
+)
+
 // Encryptor 加密器接口
 type Encryptor interface {
 	Encrypt(data []byte) ([]byte, error)
 	Decrypt(data []byte) ([]byte, error)
 }
 
+// AEADEncryptor 支持附加数据的加密器
+// BalancedCodec 对实现该接口的加密器会为每个加密帧添加递增的序号，
+// 并把帧头（版本、标志、请求ID、类型ID）和扩展区作为附加数据，
+// 密文无法被移到其他帧，重复或过期的序号在解密时被拒绝
+type AEADEncryptor interface {
+	Encryptor
+	// Seal 加密数据并认证附加数据
+	Seal(data, additionalData []byte) ([]byte, error)
+	// Open 解密数据并校验附加数据
+	Open(data, additionalData []byte) ([]byte, error)
+}
+
 // AESEncryptor AES加密器实现
+// 防重放只在单个连接内有效，预共享密钥下一个连接上截获的帧可以在新连接上重放，需要跨连接防重放时使用 handshake 协商的密钥
 type AESEncryptor struct {
 	key []byte
 }
@@ -148,36 +185,34 @@ func NewAESEncryptor(key []byte) (*AESEncryptor, error) {
 
 // Encrypt 加密数据
 func (e *AESEncryptor) Encrypt(data []byte) ([]byte, error) {
-	block, err := aes.NewCipher(e.key)
-	if err != nil {
-		return nil, err
-	}
+	return e.Seal(data, nil)
+}
 
-	// 使用GCM模式
-	gcm, err := cipher.NewGCM(block)
+// Decrypt 解密数据
+func (e *AESEncryptor) Decrypt(data []byte) ([]byte, error) {
+	return e.Open(data, nil)
+}
+
+// Seal 加密数据并认证附加数据，格式: nonce + 密文
+// nonce 随机生成，同一密钥加密的消息数不宜超过 2^32 条
+func (e *AESEncryptor) Seal(data, additionalData []byte) ([]byte, error) {
+	gcm, err := e.gcm()
 	if err != nil {
 		return nil, err
 	}
 
 	// 生成随机nonce
-	nonce := make([]byte, gcm.NonceSize())
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(data)+gcm.Overhead())
 	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
 		return nil, err
 	}
 
-	// 加密数据
-	ciphertext := gcm.Seal(nonce, nonce, data, nil)
-	return ciphertext, nil
+	return gcm.Seal(nonce, nonce, data, additionalData), nil
 }
 
-// Decrypt 解密数据
-func (e *AESEncryptor) Decrypt(data []byte) ([]byte, error) {
-	block, err := aes.NewCipher(e.key)
-	if err != nil {
-		return nil, err
-	}
-
-	gcm, err := cipher.NewGCM(block)
+// Open 解密数据并校验附加数据
+func (e *AESEncryptor) Open(data, additionalData []byte) ([]byte, error) {
+	gcm, err := e.gcm()
 	if err != nil {
 		return nil, err
 	}
@@ -188,14 +223,23 @@ func (e *AESEncryptor) Decrypt(data []byte) ([]byte, error) {
 	}
 
 	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
 	}
 
 	return plaintext, nil
 }
 
+func (e *AESEncryptor) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(e.key)
+	if err != nil {
+		return nil, err
+	}
+	// 使用GCM模式
+	return cipher.NewGCM(block)
+}
+
 // BufferPool 零拷贝辅助
 type BufferPool struct {
 	pool sync.Pool
@@ -226,34 +270,80 @@ func (p *BufferPool) Put(buf []byte) {
 }
 
 // BalancedCodec 新协议编解码器
+// 使用 AEADEncryptor 时编解码器保存发送序号和防重放窗口，每个连接应使用独立的编解码器
 type BalancedCodec struct {
 	serializer serializer.Serializer
 	bufferPool *BufferPool
-	encryptor  Encryptor
+	encryptor  atomic.Pointer[encryptorRef]
+	signer     atomic.Pointer[signerRef]
+	sendSeq    atomic.Uint64
+	replay     *ReplayWindow
+}
+
+// encryptorRef 包装加密器以便原子替换
+type encryptorRef struct {
+	Encryptor
 }
 
 func NewBalancedCodec(serializer serializer.Serializer) *BalancedCodec {
 	return &BalancedCodec{
 		serializer: serializer,
 		bufferPool: NewBufferPool(1024),
-		encryptor:  nil,
+		replay:     NewReplayWindow(DefaultReplayWindowSize),
 	}
 }
 
 // NewBalancedCodecWithEncryption 创建带加密功能的编解码器
 func NewBalancedCodecWithEncryption(serializer serializer.Serializer, encryptor Encryptor) *BalancedCodec {
-	return &BalancedCodec{
-		serializer: serializer,
-		bufferPool: NewBufferPool(1024),
-		encryptor:  encryptor,
-	}
+	c := NewBalancedCodec(serializer)
+	c.SetEncryptor(encryptor)
+	return c
 }
 
-// SetEncryptor 设置加密器
+// SetReplayWindow 设置防重放窗口大小，数据报传输乱序严重时可以调大，需在开始解码前调用
+func (c *BalancedCodec) SetReplayWindow(size int) {
+	c.replay = NewReplayWindow(size)
+}
+
+// SetEncryptor 设置加密器，nil表示不加密，可以在编解码过程中调用
 func (c *BalancedCodec) SetEncryptor(encryptor Encryptor) {
-	c.encryptor = encryptor
+	if encryptor == nil {
+		c.encryptor.Store(nil)
+		return
+	}
+	c.encryptor.Store(&encryptorRef{encryptor})
+}
+
+// Encryptor 返回当前的加密器，未设置时返回nil
+func (c *BalancedCodec) Encryptor() Encryptor {
+	if ref := c.encryptor.Load(); ref != nil {
+		return ref.Encryptor
+	}
+	return nil
+}
+
+// SetSigner 设置签名器，nil表示不签名，可以在编解码过程中调用
+// 设置后发送的帧都带签名，收到的带签名帧都会校验；required 为 true 时拒绝未签名的帧，
+// 为 false 时接受未签名的帧，便于双方逐步启用签名
+func (c *BalancedCodec) SetSigner(signer Signer, required bool) {
+	if signer == nil {
+		c.signer.Store(nil)
+		return
+	}
+	c.signer.Store(&signerRef{Signer: signer, required: required})
+}
+
+// Signer 返回当前的签名器，未设置时返回nil
+func (c *BalancedCodec) Signer() Signer {
+	if ref := c.signer.Load(); ref != nil {
+		return ref.Signer
+	}
+	return nil
 }
 
+// RawPayload 已经序列化的负载，编码时不再经过序列化器，例如缓存的响应
+type RawPayload []byte
+
 // Encode 编码消息
 func (c *BalancedCodec) Encode(w io.Writer, typeID uint32, payload interface{}, requestID uint64) error {
 	return c.EncodeWithFlags(w, typeID, payload, requestID, BalancedFlagNone, nil)
@@ -261,61 +351,103 @@ func (c *BalancedCodec) Encode(w io.Writer, typeID uint32, payload interface{},
 
 // EncodeWithFlags 带标志位的编码
 func (c *BalancedCodec) EncodeWithFlags(w io.Writer, typeID uint32, payload interface{}, requestID uint64, flags uint8, extensions []TLV) error {
+	// 签名标志只用于解码结果，不写入帧头
+	flags &^= BalancedFlagSigned
+
 	// 步骤1: 序列化负载
-	data, err := c.serializer.Serialize(payload)
+	data, err := c.serialize(payload)
 	if err != nil {
 		return err
 	}
 
-	// 步骤2: 检查是否需要加密
-	if flags&BalancedFlagEncrypted != 0 {
-		if c.encryptor == nil {
+	// 步骤2: 加密帧添加密钥ID和序号
+	encryptor := c.Encryptor()
+	aead, sealed := encryptor.(AEADEncryptor)
+	ring, keyed := encryptor.(KeyRingEncryptor)
+	var keyID uint32
+	encrypted := flags&BalancedFlagEncrypted != 0
+	if encrypted {
+		if encryptor == nil {
 			return ErrEncryptionFailed
 		}
-		encryptedData, err := c.encryptor.Encrypt(data)
-		if err != nil {
-			return err
+		extensions = slices.Clip(extensions)
+		if keyed {
+			keyID = ring.CurrentKey()
+			extensions = append(extensions, TLV{
+				Type:   TLVTypeKeyID,
+				Length: 4,
+				Value:  binary.BigEndian.AppendUint32(nil, keyID),
+			})
+		}
+		if sealed {
+			seq := c.sendSeq.Add(1)
+			if seq == 0 {
+				// 序号耗尽，继续发送会被对端当作重放
+				return fmt.Errorf("%w: sequence number exhausted", ErrEncryptionFailed)
+			}
+			extensions = append(extensions, TLV{
+				Type:   TLVTypeSequence,
+				Length: 8,
+				Value:  binary.BigEndian.AppendUint64(nil, seq),
+			})
 		}
-		data = encryptedData
 	}
 
-	// 步骤3: 处理扩展区
+	// 步骤3: 处理扩展区，签名帧总是带扩展区
+	signer := c.Signer()
 	var extData []byte
-	extLen := 0
-	if len(extensions) > 0 {
+	if len(extensions) > 0 || signer != nil {
 		flags |= BalancedFlagExtended
 		for _, tlv := range extensions {
-			// TLV格式: Type(8bit) + Length(16bit) + Value(变长)
-			extData = append(extData, tlv.Type)
-			// 使用大端序写入长度
-			extData = append(extData, byte(tlv.Length>>8), byte(tlv.Length&0xFF))
-			extData = append(extData, tlv.Value...)
-			extLen += 3 + len(tlv.Value)
+			extData = appendTLV(extData, tlv)
 		}
 		// 结束标志
 		extData = append(extData, 0, 0, 0) // Type=0, Length=0
-		extLen += 3
 	}
 
-	// 确保 extLen 与 extData 长度一致
-	if extLen != len(extData) {
-		extLen = len(extData)
+	// 步骤4: 加密负载，帧头和扩展区作为附加数据
+	versionFlags := byte(BalancedVersion<<4) | flags
+	if encrypted {
+		switch {
+		case keyed:
+			data, err = ring.SealWithKey(keyID, data, additionalData(versionFlags, requestID, typeID, extData))
+		case sealed:
+			data, err = aead.Seal(data, additionalData(versionFlags, requestID, typeID, extData))
+		default:
+			data, err = encryptor.Encrypt(data)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// 步骤5: 对加密后的帧签名，签名扩展字段插入到结束标志之前
+	if signer != nil {
+		keyID := signer.SignKey()
+		signature, err := signer.Sign(keyID, signedData(versionFlags, requestID, typeID, extData, data))
+		if err != nil {
+			return err
+		}
+		end := len(extData) - 3
+		extData = append(appendTLV(extData[:end:end], signatureTLV(keyID, signature)), 0, 0, 0)
 	}
+	extLen := len(extData)
 
-	// 步骤4: 计算总长度
+	// 步骤6: 计算总长度
 	totalLength := BalancedHeaderSize + extLen + len(data)
 	if totalLength > MaxMessageSize {
 		return ErrMessageTooLarge
 	}
 
-	// 步骤5: 构建头部
-	header := make([]byte, BalancedHeaderSize)
+	// 步骤7: 构建头部
+	frame := make([]byte, BalancedHeaderSize, totalLength)
+	header := frame[:BalancedHeaderSize]
 
 	// 写入Magic Number
 	binary.BigEndian.PutUint32(header[0:4], MagicNumber)
 
 	// 写入版本和标志
-	header[4] = (BalancedVersion << 4) | flags
+	header[4] = versionFlags
 
 	// 写入总长度（24bit）
 	header[5] = byte(totalLength >> 16)
@@ -328,24 +460,25 @@ func (c *BalancedCodec) EncodeWithFlags(w io.Writer, typeID uint32, payload inte
 	// 写入类型ID
 	binary.BigEndian.PutUint32(header[16:20], typeID)
 
-	// 步骤6: 写入数据
-	if _, err := w.Write(header); err != nil {
-		return err
-	}
-	// 写入扩展区
-	if extLen > 0 {
-		if _, err := w.Write(extData); err != nil {
-			return err
-		}
-	}
-	// 写入负载
-	if _, err := w.Write(data); err != nil {
+	// 步骤8: 拼接扩展区和负载，一次写入整帧
+	// 单次写入保证数据报传输中一帧对应一个数据报，也避免并发写入时帧被交错
+	frame = append(frame, extData...)
+	frame = append(frame, data...)
+	if _, err := w.Write(frame); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// serialize 序列化负载，RawPayload 原样返回
+func (c *BalancedCodec) serialize(payload interface{}) ([]byte, error) {
+	if raw, ok := payload.(RawPayload); ok {
+		return raw, nil
+	}
+	return c.serializer.Serialize(payload)
+}
+
 // Decode 解码消息
 func (c *BalancedCodec) Decode(r io.Reader) (uint32, []byte, uint64, error) {
 	typeID, payload, requestID, _, _, err := c.DecodeWithFlags(r)
@@ -369,7 +502,7 @@ func (c *BalancedCodec) DecodeWithFlags(r io.Reader) (uint32, []byte, uint64, ui
 	// 步骤3: 解析版本和标志
 	versionFlags := header[4]
 	version := versionFlags >> 4
-	flags := versionFlags & 0x0F
+	flags := versionFlags & 0x0F &^ BalancedFlagSigned
 	if version != BalancedVersion {
 		return 0, nil, 0, 0, nil, ErrUnsupportedVersion
 	}
@@ -387,8 +520,10 @@ func (c *BalancedCodec) DecodeWithFlags(r io.Reader) (uint32, []byte, uint64, ui
 	typeID := binary.BigEndian.Uint32(header[16:20])
 
 	// 步骤7: 读取扩展区（如果有）
-	extLen := 0
+	// 保留扩展区原始字节，解密和验签时作为附加数据
+	var extData []byte
 	var extensions []TLV
+	signatureAt := -1 // 签名扩展字段在扩展区中的偏移
 	if flags&BalancedFlagExtended != 0 {
 		for {
 			tlvHeader := make([]byte, 3) // Type(8bit) + Length(16bit)
@@ -398,21 +533,30 @@ func (c *BalancedCodec) DecodeWithFlags(r io.Reader) (uint32, []byte, uint64, ui
 			tlvType := tlvHeader[0]
 			tlvLen := binary.BigEndian.Uint16(tlvHeader[1:3])
 			if tlvLen == 0 {
+				extData = append(extData, tlvHeader...)
 				break // 结束标志
 			}
+			if signatureAt >= 0 {
+				// 签名必须是最后一个扩展字段
+				return 0, nil, 0, 0, nil, ErrInvalidMessageFormat
+			}
+			if tlvType == TLVTypeSignature {
+				signatureAt = len(extData)
+			}
+			extData = append(extData, tlvHeader...)
 			value := make([]byte, tlvLen)
 			if _, err := io.ReadFull(r, value); err != nil {
 				return 0, nil, 0, 0, nil, err
 			}
-			extLen += 3 + int(tlvLen)
+			extData = append(extData, value...)
 			extensions = append(extensions, TLV{
 				Type:   tlvType,
 				Length: tlvLen,
 				Value:  value,
 			})
 		}
-		extLen += 3 // 结束标志
 	}
+	extLen := len(extData)
 
 	// 步骤8: 计算并读取负载
 	payloadLength := totalLength - BalancedHeaderSize - extLen
@@ -424,17 +568,145 @@ func (c *BalancedCodec) DecodeWithFlags(r io.Reader) (uint32, []byte, uint64, ui
 		return 0, nil, 0, 0, nil, err
 	}
 
-	// 步骤9: 检查是否需要解密
-	if flags&BalancedFlagEncrypted != 0 {
-		if c.encryptor == nil {
-			return 0, nil, 0, 0, nil, ErrDecryptionFailed
-		}
-		decryptedPayload, err := c.encryptor.Decrypt(payload)
+	// 步骤9: 校验签名，签名覆盖去掉签名扩展字段后的扩展区和加密后的负载
+	if signatureAt >= 0 {
+		extData = append(extData[:signatureAt:signatureAt], 0, 0, 0)
+	}
+	signed, err := c.verify(header, extData, payload, extensions)
+	if err != nil {
+		return 0, nil, 0, 0, nil, err
+	}
+
+	// 步骤10: 检查是否需要解密
+	encrypted := flags&BalancedFlagEncrypted != 0
+	if encrypted {
+		decryptedPayload, err := c.decrypt(payload, header, extData, extensions)
 		if err != nil {
 			return 0, nil, 0, 0, nil, err
 		}
 		payload = decryptedPayload
 	}
 
+	// 序号、密钥ID和签名由编解码器内部使用，不返回给调用方
+	if encrypted || signatureAt >= 0 {
+		extensions = slices.DeleteFunc(extensions, func(tlv TLV) bool {
+			return tlv.Type == TLVTypeSignature || encrypted && (tlv.Type == TLVTypeSequence || tlv.Type == TLVTypeKeyID)
+		})
+		if len(extensions) == 0 {
+			extensions = nil
+			flags &^= BalancedFlagExtended
+		}
+	}
+
+	if signed {
+		flags |= BalancedFlagSigned
+	}
 	return typeID, payload, requestID, flags, extensions, nil
 }
+
+// verify 校验帧签名，未设置签名器时不校验，返回签名是否校验通过
+func (c *BalancedCodec) verify(header, extData, payload []byte, extensions []TLV) (bool, error) {
+	ref := c.signer.Load()
+	if ref == nil {
+		return false, nil
+	}
+	var signature []byte
+	for _, tlv := range extensions {
+		if tlv.Type == TLVTypeSignature {
+			signature = tlv.Value
+		}
+	}
+	switch {
+	case signature == nil && ref.required:
+		return false, ErrUnsignedFrame
+	case signature == nil:
+		return false, nil
+	case len(signature) < 4:
+		return false, fmt.Errorf("%w: malformed signature", ErrBadSignature)
+	}
+	requestID := binary.BigEndian.Uint64(header[8:16])
+	typeID := binary.BigEndian.Uint32(header[16:20])
+	keyID := binary.BigEndian.Uint32(signature[:4])
+	if err := ref.Verify(keyID, signedData(header[4], requestID, typeID, extData, payload), signature[4:]); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// decrypt 解密负载，AEADEncryptor 还需校验帧头和序号
+func (c *BalancedCodec) decrypt(payload, header, extData []byte, extensions []TLV) ([]byte, error) {
+	encryptor := c.Encryptor()
+	if encryptor == nil {
+		return nil, ErrDecryptionFailed
+	}
+	aead, ok := encryptor.(AEADEncryptor)
+	if !ok {
+		return encryptor.Decrypt(payload)
+	}
+
+	seq, ok := sequenceOf(extensions)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing sequence number", ErrDecryptionFailed)
+	}
+	// 解密前先丢弃明显的重放，解密成功后才记录序号，避免伪造帧污染窗口
+	if !c.replay.Check(seq) {
+		return nil, fmt.Errorf("%w: sequence %d", ErrReplayedFrame, seq)
+	}
+	requestID := binary.BigEndian.Uint64(header[8:16])
+	typeID := binary.BigEndian.Uint32(header[16:20])
+	ad := additionalData(header[4], requestID, typeID, extData)
+
+	var plaintext []byte
+	var err error
+	if ring, keyed := aead.(KeyRingEncryptor); keyed {
+		keyID, found := keyIDOf(extensions)
+		if !found {
+			return nil, fmt.Errorf("%w: missing key id", ErrDecryptionFailed)
+		}
+		plaintext, err = ring.OpenWithKey(keyID, payload, ad)
+	} else {
+		plaintext, err = aead.Open(payload, ad)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !c.replay.Accept(seq) {
+		return nil, fmt.Errorf("%w: sequence %d", ErrReplayedFrame, seq)
+	}
+	return plaintext, nil
+}
+
+// additionalData 加密帧的附加数据: 版本和标志 + 请求ID + 类型ID + 扩展区
+func additionalData(versionFlags byte, requestID uint64, typeID uint32, extData []byte) []byte {
+	ad := make([]byte, 0, 13+len(extData))
+	ad = append(ad, versionFlags)
+	ad = binary.BigEndian.AppendUint64(ad, requestID)
+	ad = binary.BigEndian.AppendUint32(ad, typeID)
+	return append(ad, extData...)
+}
+
+// appendTLV 追加TLV编码: Type(8bit) + Length(16bit, 大端序) + Value
+func appendTLV(dst []byte, tlv TLV) []byte {
+	dst = append(dst, tlv.Type, byte(tlv.Length>>8), byte(tlv.Length))
+	return append(dst, tlv.Value...)
+}
+
+// keyIDOf 从扩展区中读取密钥ID
+func keyIDOf(extensions []TLV) (uint32, bool) {
+	for _, tlv := range extensions {
+		if tlv.Type == TLVTypeKeyID && len(tlv.Value) == 4 {
+			return binary.BigEndian.Uint32(tlv.Value), true
+		}
+	}
+	return 0, false
+}
+
+// sequenceOf 从扩展区中读取序号
+func sequenceOf(extensions []TLV) (uint64, bool) {
+	for _, tlv := range extensions {
+		if tlv.Type == TLVTypeSequence && len(tlv.Value) == 8 {
+			return binary.BigEndian.Uint64(tlv.Value), true
+		}
+	}
+	return 0, false
+}
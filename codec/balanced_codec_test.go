@@ -40,6 +40,18 @@ func TestBalancedCodecRoundTrip(t *testing.T) {
 	assert.Equal(t, "123", decodedData["number"])
 }
 
+func TestBalancedCodecRawPayload(t *testing.T) {
+	c := codec.NewBalancedCodec(serializer.DefaultSerializer)
+	buf := &bytes.Buffer{}
+
+	// 已经序列化的负载原样发送，不会被序列化为JSON字符串
+	raw := codec.RawPayload(`{"key":"value"}`)
+	require.NoError(t, c.Encode(buf, 1, raw, 2))
+	_, decodedPayload, _, err := c.Decode(buf)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(raw), decodedPayload)
+}
+
 func TestBalancedCodecWithEncryption(t *testing.T) {
 	s := serializer.DefaultSerializer
 
@@ -0,0 +1,355 @@
+package codec
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// ErrUnknownKey 密钥ID不在密钥环中或已退役
+var ErrUnknownKey = errors.New("unknown or retired encryption key")
+
+const (
+	// keyRingMaxSkip 接收方最多向前派生的密钥数量
+	keyRingMaxSkip = 16
+	// keyRingDeriveInfo 派生下一把密钥时使用的HKDF信息
+	keyRingDeriveInfo = "chilix-msg key ring v1"
+)
+
+// KeyRingEncryptor 使用多把密钥的加密器
+// BalancedCodec 用当前密钥加密，并通过 TLVTypeKeyID 扩展字段告诉接收方使用的密钥
+type KeyRingEncryptor interface {
+	AEADEncryptor
+	// CurrentKey 返回发送使用的密钥ID
+	CurrentKey() uint32
+	// SealWithKey 使用指定密钥加密
+	SealWithKey(keyID uint32, data, additionalData []byte) ([]byte, error)
+	// OpenWithKey 使用指定密钥解密，密钥不可用时返回 ErrUnknownKey
+	OpenWithKey(keyID uint32, data, additionalData []byte) ([]byte, error)
+}
+
+// KeyRingConfig 密钥环配置
+type KeyRingConfig struct {
+	// RotateInterval 当前密钥使用超过该时间后自动轮换，0表示不按时间轮换
+	RotateInterval time.Duration
+	// RotateBytes 当前密钥加密的数据超过该字节数后自动轮换，0表示不按流量轮换
+	RotateBytes int64
+	// GracePeriod 旧密钥被替换后仍可用于解密的时间，默认1分钟
+	GracePeriod time.Duration
+}
+
+// ringKey 密钥环中的一把密钥
+type ringKey struct {
+	key       []byte
+	encryptor *AESEncryptor
+	retireAt  time.Time // 零值表示未退役
+}
+
+// KeyRing AES-GCM密钥环
+//
+// 密钥环中ID最大的密钥是当前密钥，用于发送；其他未退役的密钥只用于解密。
+// 出现更新的密钥时，旧密钥在 GracePeriod 之后退役。
+//
+// 新密钥有两种来源:
+//   - 自动轮换：按 RotateInterval 或 RotateBytes 从当前密钥用 HKDF 派生 ID+1 的密钥。
+//     接收方收到未知的更新ID时用同样的方式派生，双方不需要交换新密钥。
+//     派生密钥可以由旧密钥推出，需要彻底更换密钥材料时使用 Add 或密钥文件
+//   - 显式添加：Add 或从密钥文件 Reload，连接不需要断开
+//
+// 同一个密钥环可以被多个连接共享，一个连接上的轮换对所有连接生效。
+// 防重放只在单个连接内有效，共享的密钥下一个连接上截获的帧可以在新连接上重放，需要跨连接防重放时使用 handshake 协商的密钥。
+type KeyRing struct {
+	config KeyRingConfig
+	file   string
+
+	mu        sync.Mutex
+	keys      map[uint32]*ringKey
+	current   uint32
+	activated time.Time // 当前密钥开始使用的时间
+	sealed    int64     // 当前密钥已加密的字节数
+}
+
+var _ KeyRingEncryptor = (*KeyRing)(nil)
+
+// NewKeyRing 创建密钥环，key 为初始AES密钥，长度必须是16、24或32字节
+func NewKeyRing(keyID uint32, key []byte, config KeyRingConfig) (*KeyRing, error) {
+	r := newKeyRing(config)
+	if err := r.Add(keyID, key); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// LoadKeyRing 从JSON密钥文件创建密钥环，之后可以调用 Reload 重新加载
+// 文件格式: {"keys": [{"id": 1, "key": "base64编码的密钥"}]}
+func LoadKeyRing(file string, config KeyRingConfig) (*KeyRing, error) {
+	r := newKeyRing(config)
+	r.file = file
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func newKeyRing(config KeyRingConfig) *KeyRing {
+	if config.GracePeriod <= 0 {
+		config.GracePeriod = time.Minute
+	}
+	return &KeyRing{config: config, keys: make(map[uint32]*ringKey)}
+}
+
+// Add 添加密钥，ID比当前密钥大时成为新的当前密钥
+func (r *KeyRing) Add(keyID uint32, key []byte) error {
+	encryptor, err := NewAESEncryptor(key)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.add(keyID, &ringKey{key: key, encryptor: encryptor}, time.Now())
+	return nil
+}
+
+// Reload 从密钥文件重新加载，文件无效时保留原密钥，文件中已删除的密钥在 GracePeriod 之后退役
+func (r *KeyRing) Reload() error {
+	if r.file == "" {
+		return errors.New("key ring has no key file")
+	}
+	data, err := os.ReadFile(r.file)
+	if err != nil {
+		return err
+	}
+	var content struct {
+		Keys []struct {
+			ID  uint32 `json:"id"`
+			Key string `json:"key"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return fmt.Errorf("failed to parse key file %s: %w", r.file, err)
+	}
+	if len(content.Keys) == 0 {
+		return fmt.Errorf("no keys in key file %s", r.file)
+	}
+
+	// 先全部解析，有一把无效就不修改密钥环
+	loaded := make(map[uint32]*ringKey, len(content.Keys))
+	for _, k := range content.Keys {
+		key, err := base64.StdEncoding.DecodeString(k.Key)
+		if err != nil {
+			return fmt.Errorf("key %d: %w", k.ID, err)
+		}
+		encryptor, err := NewAESEncryptor(key)
+		if err != nil {
+			return fmt.Errorf("key %d: %w", k.ID, err)
+		}
+		loaded[k.ID] = &ringKey{key: key, encryptor: encryptor}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, id := range slices.Sorted(maps.Keys(loaded)) {
+		if existing, ok := r.keys[id]; ok && bytes.Equal(existing.key, loaded[id].key) {
+			continue
+		}
+		r.add(id, loaded[id], now)
+	}
+	for id, k := range r.keys {
+		if _, ok := loaded[id]; !ok && k.retireAt.IsZero() && id != r.current {
+			k.retireAt = now.Add(r.config.GracePeriod)
+		}
+	}
+	return nil
+}
+
+// Rotate 立即轮换到从当前密钥派生的新密钥，返回新密钥ID
+func (r *KeyRing) Rotate() (uint32, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.deriveTo(r.current+1, time.Now()); err != nil {
+		return 0, err
+	}
+	return r.current, nil
+}
+
+// Keys 返回可用的密钥ID，按从旧到新排列
+func (r *KeyRing) Keys() []uint32 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune(time.Now())
+	return slices.Sorted(maps.Keys(r.keys))
+}
+
+// CurrentKey 返回发送使用的密钥ID，达到轮换条件时先轮换
+func (r *KeyRing) CurrentKey() uint32 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	expired := r.config.RotateInterval > 0 && now.Sub(r.activated) >= r.config.RotateInterval
+	exhausted := r.config.RotateBytes > 0 && r.sealed >= r.config.RotateBytes
+	if expired || exhausted {
+		// ID用尽时继续使用当前密钥
+		_ = r.deriveTo(r.current+1, now)
+	}
+	return r.current
+}
+
+// SealWithKey 使用指定密钥加密
+func (r *KeyRing) SealWithKey(keyID uint32, data, additionalData []byte) ([]byte, error) {
+	r.mu.Lock()
+	r.prune(time.Now())
+	k, ok := r.keys[keyID]
+	if ok && keyID == r.current {
+		r.sealed += int64(len(data))
+	}
+	r.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownKey, keyID)
+	}
+	return k.encryptor.Seal(data, additionalData)
+}
+
+// OpenWithKey 使用指定密钥解密
+// 对端已经轮换到更新的派生密钥时先派生，解密成功后才加入密钥环，伪造的帧不会触发轮换
+func (r *KeyRing) OpenWithKey(keyID uint32, data, additionalData []byte) ([]byte, error) {
+	now := time.Now()
+	r.mu.Lock()
+	r.prune(now)
+	k, ok := r.keys[keyID]
+	baseID, base := r.current, r.keys[r.current]
+	r.mu.Unlock()
+
+	var derived []*ringKey
+	if !ok {
+		if keyID <= baseID || keyID-baseID > keyRingMaxSkip {
+			return nil, fmt.Errorf("%w: %d", ErrUnknownKey, keyID)
+		}
+		for id := baseID + 1; id <= keyID; id++ {
+			next, err := deriveKey(base.key, id)
+			if err != nil {
+				return nil, err
+			}
+			derived = append(derived, next)
+			base = next
+		}
+		k = base
+	}
+
+	plaintext, err := k.encryptor.Open(data, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	if len(derived) > 0 {
+		r.mu.Lock()
+		for i, next := range derived {
+			if id := baseID + 1 + uint32(i); id > r.current {
+				r.add(id, next, now)
+			}
+		}
+		r.mu.Unlock()
+	}
+	return plaintext, nil
+}
+
+// Seal 使用当前密钥加密，格式: 密钥ID(32bit) + nonce + 密文
+// 不经过 BalancedCodec 使用密钥环时，密钥ID放在密文前
+func (r *KeyRing) Seal(data, additionalData []byte) ([]byte, error) {
+	keyID := r.CurrentKey()
+	sealed, err := r.SealWithKey(keyID, data, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	return append(binary.BigEndian.AppendUint32(nil, keyID), sealed...), nil
+}
+
+// Open 解密 Seal 的输出
+func (r *KeyRing) Open(data, additionalData []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, ErrDecryptionFailed
+	}
+	return r.OpenWithKey(binary.BigEndian.Uint32(data), data[4:], additionalData)
+}
+
+// Encrypt 使用当前密钥加密
+func (r *KeyRing) Encrypt(data []byte) ([]byte, error) {
+	return r.Seal(data, nil)
+}
+
+// Decrypt 解密 Encrypt 的输出
+func (r *KeyRing) Decrypt(data []byte) ([]byte, error) {
+	return r.Open(data, nil)
+}
+
+// deriveTo 从当前密钥逐个派生到指定ID，调用方持有锁
+func (r *KeyRing) deriveTo(keyID uint32, now time.Time) error {
+	if keyID <= r.current {
+		return fmt.Errorf("%w: key id %d exhausted", ErrInvalidKey, r.current)
+	}
+	for r.current < keyID {
+		next, err := deriveKey(r.keys[r.current].key, r.current+1)
+		if err != nil {
+			return err
+		}
+		r.add(r.current+1, next, now)
+	}
+	return nil
+}
+
+// deriveKey 用 HKDF-SHA256 从上一把密钥派生指定ID的密钥，长度不变
+func deriveKey(previous []byte, keyID uint32) (*ringKey, error) {
+	key := make([]byte, len(previous))
+	info := binary.BigEndian.AppendUint32([]byte(keyRingDeriveInfo), keyID)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, previous, nil, info), key); err != nil {
+		return nil, err
+	}
+	encryptor, err := NewAESEncryptor(key)
+	if err != nil {
+		return nil, err
+	}
+	return &ringKey{key: key, encryptor: encryptor}, nil
+}
+
+// add 添加密钥，调用方持有锁
+func (r *KeyRing) add(keyID uint32, k *ringKey, now time.Time) {
+	r.keys[keyID] = k
+	if len(r.keys) > 1 && keyID < r.current {
+		// 比当前密钥旧的密钥只用于解密
+		k.retireAt = now.Add(r.config.GracePeriod)
+		return
+	}
+	if len(r.keys) > 1 && keyID == r.current {
+		// 替换当前密钥的内容
+		return
+	}
+	// 新的当前密钥，旧密钥进入退役倒计时
+	for id, old := range r.keys {
+		if id != keyID && old.retireAt.IsZero() {
+			old.retireAt = now.Add(r.config.GracePeriod)
+		}
+	}
+	r.current = keyID
+	r.activated = now
+	r.sealed = 0
+}
+
+// prune 删除已退役的密钥，调用方持有锁
+func (r *KeyRing) prune(now time.Time) {
+	for id, k := range r.keys {
+		if id != r.current && !k.retireAt.IsZero() && now.After(k.retireAt) {
+			delete(r.keys, id)
+		}
+	}
+}
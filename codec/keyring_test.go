@@ -0,0 +1,148 @@
+package codec_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/codec"
+	"github.com/BadKid90s/chilix-msg/serializer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ringKey = []byte("0123456789abcdef0123456789abcdef")
+
+func newRing(t *testing.T, config codec.KeyRingConfig) *codec.KeyRing {
+	t.Helper()
+	ring, err := codec.NewKeyRing(1, ringKey, config)
+	require.NoError(t, err)
+	return ring
+}
+
+// roundTrip 用发送方编码一帧，再用接收方解码
+func roundTrip(sender, receiver *codec.BalancedCodec, payload string) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := sender.EncodeWithFlags(buf, 1, payload, 0, codec.BalancedFlagEncrypted, nil); err != nil {
+		return "", err
+	}
+	_, data, _, _, extensions, err := receiver.DecodeWithFlags(buf)
+	if err != nil {
+		return "", err
+	}
+	if len(extensions) > 0 {
+		return "", fmt.Errorf("unexpected extensions %v", extensions)
+	}
+	var decoded string
+	err = serializer.DefaultSerializer.Deserialize(data, &decoded)
+	return decoded, err
+}
+
+func TestKeyRing_RotateBytes(t *testing.T) {
+	senderRing := newRing(t, codec.KeyRingConfig{RotateBytes: 64})
+	receiverRing := newRing(t, codec.KeyRingConfig{})
+	sender := codec.NewBalancedCodecWithEncryption(serializer.DefaultSerializer, senderRing)
+	receiver := codec.NewBalancedCodecWithEncryption(serializer.DefaultSerializer, receiverRing)
+
+	// 每帧约30字节，第三帧开始使用派生的新密钥，接收方自动跟随
+	for i := range 6 {
+		msg := fmt.Sprintf("message number %02d with data", i)
+		decoded, err := roundTrip(sender, receiver, msg)
+		require.NoError(t, err)
+		assert.Equal(t, msg, decoded)
+	}
+	assert.Greater(t, receiverRing.CurrentKey(), uint32(1))
+}
+
+func TestKeyRing_RotateInterval(t *testing.T) {
+	ring := newRing(t, codec.KeyRingConfig{RotateInterval: 20 * time.Millisecond})
+	assert.Equal(t, uint32(1), ring.CurrentKey())
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, uint32(2), ring.CurrentKey())
+	assert.Equal(t, []uint32{1, 2}, ring.Keys())
+}
+
+func TestKeyRing_GracePeriod(t *testing.T) {
+	ring := newRing(t, codec.KeyRingConfig{GracePeriod: 50 * time.Millisecond})
+	sealed, err := ring.Encrypt([]byte("old"))
+	require.NoError(t, err)
+
+	id, err := ring.Rotate()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), id)
+
+	// 宽限期内旧密钥仍可解密
+	plain, err := ring.Decrypt(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(plain))
+
+	time.Sleep(80 * time.Millisecond)
+	_, err = ring.Decrypt(sealed)
+	assert.True(t, errors.Is(err, codec.ErrUnknownKey))
+	assert.Equal(t, []uint32{2}, ring.Keys())
+}
+
+func TestKeyRing_ForgedKeyID(t *testing.T) {
+	ring := newRing(t, codec.KeyRingConfig{})
+
+	// 未知的更新密钥ID只有解密成功后才会加入密钥环
+	_, err := ring.OpenWithKey(5, make([]byte, 64), nil)
+	assert.True(t, errors.Is(err, codec.ErrDecryptionFailed))
+	assert.Equal(t, uint32(1), ring.CurrentKey())
+
+	_, err = ring.OpenWithKey(100, make([]byte, 64), nil)
+	assert.True(t, errors.Is(err, codec.ErrUnknownKey))
+}
+
+func writeKeyFile(t *testing.T, file string, keys map[uint32][]byte) {
+	t.Helper()
+	content := `{"keys": [`
+	first := true
+	for id, key := range keys {
+		if !first {
+			content += ","
+		}
+		first = false
+		content += fmt.Sprintf(`{"id": %d, "key": %q}`, id, base64.StdEncoding.EncodeToString(key))
+	}
+	require.NoError(t, os.WriteFile(file, []byte(content+"]}"), 0o600))
+}
+
+func TestKeyRing_File(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys.json")
+	writeKeyFile(t, file, map[uint32][]byte{1: ringKey})
+
+	sender, err := codec.LoadKeyRing(file, codec.KeyRingConfig{})
+	require.NoError(t, err)
+	receiver, err := codec.LoadKeyRing(file, codec.KeyRingConfig{})
+	require.NoError(t, err)
+	sealed, err := sender.Encrypt([]byte("before"))
+	require.NoError(t, err)
+
+	// 添加新密钥后双方都切换到新密钥，旧密钥在宽限期内仍然可用
+	newKey := bytes.Repeat([]byte{0x42}, 32)
+	writeKeyFile(t, file, map[uint32][]byte{1: ringKey, 7: newKey})
+	require.NoError(t, sender.Reload())
+	require.NoError(t, receiver.Reload())
+	assert.Equal(t, uint32(7), sender.CurrentKey())
+
+	plain, err := receiver.Decrypt(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "before", string(plain))
+
+	sealed, err = sender.Encrypt([]byte("after"))
+	require.NoError(t, err)
+	plain, err = receiver.Decrypt(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "after", string(plain))
+
+	// 无效的文件不影响现有密钥
+	require.NoError(t, os.WriteFile(file, []byte(`{"keys": [{"id": 8, "key": "short"}]}`), 0o600))
+	assert.Error(t, sender.Reload())
+	assert.Equal(t, uint32(7), sender.CurrentKey())
+}
@@ -0,0 +1,79 @@
+package codec
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrReplayedFrame 加密帧的序号重复或已过期
+var ErrReplayedFrame = errors.New("replayed or stale frame")
+
+// DefaultReplayWindowSize 默认防重放窗口大小
+const DefaultReplayWindowSize = 1024
+
+// ReplayWindow 防重放滑动窗口
+// 记录最近 size 个序号的接收情况：重复的序号和早于窗口的序号被拒绝，窗口内允许乱序，
+// 以容纳并发发送和数据报传输造成的重排
+type ReplayWindow struct {
+	mu     sync.Mutex
+	size   uint64
+	latest uint64   // 已接受的最大序号
+	bitmap []uint64 // 按 序号 % size 记录窗口内已接受的序号
+}
+
+// NewReplayWindow 创建防重放窗口，size 向上取整为64的倍数，默认 DefaultReplayWindowSize
+func NewReplayWindow(size int) *ReplayWindow {
+	if size <= 0 {
+		size = DefaultReplayWindowSize
+	}
+	words := (size + 63) / 64
+	return &ReplayWindow{size: uint64(words * 64), bitmap: make([]uint64, words)}
+}
+
+// Check 判断序号是否可以接受，不修改窗口
+// 用于在解密前快速丢弃重放帧，解密成功后仍需调用 Accept
+func (w *ReplayWindow) Check(seq uint64) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.check(seq)
+}
+
+// Accept 判断序号是否可以接受，可以时记录该序号
+func (w *ReplayWindow) Accept(seq uint64) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.check(seq) {
+		return false
+	}
+	if seq > w.latest {
+		// 窗口前移，清除滑出窗口的位
+		if seq-w.latest >= w.size {
+			clear(w.bitmap)
+		} else {
+			for s := w.latest + 1; s < seq; s++ {
+				w.clearBit(s)
+			}
+		}
+		w.latest = seq
+	}
+	w.bitmap[(seq%w.size)/64] |= 1 << (seq % 64)
+	return true
+}
+
+func (w *ReplayWindow) check(seq uint64) bool {
+	switch {
+	case seq == 0:
+		// 序号从1开始
+		return false
+	case seq > w.latest:
+		return true
+	case w.latest-seq >= w.size:
+		return false
+	default:
+		return w.bitmap[(seq%w.size)/64]&(1<<(seq%64)) == 0
+	}
+}
+
+func (w *ReplayWindow) clearBit(seq uint64) {
+	w.bitmap[(seq%w.size)/64] &^= 1 << (seq % 64)
+}
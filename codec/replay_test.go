@@ -0,0 +1,91 @@
+package codec_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/BadKid90s/chilix-msg/codec"
+	"github.com/BadKid90s/chilix-msg/serializer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayWindow(t *testing.T) {
+	w := codec.NewReplayWindow(64)
+
+	assert.False(t, w.Accept(0), "sequence numbers start at 1")
+	assert.True(t, w.Accept(1))
+	assert.False(t, w.Accept(1), "duplicate")
+
+	// 窗口内乱序
+	assert.True(t, w.Accept(10))
+	assert.True(t, w.Accept(5))
+	assert.False(t, w.Accept(5))
+	assert.True(t, w.Check(9))
+	assert.True(t, w.Check(9), "Check does not record")
+
+	// 窗口前移后过旧的序号被拒绝
+	assert.True(t, w.Accept(100))
+	assert.False(t, w.Accept(36))
+	assert.True(t, w.Accept(37))
+	assert.False(t, w.Accept(37))
+
+	// 大幅前移清空窗口
+	assert.True(t, w.Accept(1000))
+	assert.True(t, w.Accept(999))
+	assert.False(t, w.Accept(100))
+}
+
+// encodeFrame 编码一个加密帧并返回原始字节
+func encodeFrame(t *testing.T, c *codec.BalancedCodec, payload string, requestID uint64) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	require.NoError(t, c.EncodeWithFlags(buf, 1, payload, requestID, codec.BalancedFlagEncrypted, nil))
+	return buf.Bytes()
+}
+
+func TestBalancedCodecReplayProtection(t *testing.T) {
+	encryptor, err := codec.NewAESEncryptor([]byte("1234567890123456"))
+	require.NoError(t, err)
+	sender := codec.NewBalancedCodecWithEncryption(serializer.DefaultSerializer, encryptor)
+	receiver := codec.NewBalancedCodecWithEncryption(serializer.DefaultSerializer, encryptor)
+
+	first := encodeFrame(t, sender, "first", 1)
+	second := encodeFrame(t, sender, "second", 2)
+
+	// 乱序到达的帧可以接受
+	_, payload, _, _, _, err := receiver.DecodeWithFlags(bytes.NewReader(second))
+	require.NoError(t, err)
+	assert.Equal(t, `"second"`, string(payload))
+	_, payload, _, _, _, err = receiver.DecodeWithFlags(bytes.NewReader(first))
+	require.NoError(t, err)
+	assert.Equal(t, `"first"`, string(payload))
+
+	// 重放被拒绝
+	_, _, _, _, _, err = receiver.DecodeWithFlags(bytes.NewReader(first))
+	assert.True(t, errors.Is(err, codec.ErrReplayedFrame))
+}
+
+func TestBalancedCodecHeaderBinding(t *testing.T) {
+	encryptor, err := codec.NewAESEncryptor([]byte("1234567890123456"))
+	require.NoError(t, err)
+	sender := codec.NewBalancedCodecWithEncryption(serializer.DefaultSerializer, encryptor)
+	receiver := codec.NewBalancedCodecWithEncryption(serializer.DefaultSerializer, encryptor)
+
+	// 修改请求ID、类型ID或序号都会导致认证失败
+	for name, offset := range map[string]int{"request id": 15, "type id": 19, "sequence": codec.BalancedHeaderSize + 9} {
+		t.Run(name, func(t *testing.T) {
+			frame := encodeFrame(t, sender, "payload", 7)
+			frame[offset] ^= 0x01
+			_, _, _, _, _, err := receiver.DecodeWithFlags(bytes.NewReader(frame))
+			assert.True(t, errors.Is(err, codec.ErrDecryptionFailed), "got %v", err)
+		})
+	}
+
+	// 篡改失败的帧不影响后续正常的帧
+	frame := encodeFrame(t, sender, "payload", 7)
+	_, _, requestID, _, _, err := receiver.DecodeWithFlags(bytes.NewReader(frame))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), requestID)
+}
@@ -0,0 +1,211 @@
+package codec
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+var (
+	// ErrBadSignature 帧签名校验失败
+	ErrBadSignature = errors.New("bad frame signature")
+	// ErrUnsignedFrame 要求签名时收到未签名的帧
+	ErrUnsignedFrame = errors.New("unsigned frame")
+)
+
+// Signer 消息签名器
+// BalancedCodec 在加密之后对帧头、扩展区和负载签名，签名通过 TLVTypeSignature 扩展字段携带，
+// 只需要防篡改而不需要保密的链路可以只使用签名
+type Signer interface {
+	// SignKey 返回签名使用的密钥ID
+	SignKey() uint32
+	// Sign 使用指定密钥对数据签名
+	Sign(keyID uint32, data []byte) ([]byte, error)
+	// Verify 使用指定密钥校验签名，密钥不可用时返回 ErrUnknownKey，签名不匹配时返回 ErrBadSignature
+	Verify(keyID uint32, data, signature []byte) error
+}
+
+// HMACSigner HMAC-SHA256 签名器，双方使用相同的密钥
+// 轮换时先在双方 Add 新密钥，确认对端都已更新后再 Remove 旧密钥
+type HMACSigner struct {
+	mu      sync.RWMutex
+	keys    map[uint32][]byte
+	current uint32
+}
+
+// NewHMACSigner 创建 HMAC-SHA256 签名器，id 为第一个密钥的ID
+func NewHMACSigner(id uint32, key []byte) (*HMACSigner, error) {
+	s := &HMACSigner{keys: make(map[uint32][]byte)}
+	if err := s.Add(id, key); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add 添加密钥并用于之后的签名，已有的密钥仍可用于校验
+func (s *HMACSigner) Add(id uint32, key []byte) error {
+	if len(key) < 16 {
+		return fmt.Errorf("%w: HMAC key must be at least 16 bytes", ErrInvalidKey)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[id] = slices.Clone(key)
+	s.current = id
+	return nil
+}
+
+// Remove 移除用于校验的密钥，不能移除当前签名密钥
+func (s *HMACSigner) Remove(id uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id != s.current {
+		delete(s.keys, id)
+	}
+}
+
+// SignKey 返回当前签名密钥ID
+func (s *HMACSigner) SignKey() uint32 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// Sign 计算 HMAC-SHA256
+func (s *HMACSigner) Sign(keyID uint32, data []byte) ([]byte, error) {
+	key, err := s.key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// Verify 校验 HMAC-SHA256
+func (s *HMACSigner) Verify(keyID uint32, data, signature []byte) error {
+	expected, err := s.Sign(keyID, data)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(expected, signature) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+func (s *HMACSigner) key(id uint32) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownKey, id)
+	}
+	return key, nil
+}
+
+// Ed25519Signer Ed25519 签名器，用自己的私钥签名，用对端公钥校验
+// 对端公钥按对端签名时使用的密钥ID登记
+type Ed25519Signer struct {
+	mu    sync.RWMutex
+	id    uint32
+	key   ed25519.PrivateKey
+	peers map[uint32]ed25519.PublicKey
+}
+
+// NewEd25519Signer 创建 Ed25519 签名器，用 key 签名，用 peers 中对端的公钥校验
+func NewEd25519Signer(id uint32, key ed25519.PrivateKey, peers map[uint32]ed25519.PublicKey) (*Ed25519Signer, error) {
+	s := &Ed25519Signer{peers: make(map[uint32]ed25519.PublicKey)}
+	if err := s.SetKey(id, key); err != nil {
+		return nil, err
+	}
+	for peerID, peerKey := range peers {
+		if err := s.AddPeer(peerID, peerKey); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// SetKey 替换签名私钥，对端需要先登记新的公钥
+func (s *Ed25519Signer) SetKey(id uint32, key ed25519.PrivateKey) error {
+	if len(key) != ed25519.PrivateKeySize {
+		return fmt.Errorf("%w: invalid Ed25519 private key", ErrInvalidKey)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.id, s.key = id, key
+	return nil
+}
+
+// AddPeer 登记对端公钥
+func (s *Ed25519Signer) AddPeer(id uint32, key ed25519.PublicKey) error {
+	if len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: invalid Ed25519 public key", ErrInvalidKey)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peers[id] = key
+	return nil
+}
+
+// RemovePeer 移除对端公钥
+func (s *Ed25519Signer) RemovePeer(id uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.peers, id)
+}
+
+// SignKey 返回签名私钥的ID
+func (s *Ed25519Signer) SignKey() uint32 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.id
+}
+
+// Sign 使用私钥签名，keyID 必须是当前私钥的ID
+func (s *Ed25519Signer) Sign(keyID uint32, data []byte) ([]byte, error) {
+	s.mu.RLock()
+	id, key := s.id, s.key
+	s.mu.RUnlock()
+	if keyID != id {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownKey, keyID)
+	}
+	return ed25519.Sign(key, data), nil
+}
+
+// Verify 使用对端公钥校验签名
+func (s *Ed25519Signer) Verify(keyID uint32, data, signature []byte) error {
+	s.mu.RLock()
+	key, ok := s.peers[keyID]
+	s.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownKey, keyID)
+	}
+	if !ed25519.Verify(key, data, signature) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// signerRef 包装签名器以便原子替换
+type signerRef struct {
+	Signer
+	required bool
+}
+
+// signatureTLV 构造签名扩展字段: 密钥ID(32bit) + 签名
+func signatureTLV(keyID uint32, signature []byte) TLV {
+	value := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(signature)), keyID)
+	value = append(value, signature...)
+	return TLV{Type: TLVTypeSignature, Length: uint16(len(value)), Value: value}
+}
+
+// signedData 签名覆盖的数据: 附加数据（不含签名扩展字段）+ 负载
+func signedData(versionFlags byte, requestID uint64, typeID uint32, extData, payload []byte) []byte {
+	return append(additionalData(versionFlags, requestID, typeID, extData), payload...)
+}
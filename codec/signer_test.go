@@ -0,0 +1,196 @@
+package codec_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/BadKid90s/chilix-msg/codec"
+	"github.com/BadKid90s/chilix-msg/serializer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var signKey = []byte("0123456789abcdef")
+
+// signedPair 创建使用相同签名器的发送方和接收方
+func signedPair(t *testing.T, signer codec.Signer) (sender, receiver *codec.BalancedCodec) {
+	t.Helper()
+	sender = codec.NewBalancedCodec(serializer.DefaultSerializer)
+	sender.SetSigner(signer, true)
+	receiver = codec.NewBalancedCodec(serializer.DefaultSerializer)
+	receiver.SetSigner(signer, true)
+	return sender, receiver
+}
+
+func encodeSigned(t *testing.T, c *codec.BalancedCodec, flags uint8, extensions []codec.TLV) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	require.NoError(t, c.EncodeWithFlags(buf, 1, "payload", 7, flags, extensions))
+	return buf.Bytes()
+}
+
+func TestSigner_HMAC(t *testing.T) {
+	signer, err := codec.NewHMACSigner(1, signKey)
+	require.NoError(t, err)
+	sender, receiver := signedPair(t, signer)
+
+	// 签名扩展字段不返回给调用方，其他扩展字段保留
+	custom := codec.TLV{Type: 0x10, Length: 2, Value: []byte("hi")}
+	frame := encodeSigned(t, sender, codec.BalancedFlagNone, []codec.TLV{custom})
+	_, payload, requestID, flags, extensions, err := receiver.DecodeWithFlags(bytes.NewReader(frame))
+	require.NoError(t, err)
+	assert.Equal(t, `"payload"`, string(payload))
+	assert.Equal(t, uint64(7), requestID)
+	assert.Equal(t, []codec.TLV{custom}, extensions)
+	assert.NotZero(t, flags&codec.BalancedFlagExtended)
+	assert.NotZero(t, flags&codec.BalancedFlagSigned)
+
+	frame = encodeSigned(t, sender, codec.BalancedFlagNone, nil)
+	_, _, _, flags, extensions, err = receiver.DecodeWithFlags(bytes.NewReader(frame))
+	require.NoError(t, err)
+	assert.Nil(t, extensions)
+	assert.Zero(t, flags&codec.BalancedFlagExtended)
+
+	// 修改请求ID、类型ID、自定义扩展字段或负载都会导致验签失败
+	for name, offset := range map[string]int{"request id": 15, "type id": 19, "extension": codec.BalancedHeaderSize + 4, "payload": -2} {
+		t.Run(name, func(t *testing.T) {
+			frame := encodeSigned(t, sender, codec.BalancedFlagNone, []codec.TLV{custom})
+			if offset < 0 {
+				offset += len(frame)
+			}
+			frame[offset] ^= 0x01
+			_, _, _, _, _, err := receiver.DecodeWithFlags(bytes.NewReader(frame))
+			assert.True(t, errors.Is(err, codec.ErrBadSignature), "got %v", err)
+		})
+	}
+}
+
+func TestSigner_Required(t *testing.T) {
+	signer, err := codec.NewHMACSigner(1, signKey)
+	require.NoError(t, err)
+	plain := codec.NewBalancedCodec(serializer.DefaultSerializer)
+	frame := encodeSigned(t, plain, codec.BalancedFlagNone, nil)
+
+	receiver := codec.NewBalancedCodec(serializer.DefaultSerializer)
+	receiver.SetSigner(signer, true)
+	_, _, _, _, _, err = receiver.DecodeWithFlags(bytes.NewReader(frame))
+	assert.True(t, errors.Is(err, codec.ErrUnsignedFrame), "got %v", err)
+
+	// 不要求签名时接受未签名的帧
+	receiver.SetSigner(signer, false)
+	_, payload, _, flags, _, err := receiver.DecodeWithFlags(bytes.NewReader(frame))
+	require.NoError(t, err)
+	assert.Equal(t, `"payload"`, string(payload))
+	assert.Zero(t, flags&codec.BalancedFlagSigned)
+
+	// 帧头中的签名标志不可信，解码时被清除
+	forged := encodeSigned(t, plain, codec.BalancedFlagSigned, nil)
+	assert.Zero(t, forged[4]&codec.BalancedFlagSigned)
+	forged[4] |= codec.BalancedFlagSigned
+	_, _, _, flags, _, err = receiver.DecodeWithFlags(bytes.NewReader(forged))
+	require.NoError(t, err)
+	assert.Zero(t, flags&codec.BalancedFlagSigned)
+
+	// 未设置签名器的接收方跳过签名
+	sender := codec.NewBalancedCodec(serializer.DefaultSerializer)
+	sender.SetSigner(signer, true)
+	signed := encodeSigned(t, sender, codec.BalancedFlagNone, nil)
+	_, _, _, flags, extensions, err := plain.DecodeWithFlags(bytes.NewReader(signed))
+	require.NoError(t, err)
+	assert.Nil(t, extensions)
+	assert.Zero(t, flags&codec.BalancedFlagSigned)
+
+	// 签名必须是最后一个扩展字段
+	buf := &bytes.Buffer{}
+	require.NoError(t, plain.EncodeWithFlags(buf, 1, "payload", 7, codec.BalancedFlagNone, []codec.TLV{
+		{Type: codec.TLVTypeSignature, Length: 4, Value: []byte{0, 0, 0, 1}},
+		{Type: 0x10, Length: 2, Value: []byte("hi")},
+	}))
+	_, _, _, _, _, err = receiver.DecodeWithFlags(buf)
+	assert.True(t, errors.Is(err, codec.ErrInvalidMessageFormat), "got %v", err)
+}
+
+func TestSigner_Rotation(t *testing.T) {
+	senderSigner, err := codec.NewHMACSigner(1, signKey)
+	require.NoError(t, err)
+	receiverSigner, err := codec.NewHMACSigner(1, signKey)
+	require.NoError(t, err)
+	sender, _ := signedPair(t, senderSigner)
+	_, receiver := signedPair(t, receiverSigner)
+
+	old := encodeSigned(t, sender, codec.BalancedFlagNone, nil)
+
+	// 接收方先登记新密钥，再切换发送方的签名密钥，旧密钥签名的帧仍可校验
+	require.NoError(t, receiverSigner.Add(2, []byte("fedcba9876543210")))
+	require.NoError(t, senderSigner.Add(2, []byte("fedcba9876543210")))
+	assert.Equal(t, uint32(2), senderSigner.SignKey())
+	_, _, _, _, _, err = receiver.DecodeWithFlags(bytes.NewReader(encodeSigned(t, sender, codec.BalancedFlagNone, nil)))
+	require.NoError(t, err)
+	_, _, _, _, _, err = receiver.DecodeWithFlags(bytes.NewReader(old))
+	require.NoError(t, err)
+
+	// 移除旧密钥后旧签名不再被接受
+	receiverSigner.Remove(1)
+	_, _, _, _, _, err = receiver.DecodeWithFlags(bytes.NewReader(old))
+	assert.True(t, errors.Is(err, codec.ErrUnknownKey), "got %v", err)
+
+	_, err = codec.NewHMACSigner(1, []byte("short"))
+	assert.True(t, errors.Is(err, codec.ErrInvalidKey))
+}
+
+func TestSigner_Ed25519(t *testing.T) {
+	clientPublic, clientPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	serverPublic, serverPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	client, err := codec.NewEd25519Signer(1, clientPrivate, map[uint32]ed25519.PublicKey{2: serverPublic})
+	require.NoError(t, err)
+	server, err := codec.NewEd25519Signer(2, serverPrivate, map[uint32]ed25519.PublicKey{1: clientPublic})
+	require.NoError(t, err)
+
+	clientCodec := codec.NewBalancedCodec(serializer.DefaultSerializer)
+	clientCodec.SetSigner(client, true)
+	serverCodec := codec.NewBalancedCodec(serializer.DefaultSerializer)
+	serverCodec.SetSigner(server, true)
+
+	_, payload, _, _, _, err := serverCodec.DecodeWithFlags(bytes.NewReader(encodeSigned(t, clientCodec, codec.BalancedFlagNone, nil)))
+	require.NoError(t, err)
+	assert.Equal(t, `"payload"`, string(payload))
+	_, _, _, _, _, err = clientCodec.DecodeWithFlags(bytes.NewReader(encodeSigned(t, serverCodec, codec.BalancedFlagNone, nil)))
+	require.NoError(t, err)
+
+	// 未登记的对端密钥
+	server.RemovePeer(1)
+	_, _, _, _, _, err = serverCodec.DecodeWithFlags(bytes.NewReader(encodeSigned(t, clientCodec, codec.BalancedFlagNone, nil)))
+	assert.True(t, errors.Is(err, codec.ErrUnknownKey), "got %v", err)
+}
+
+func TestSigner_WithEncryption(t *testing.T) {
+	encryptor, err := codec.NewAESEncryptor([]byte("1234567890123456"))
+	require.NoError(t, err)
+	signer, err := codec.NewHMACSigner(1, signKey)
+	require.NoError(t, err)
+	sender, receiver := signedPair(t, signer)
+	sender.SetEncryptor(encryptor)
+	receiver.SetEncryptor(encryptor)
+
+	frame := encodeSigned(t, sender, codec.BalancedFlagEncrypted, nil)
+	_, payload, _, flags, extensions, err := receiver.DecodeWithFlags(bytes.NewReader(frame))
+	require.NoError(t, err)
+	assert.Equal(t, `"payload"`, string(payload))
+	assert.Equal(t, uint8(codec.BalancedFlagEncrypted|codec.BalancedFlagSigned), flags)
+	assert.Nil(t, extensions)
+
+	// 密文被篡改时在解密之前就被签名校验拒绝，不占用防重放窗口
+	frame = encodeSigned(t, sender, codec.BalancedFlagEncrypted, nil)
+	frame[len(frame)-1] ^= 0x01
+	_, _, _, _, _, err = receiver.DecodeWithFlags(bytes.NewReader(frame))
+	assert.True(t, errors.Is(err, codec.ErrBadSignature), "got %v", err)
+	frame[len(frame)-1] ^= 0x01
+	_, _, _, _, _, err = receiver.DecodeWithFlags(bytes.NewReader(frame))
+	require.NoError(t, err)
+}
@@ -1,25 +1,30 @@
 package core
 
 import (
+	"github.com/BadKid90s/chilix-msg/codec"
 	"github.com/BadKid90s/chilix-msg/log"
 	"github.com/BadKid90s/chilix-msg/transport"
 )
 
 // Context 处理器上下文接口
 type Context interface {
-	Bind(target interface{}) error    // 绑定消息负载
-	MessageType() string              // 获取消息类型
-	RequestID() uint64                // 获取请求ID
-	IsRequest() bool                  // 判断是否是请求消息
-	IsResponse() bool                 // 判断是否是响应消息
-	Connection() transport.Connection // 获取底层连接
-	RawData() []byte                  // 获取原始数据
-	SetRawData(data []byte)           // 设置原始数据
-	Writer() Writer                   // 获取消息写入器
-	SetWriter(writer Writer)          // 设置写入器
-	Reply(payload interface{}) error  // 发送成功响应
-	Logger() log.Logger               //获取日志
-	Processor() Processor             //获取处理器
+	Bind(target interface{}) error                   // 绑定消息负载
+	MessageType() string                             // 获取消息类型
+	RequestID() uint64                               // 获取请求ID
+	IsRequest() bool                                 // 判断是否是请求消息
+	IsResponse() bool                                // 判断是否是响应消息
+	Connection() transport.Connection                // 获取底层连接
+	RawData() []byte                                 // 获取原始数据
+	SetRawData(data []byte)                          // 设置原始数据
+	Metadata() []codec.TLV                           // 获取对端随消息发送的元数据
+	Signed() bool                                    // 消息帧的签名是否已校验通过
+	Writer() Writer                                  // 获取消息写入器
+	SetWriter(writer Writer)                         // 设置写入器
+	Reply(payload interface{}) error                 // 发送成功响应
+	ReplyError(code ErrorCode, message string) error // 发送协议错误响应
+	Session() Session                                // 获取连接会话
+	Logger() log.Logger                              //获取日志
+	Processor() Processor                            //获取处理器
 }
 
 // processorContext 处理器上下文实现
@@ -28,6 +33,8 @@ type processorContext struct {
 	requestID  uint64
 	connection transport.Connection
 	rawData    []byte
+	metadata   []codec.TLV
+	signed     bool
 	processor  Processor
 	writer     Writer
 	logger     log.Logger
@@ -64,6 +71,14 @@ func (c *processorContext) RawData() []byte {
 func (c *processorContext) SetRawData(data []byte) {
 	c.rawData = data
 }
+
+func (c *processorContext) Metadata() []codec.TLV {
+	return c.metadata
+}
+
+func (c *processorContext) Signed() bool {
+	return c.signed
+}
 func (c *processorContext) Writer() Writer {
 	return c.writer
 }
@@ -81,3 +96,11 @@ func (c *processorContext) Logger() log.Logger {
 func (c *processorContext) Processor() Processor {
 	return c.processor
 }
+
+func (c *processorContext) ReplyError(code ErrorCode, message string) error {
+	return c.processor.SendError(c.requestID, c.msgType, NewProtocolError(code, message))
+}
+
+func (c *processorContext) Session() Session {
+	return c.processor.Session()
+}
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/log"
+	"github.com/BadKid90s/chilix-msg/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startDatagramServer 在数据报监听器上接受一个会话并启动处理器
+func startDatagramServer(t *testing.T, listener transport.PacketListener, setup func(Processor)) {
+	t.Helper()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		p := NewProcessor(conn, ProcessorConfig{Logger: log.NewDefaultLogger(), RequestTimeout: time.Second})
+		setup(p)
+		_ = p.Listen()
+	}()
+}
+
+func TestProcessor_Datagram(t *testing.T) {
+	udp := transport.NewUDPTransport()
+	quicDatagram, err := transport.NewQUICDatagramTransport(transport.QUICConfig{Insecure: true})
+	require.NoError(t, err)
+
+	for _, tr := range []transport.PacketTransport{udp, quicDatagram} {
+		t.Run(tr.Protocol(), func(t *testing.T) {
+			listener, err := tr.Listen("127.0.0.1:0")
+			require.NoError(t, err)
+			defer func() { _ = listener.Close() }()
+
+			received := make(chan string, 10)
+			startDatagramServer(t, listener, func(p Processor) {
+				p.RegisterHandler("telemetry", func(ctx Context) error {
+					var msg string
+					if err := ctx.Bind(&msg); err != nil {
+						return err
+					}
+					received <- msg
+					return nil
+				})
+			})
+
+			conn, err := tr.Dial(listener.Addr().String())
+			require.NoError(t, err)
+			client := NewProcessor(conn, ProcessorConfig{Logger: log.NewDefaultLogger(), RequestTimeout: time.Second})
+			defer func() { _ = client.Close() }()
+			go func() { _ = client.Listen() }()
+
+			// 服务端处理器需要先注册类型，重复发送以容忍启动期间的丢包
+			deadline := time.After(3 * time.Second)
+			for done := false; !done; {
+				require.NoError(t, client.Send("telemetry", "cpu=42"))
+				select {
+				case msg := <-received:
+					assert.Equal(t, "cpu=42", msg)
+					done = true
+				case <-time.After(100 * time.Millisecond):
+				case <-deadline:
+					t.Fatal("Timed out waiting for datagram")
+				}
+			}
+
+			// 数据报不支持请求-响应
+			_, err = client.Request("telemetry", "x")
+			assert.ErrorIs(t, err, ErrDatagramRequest)
+
+			// 超过MTU的帧返回明确的错误
+			err = client.Send("telemetry", strings.Repeat("x", 64*1024))
+			assert.ErrorIs(t, err, transport.ErrPacketTooLarge)
+		})
+	}
+}
+
+func TestProcessor_DatagramDropsInvalidPackets(t *testing.T) {
+	tr := transport.NewUDPTransport()
+	listener, err := tr.Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+
+	received := make(chan string, 1)
+	startDatagramServer(t, listener, func(p Processor) {
+		p.RegisterHandler("telemetry", func(ctx Context) error {
+			var msg string
+			_ = ctx.Bind(&msg)
+			received <- msg
+			return nil
+		})
+	})
+
+	// 先发送损坏的数据报，处理器应丢弃它并继续处理后续消息
+	raw, err := net.Dial("udp", listener.Addr().String())
+	require.NoError(t, err)
+	defer func() { _ = raw.Close() }()
+	_, err = raw.Write([]byte("garbage"))
+	require.NoError(t, err)
+
+	client := NewProcessor(raw, ProcessorConfig{Logger: log.NewDefaultLogger()})
+	deadline := time.After(3 * time.Second)
+	for {
+		require.NoError(t, client.Send("telemetry", "ok"))
+		select {
+		case msg := <-received:
+			assert.Equal(t, "ok", msg)
+			return
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("Processor stopped after invalid datagram")
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/codec"
+	"github.com/BadKid90s/chilix-msg/log"
+	"github.com/BadKid90s/chilix-msg/serializer"
+	"github.com/BadKid90s/chilix-msg/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessor_Encryptor(t *testing.T) {
+	encryptor, err := codec.NewAESEncryptor([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+
+	tr := transport.NewMemoryTransport()
+	listener, err := tr.Listen("")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	config := ProcessorConfig{Logger: log.NewDefaultLogger(), RequestTimeout: time.Second, Encryptor: encryptor}
+	received := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		server := NewProcessor(conn, config)
+		server.RegisterHandler("echo", func(ctx Context) error {
+			var msg string
+			if err := ctx.Bind(&msg); err != nil {
+				return err
+			}
+			received <- msg
+			return ctx.Reply(msg)
+		})
+		_ = server.Listen()
+	}()
+
+	conn, err := tr.Dial(listener.Addr().String())
+	require.NoError(t, err)
+	client := NewProcessor(conn, config)
+	defer client.Close()
+	go func() { _ = client.Listen() }()
+
+	resp, err := client.Request("echo", "secret")
+	require.NoError(t, err)
+	var reply string
+	require.NoError(t, resp.Bind(&reply))
+	assert.Equal(t, "secret", reply)
+	assert.Equal(t, "secret", <-received)
+
+	// 加密会话中的明文帧被丢弃，之后的加密帧仍能正常处理
+	plain := codec.NewBalancedCodec(serializer.DefaultSerializer)
+	typeID, _ := client.(*processor).typeRegistry.GetID("echo")
+	require.NoError(t, plain.Encode(conn, typeID, "forged", 0))
+	require.NoError(t, client.Send("echo", "after"))
+	assert.Equal(t, "after", <-received)
+}
+
+func TestProcessor_Signer(t *testing.T) {
+	signer, err := codec.NewHMACSigner(1, []byte("0123456789abcdef"))
+	require.NoError(t, err)
+	encryptor, err := codec.NewAESEncryptor([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+
+	received := make(chan string, 2)
+	client, _ := newProcessorPair(t, func(server Processor) {
+		server.SetSigner(signer, true)
+		server.SetEncryptor(encryptor)
+		server.RegisterHandler("echo", func(ctx Context) error {
+			var msg string
+			if err := ctx.Bind(&msg); err != nil {
+				return err
+			}
+			received <- msg
+			return ctx.Reply(msg)
+		})
+	})
+	client.SetSigner(signer, true)
+	client.SetEncryptor(encryptor)
+	assert.Equal(t, signer, client.Signer())
+
+	resp, err := client.Request("echo", "signed")
+	require.NoError(t, err)
+	var reply string
+	require.NoError(t, resp.Bind(&reply))
+	assert.Equal(t, "signed", reply)
+	assert.Equal(t, "signed", <-received)
+
+	// 要求签名时未签名的帧被丢弃
+	client.SetSigner(nil, false)
+	require.NoError(t, client.Send("echo", "unsigned"))
+	client.SetSigner(signer, true)
+	require.NoError(t, client.Send("echo", "after"))
+	assert.Equal(t, "after", <-received)
+}
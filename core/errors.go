@@ -0,0 +1,105 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/BadKid90s/chilix-msg/codec"
+)
+
+// ErrorCode 协议错误码，通过错误帧发送给对端
+type ErrorCode uint16
+
+const (
+	// CodeUnknown 未知错误
+	CodeUnknown ErrorCode = iota
+	// CodeBadRequest 消息格式或内容无效
+	CodeBadRequest
+	// CodeUnauthenticated 连接尚未通过认证或认证失败
+	CodeUnauthenticated
+	// CodePermissionDenied 已认证的身份无权发送该类型的消息
+	CodePermissionDenied
+	// CodeNotFound 没有处理该消息类型的处理器
+	CodeNotFound
+	// CodeInternal 处理器内部错误，例如处理器panic
+	CodeInternal
+	// CodeRateLimited 消息超出限流，稍后重试
+	CodeRateLimited
+)
+
+var codeNames = map[ErrorCode]string{
+	CodeUnknown:          "unknown",
+	CodeBadRequest:       "bad request",
+	CodeUnauthenticated:  "unauthenticated",
+	CodePermissionDenied: "permission denied",
+	CodeNotFound:         "not found",
+	CodeInternal:         "internal error",
+	CodeRateLimited:      "rate limited",
+}
+
+func (c ErrorCode) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("code %d", uint16(c))
+}
+
+// ProtocolError 框架层面的协议错误，与业务响应分开传输
+// 对端 Request 收到错误帧时返回 *ProtocolError，可以用 errors.Is 按错误码匹配
+type ProtocolError struct {
+	Code    ErrorCode
+	Message string
+}
+
+// NewProtocolError 创建协议错误
+func NewProtocolError(code ErrorCode, message string) *ProtocolError {
+	return &ProtocolError{Code: code, Message: message}
+}
+
+func (e *ProtocolError) Error() string {
+	if e.Message == "" {
+		return "protocol error: " + e.Code.String()
+	}
+	return fmt.Sprintf("protocol error: %s: %s", e.Code, e.Message)
+}
+
+// Is 按错误码匹配，例如 errors.Is(err, core.ErrUnauthenticated)
+func (e *ProtocolError) Is(target error) bool {
+	t, ok := target.(*ProtocolError)
+	return ok && t.Code == e.Code
+}
+
+// 用于 errors.Is 按错误码匹配的协议错误
+var (
+	ErrUnauthenticated  = NewProtocolError(CodeUnauthenticated, "")
+	ErrPermissionDenied = NewProtocolError(CodePermissionDenied, "")
+	ErrNotFound         = NewProtocolError(CodeNotFound, "")
+	ErrInternal         = NewProtocolError(CodeInternal, "")
+	ErrRateLimited      = NewProtocolError(CodeRateLimited, "")
+)
+
+// errorTLV 将协议错误编码为TLV，过长的错误信息被截断
+func errorTLV(e *ProtocolError) codec.TLV {
+	message := e.Message
+	if len(message) > 0xFFFF-2 {
+		message = message[:0xFFFF-2]
+	}
+	value := make([]byte, 2, 2+len(message))
+	binary.BigEndian.PutUint16(value, uint16(e.Code))
+	value = append(value, message...)
+	return codec.TLV{Type: codec.TLVTypeError, Length: uint16(len(value)), Value: value}
+}
+
+// protocolErrorOf 从扩展区中取出协议错误，没有错误TLV时返回nil
+func protocolErrorOf(extensions []codec.TLV) *ProtocolError {
+	for _, tlv := range extensions {
+		if tlv.Type != codec.TLVTypeError || len(tlv.Value) < 2 {
+			continue
+		}
+		return &ProtocolError{
+			Code:    ErrorCode(binary.BigEndian.Uint16(tlv.Value)),
+			Message: string(tlv.Value[2:]),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/codec"
+	"github.com/BadKid90s/chilix-msg/log"
+	"github.com/BadKid90s/chilix-msg/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newProcessorPair 通过内存传输创建一对已连接的处理器
+func newProcessorPair(t *testing.T, setup func(server Processor)) (client, server Processor) {
+	t.Helper()
+	tr := transport.NewMemoryTransport()
+	listener, err := tr.Listen("")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	config := ProcessorConfig{Logger: log.NewDefaultLogger(), RequestTimeout: time.Second}
+	accepted := make(chan Processor, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		p := NewProcessor(conn, config)
+		setup(p)
+		accepted <- p
+		_ = p.Listen()
+	}()
+
+	conn, err := tr.Dial(listener.Addr().String())
+	require.NoError(t, err)
+	client = NewProcessor(conn, config)
+	go func() { _ = client.Listen() }()
+	server = <-accepted
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	return client, server
+}
+
+func TestProcessor_ReplyError(t *testing.T) {
+	client, _ := newProcessorPair(t, func(p Processor) {
+		p.RegisterHandler("lookup", func(ctx Context) error {
+			return ctx.ReplyError(CodeBadRequest, "missing id")
+		})
+	})
+
+	_, err := client.Request("lookup", "x")
+	require.Error(t, err)
+
+	var protoErr *ProtocolError
+	require.True(t, errors.As(err, &protoErr))
+	assert.Equal(t, CodeBadRequest, protoErr.Code)
+	assert.Equal(t, "missing id", protoErr.Message)
+	assert.True(t, errors.Is(err, NewProtocolError(CodeBadRequest, "")))
+	assert.False(t, errors.Is(err, ErrUnauthenticated))
+}
+
+func TestProcessor_ErrorFrameNotDispatched(t *testing.T) {
+	received := make(chan string, 1)
+	client, server := newProcessorPair(t, func(p Processor) {
+		p.RegisterHandler("event", func(ctx Context) error {
+			received <- "server"
+			return nil
+		})
+	})
+	client.RegisterHandler("event", func(ctx Context) error {
+		received <- "client"
+		return nil
+	})
+
+	// 没有对应请求的错误帧只记录日志，不调用处理器
+	require.NoError(t, server.SendError(0, "event", NewProtocolError(CodeUnauthenticated, "no")))
+	require.NoError(t, client.Send("event", "x"))
+	select {
+	case who := <-received:
+		assert.Equal(t, "server", who)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+	select {
+	case who := <-received:
+		t.Fatalf("Unexpected dispatch to %s", who)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestProtocolError_Message(t *testing.T) {
+	err := NewProtocolError(CodeUnauthenticated, "token expired")
+	assert.Equal(t, "protocol error: unauthenticated: token expired", err.Error())
+	assert.Equal(t, "protocol error: code 99", NewProtocolError(99, "").Error())
+
+	// 过长的错误信息被截断，保证能放入TLV
+	long := NewProtocolError(CodeBadRequest, strings.Repeat("x", 70000))
+	decoded := protocolErrorOf(nil)
+	assert.Nil(t, decoded)
+	tlv := errorTLV(long)
+	assert.Equal(t, int(tlv.Length), len(tlv.Value))
+	decoded = protocolErrorOf([]codec.TLV{tlv})
+	require.NotNil(t, decoded)
+	assert.Equal(t, CodeBadRequest, decoded.Code)
+	assert.Len(t, decoded.Message, 0xFFFF-2)
+}
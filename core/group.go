@@ -0,0 +1,42 @@
+package core
+
+import "slices"
+
+// Group 处理器分组
+// 组内注册的消息类型为 前缀.类型，组中间件只作用于组内（包括子分组）的处理器
+type Group interface {
+	// Use 注册组中间件，对之前和之后注册的组内处理器都生效
+	Use(middleware Middleware)
+	// RegisterHandler 注册组内的消息处理器，middlewares 只作用于该处理器
+	RegisterHandler(msgType string, handler Handler, middlewares ...Middleware)
+	// Group 创建子分组，前缀为 当前前缀.prefix
+	Group(prefix string, middlewares ...Middleware) Group
+}
+
+// handlerGroup 分组实现，中间件列表由处理器的锁保护
+type handlerGroup struct {
+	processor   *processor
+	parent      *handlerGroup
+	prefix      string
+	middlewares []Middleware
+}
+
+func (g *handlerGroup) Use(middleware Middleware) {
+	g.processor.mutex.Lock()
+	defer g.processor.mutex.Unlock()
+	g.middlewares = append(g.middlewares, middleware)
+	g.processor.router.rebuild(g.processor.middlewares)
+}
+
+func (g *handlerGroup) RegisterHandler(msgType string, handler Handler, middlewares ...Middleware) {
+	g.processor.register(g.prefix+"."+msgType, handler, middlewares, g)
+}
+
+func (g *handlerGroup) Group(prefix string, middlewares ...Middleware) Group {
+	return &handlerGroup{
+		processor:   g.processor,
+		parent:      g,
+		prefix:      g.prefix + "." + prefix,
+		middlewares: slices.Clone(middlewares),
+	}
+}
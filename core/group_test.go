@@ -0,0 +1,102 @@
+package core
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/BadKid90s/chilix-msg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// traceRecorder 记录中间件和处理器的执行顺序
+type traceRecorder struct {
+	mu    sync.Mutex
+	trace []string
+}
+
+func (r *traceRecorder) add(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trace = append(r.trace, name)
+}
+
+func (r *traceRecorder) middleware(name string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx Context) error {
+			r.add(name)
+			return next(ctx)
+		}
+	}
+}
+
+func (r *traceRecorder) handler(name string) Handler {
+	return func(ctx Context) error {
+		r.add(name)
+		return nil
+	}
+}
+
+// dispatch 直接分发一条消息并返回执行顺序
+func (r *traceRecorder) dispatch(t *testing.T, p *processor, msgType string) []string {
+	t.Helper()
+	r.mu.Lock()
+	r.trace = nil
+	r.mu.Unlock()
+	require.NoError(t, p.dispatchMessage(msgType, &processorContext{msgType: msgType, processor: p, logger: p.logger}))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.trace
+}
+
+func TestProcessor_MiddlewareOrder(t *testing.T) {
+	p := newProcessor(nil, ProcessorConfig{Logger: log.NewDefaultLogger()})
+	r := &traceRecorder{}
+
+	// 中间件在处理器之后注册同样生效
+	p.RegisterHandler("ping", r.handler("ping"), r.middleware("ping-mw"))
+	p.Use(r.middleware("global-1"))
+	assert.Equal(t, []string{"global-1", "ping-mw", "ping"}, r.dispatch(t, p, "ping"))
+
+	orders := p.Group("orders", r.middleware("orders"))
+	orders.RegisterHandler("create", r.handler("create"))
+	items := orders.Group("items")
+	items.RegisterHandler("add", r.handler("add"), r.middleware("add-mw"))
+	items.Use(r.middleware("items"))
+	p.Use(r.middleware("global-2"))
+
+	_, exists := p.typeRegistry.GetID("orders.items.add")
+	assert.True(t, exists)
+	assert.Equal(t, []string{"global-1", "global-2", "orders", "create"}, r.dispatch(t, p, "orders.create"))
+	assert.Equal(t, []string{"global-1", "global-2", "orders", "items", "add-mw", "add"}, r.dispatch(t, p, "orders.items.add"))
+	assert.Equal(t, []string{"global-1", "global-2", "ping-mw", "ping"}, r.dispatch(t, p, "ping"))
+
+	assert.ErrorIs(t, p.dispatchMessage("orders.missing", &processorContext{}), ErrHandlerNotFound)
+}
+
+// 测试处理器链在注册和 Use 时组合，分发时不再调用中间件工厂
+func TestProcessor_ChainCached(t *testing.T) {
+	p := newProcessor(nil, ProcessorConfig{Logger: log.NewDefaultLogger()})
+	r := &traceRecorder{}
+	var built int
+	counting := func(next Handler) Handler {
+		built++
+		return next
+	}
+
+	p.Use(counting)
+	p.RegisterHandler("ping", r.handler("ping"))
+	p.Group("orders").RegisterHandler("create", r.handler("create"))
+	built = 0
+	for range 3 {
+		r.dispatch(t, p, "ping")
+		r.dispatch(t, p, "orders.create")
+	}
+	assert.Zero(t, built)
+
+	// 新增的中间件重建已有路由的处理器链
+	p.Use(r.middleware("global"))
+	assert.Equal(t, []string{"global", "ping"}, r.dispatch(t, p, "ping"))
+	p.SetNotFound(r.handler("not-found"))
+	assert.Equal(t, []string{"global", "not-found"}, r.dispatch(t, p, "missing"))
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/BadKid90s/chilix-msg/codec"
+)
+
+// ErrReservedMetadata 元数据使用了编解码器内部的TLV类型
+var ErrReservedMetadata = errors.New("metadata uses a reserved TLV type")
+
+// OutboundKind 出站消息的种类
+type OutboundKind uint8
+
+const (
+	// OutboundSend Processor.Send 发送的推送消息
+	OutboundSend OutboundKind = iota
+	// OutboundRequest Processor.Request 发送的请求，next 返回时已经收到响应或超时
+	OutboundRequest
+	// OutboundReply Processor.Reply 发送的响应
+	OutboundReply
+	// OutboundError Processor.SendError 发送的协议错误帧
+	OutboundError
+)
+
+var outboundKindNames = map[OutboundKind]string{
+	OutboundSend:    "send",
+	OutboundRequest: "request",
+	OutboundReply:   "reply",
+	OutboundError:   "error",
+}
+
+func (k OutboundKind) String() string {
+	if name, ok := outboundKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("kind %d", uint8(k))
+}
+
+// OutboundMessage 出站消息，出站中间件可以读取和修改
+type OutboundMessage struct {
+	Kind    OutboundKind
+	MsgType string
+	// RequestID Reply 和 SendError 为对应请求的ID；Request 每次调用 next 时分配新的ID，next 返回后可以读取
+	RequestID uint64
+	Payload   interface{}
+	// Metadata 随帧发送的扩展字段，对端通过 Context.Metadata 或 Response.Metadata 读取
+	Metadata []codec.TLV
+	// Error SendError 发送的协议错误
+	Error *ProtocolError
+	// Response Request 收到的响应，next 成功返回后设置
+	Response Response
+	// Done 处理器关闭时关闭，出站中间件等待时（例如重试的退避）应同时监听
+	Done <-chan struct{}
+}
+
+// SetMetadata 设置元数据，替换已有的同类型元数据
+func (m *OutboundMessage) SetMetadata(tlvType uint8, value []byte) {
+	m.Metadata = slices.DeleteFunc(m.Metadata, func(tlv codec.TLV) bool { return tlv.Type == tlvType })
+	m.Metadata = append(m.Metadata, codec.TLV{Type: tlvType, Length: uint16(len(value)), Value: value})
+}
+
+// OutboundHandler 出站处理函数，链的末端负责编码和发送
+type OutboundHandler func(msg *OutboundMessage) error
+
+// OutboundMiddleware 出站中间件类型，与 Middleware 对应，包装 Send、Request、Reply 和 SendError
+type OutboundMiddleware func(OutboundHandler) OutboundHandler
+
+// MetadataValue 查找指定类型的元数据
+func MetadataValue(metadata []codec.TLV, tlvType uint8) ([]byte, bool) {
+	for _, tlv := range metadata {
+		if tlv.Type == tlvType {
+			return tlv.Value, true
+		}
+	}
+	return nil, false
+}
+
+// checkMetadata 元数据不能为空，也不能使用编解码器和协议错误占用的类型
+func checkMetadata(metadata []codec.TLV) error {
+	for _, tlv := range metadata {
+		switch tlv.Type {
+		case codec.TLVTypeError, codec.TLVTypeSequence, codec.TLVTypeKeyID, codec.TLVTypeSignature, codec.TLVTypeName:
+			return fmt.Errorf("%w: %d", ErrReservedMetadata, tlv.Type)
+		}
+		if len(tlv.Value) == 0 || len(tlv.Value) > 0xFFFF || int(tlv.Length) != len(tlv.Value) {
+			return fmt.Errorf("%w: invalid metadata %d", codec.ErrInvalidMessageFormat, tlv.Type)
+		}
+	}
+	return nil
+}
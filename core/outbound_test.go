@@ -0,0 +1,158 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/BadKid90s/chilix-msg/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	traceMetadata  uint8 = 0x20
+	serverMetadata uint8 = 0x21
+)
+
+// outboundRecorder 记录经过出站中间件的消息
+type outboundRecorder struct {
+	mu   sync.Mutex
+	seen []string
+}
+
+func (r *outboundRecorder) middleware(name string) OutboundMiddleware {
+	return func(next OutboundHandler) OutboundHandler {
+		return func(msg *OutboundMessage) error {
+			r.mu.Lock()
+			r.seen = append(r.seen, name+":"+msg.Kind.String()+":"+msg.MsgType)
+			r.mu.Unlock()
+			return next(msg)
+		}
+	}
+}
+
+func (r *outboundRecorder) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.seen...)
+}
+
+func TestProcessor_UseOutbound(t *testing.T) {
+	serverRecorder := &outboundRecorder{}
+	metadata := make(chan string, 1)
+	client, _ := newProcessorPair(t, func(server Processor) {
+		server.UseOutbound(serverRecorder.middleware("server"))
+		server.UseOutbound(func(next OutboundHandler) OutboundHandler {
+			return func(msg *OutboundMessage) error {
+				msg.SetMetadata(serverMetadata, []byte("v1"))
+				return next(msg)
+			}
+		})
+		server.RegisterHandler("echo", func(ctx Context) error {
+			trace, _ := MetadataValue(ctx.Metadata(), traceMetadata)
+			metadata <- string(trace)
+			if err := ctx.Processor().Send("notice", "pushed"); err != nil {
+				return err
+			}
+			return ctx.Reply("pong")
+		})
+		server.RegisterHandler("fail", func(ctx Context) error {
+			return ctx.ReplyError(CodeBadRequest, "bad")
+		})
+	})
+	client.RegisterHandler("notice", func(ctx Context) error { return nil })
+
+	// 先注册的中间件在外层
+	clientRecorder := &outboundRecorder{}
+	client.UseOutbound(clientRecorder.middleware("outer"))
+	client.UseOutbound(func(next OutboundHandler) OutboundHandler {
+		return func(msg *OutboundMessage) error {
+			msg.SetMetadata(traceMetadata, []byte("trace-1"))
+			err := next(msg)
+			if msg.Kind == OutboundRequest && err == nil {
+				assert.NotZero(t, msg.RequestID)
+				assert.NotNil(t, msg.Response)
+			}
+			return err
+		}
+	})
+	client.UseOutbound(clientRecorder.middleware("inner"))
+
+	resp, err := client.Request("echo", "ping")
+	require.NoError(t, err)
+	assert.Equal(t, "trace-1", <-metadata)
+	value, ok := MetadataValue(resp.Metadata(), serverMetadata)
+	assert.True(t, ok)
+	assert.Equal(t, "v1", string(value))
+
+	_, err = client.Request("fail", nil)
+	assert.True(t, errors.Is(err, NewProtocolError(CodeBadRequest, "")))
+
+	assert.Equal(t, []string{
+		"outer:request:echo", "inner:request:echo",
+		"outer:request:fail", "inner:request:fail",
+	}, clientRecorder.list())
+	assert.ElementsMatch(t, []string{"server:send:notice", "server:reply:echo", "server:error:fail"}, serverRecorder.list())
+}
+
+func TestProcessor_UseOutboundRetry(t *testing.T) {
+	var attempts atomic.Int32
+	client, _ := newProcessorPair(t, func(server Processor) {
+		server.RegisterHandler("flaky", func(ctx Context) error {
+			// 第一次请求不响应
+			if attempts.Add(1) == 1 {
+				return nil
+			}
+			return ctx.Reply("ok")
+		})
+	})
+
+	var requestIDs []uint64
+	client.UseOutbound(func(next OutboundHandler) OutboundHandler {
+		return func(msg *OutboundMessage) error {
+			err := next(msg)
+			requestIDs = append(requestIDs, msg.RequestID)
+			if msg.Kind == OutboundRequest && errors.Is(err, ErrRequestTimeout) {
+				err = next(msg)
+				requestIDs = append(requestIDs, msg.RequestID)
+			}
+			return err
+		}
+	})
+
+	resp, err := client.Request("flaky", nil)
+	require.NoError(t, err)
+	var reply string
+	require.NoError(t, resp.Bind(&reply))
+	assert.Equal(t, "ok", reply)
+	require.Len(t, requestIDs, 2)
+	assert.NotEqual(t, requestIDs[0], requestIDs[1], "each attempt uses a new request ID")
+}
+
+func TestProcessor_UseOutboundReject(t *testing.T) {
+	client, _ := newProcessorPair(t, func(server Processor) {})
+
+	errBlocked := errors.New("blocked")
+	client.UseOutbound(func(next OutboundHandler) OutboundHandler {
+		return func(msg *OutboundMessage) error {
+			if msg.MsgType == "blocked" {
+				return errBlocked
+			}
+			return next(msg)
+		}
+	})
+	assert.ErrorIs(t, client.Send("blocked", nil), errBlocked)
+	_, err := client.Request("blocked", nil)
+	assert.ErrorIs(t, err, errBlocked)
+
+	// 编解码器内部使用的TLV类型不能作为元数据
+	client.UseOutbound(func(next OutboundHandler) OutboundHandler {
+		return func(msg *OutboundMessage) error {
+			msg.SetMetadata(codec.TLVTypeError, []byte{0, 1})
+			return next(msg)
+		}
+	})
+	assert.ErrorIs(t, client.Send("other", nil), ErrReservedMetadata)
+}
@@ -0,0 +1,29 @@
+package core
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError 处理器panic转换成的错误，带panic时的调用栈
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+// NewPanicError 为恢复的panic值创建 PanicError 并记录当前调用栈，在恢复panic的defer函数中调用
+func NewPanicError(value interface{}) *PanicError {
+	return &PanicError{Value: value, Stack: debug.Stack()}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("handler panic: %v", e.Value)
+}
+
+// PanicHandler 处理器panic后的回调，在记录日志和回复错误帧之后调用，可用于上报指标和告警
+type PanicHandler func(ctx Context, err *PanicError)
+
+// Stats 处理器运行指标
+type Stats struct {
+	Panics uint64 // 处理器panic次数
+}
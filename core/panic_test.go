@@ -0,0 +1,53 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessor_PanicRecovery(t *testing.T) {
+	panics := make(chan *PanicError, 2)
+	client, server := newProcessorPair(t, func(server Processor) {
+		server.(*processor).config.PanicHandler = func(ctx Context, err *PanicError) {
+			panics <- err
+			panic("panic handler must not crash either")
+		}
+		server.RegisterHandler("boom", func(ctx Context) error {
+			panic("boom")
+		})
+		server.RegisterHandler("echo", func(ctx Context) error {
+			return ctx.Reply("ok")
+		})
+	})
+
+	// 请求方立即收到内部错误，panic内容不发送给对端
+	_, err := client.Request("boom", nil)
+	assert.True(t, errors.Is(err, ErrInternal), "got %v", err)
+	var protoErr *ProtocolError
+	require.True(t, errors.As(err, &protoErr))
+	assert.NotContains(t, protoErr.Message, "boom")
+
+	select {
+	case panicErr := <-panics:
+		assert.Equal(t, "boom", panicErr.Value)
+		assert.Contains(t, string(panicErr.Stack), "panic_test.go")
+	case <-time.After(time.Second):
+		t.Fatal("PanicHandler was not called")
+	}
+
+	// 推送消息的panic同样被恢复
+	require.NoError(t, client.Send("boom", nil))
+	<-panics
+	assert.Equal(t, uint64(2), server.Stats().Panics)
+
+	// 连接仍然可用
+	resp, err := client.Request("echo", nil)
+	require.NoError(t, err)
+	var reply string
+	require.NoError(t, resp.Bind(&reply))
+	assert.Equal(t, "ok", reply)
+}
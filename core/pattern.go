@@ -0,0 +1,113 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidPattern 消息类型模式格式错误
+var ErrInvalidPattern = errors.New("invalid message type pattern")
+
+// Pattern 消息类型模式，消息类型按 "." 分段
+//
+// 示例:
+//
+//	orders.create    精确匹配
+//	orders.*.get     * 匹配任意一段
+//	orders.**        前缀匹配，** 只能作为最后一段，匹配 orders 之后的一段或多段
+//	**               匹配所有类型
+type Pattern struct {
+	raw      string
+	segments []string
+	prefix   bool
+	literal  bool // 按原样精确匹配，不解析通配符
+}
+
+// ParsePattern 解析消息类型模式
+func ParsePattern(pattern string) (Pattern, error) {
+	if pattern == "" {
+		return Pattern{}, fmt.Errorf("%w: empty pattern", ErrInvalidPattern)
+	}
+	segments := strings.Split(pattern, ".")
+	p := Pattern{raw: pattern}
+	for i, seg := range segments {
+		switch {
+		case seg == "":
+			return Pattern{}, fmt.Errorf("%w: empty segment in %q", ErrInvalidPattern, pattern)
+		case seg == "**":
+			if i != len(segments)-1 {
+				return Pattern{}, fmt.Errorf("%w: ** must be the last segment in %q", ErrInvalidPattern, pattern)
+			}
+			p.prefix = true
+		case seg != "*" && strings.Contains(seg, "*"):
+			return Pattern{}, fmt.Errorf("%w: partial wildcard in %q", ErrInvalidPattern, pattern)
+		default:
+			p.segments = append(p.segments, seg)
+		}
+	}
+	return p, nil
+}
+
+// literalPattern 按原样精确匹配的模式，用于不符合模式语法的消息类型，例如 "a..b"、"v1*" 或空类型
+func literalPattern(msgType string) Pattern {
+	return Pattern{raw: msgType, segments: []string{msgType}, literal: true}
+}
+
+// MustParsePattern 解析消息类型模式，格式错误时panic
+func MustParsePattern(pattern string) Pattern {
+	p, err := ParsePattern(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Match 判断消息类型是否匹配
+func (p Pattern) Match(msgType string) bool {
+	if p.literal {
+		return msgType == p.raw
+	}
+	parts := strings.Split(msgType, ".")
+	if p.prefix {
+		// ** 至少匹配一段
+		if len(parts) <= len(p.segments) {
+			return false
+		}
+	} else if len(parts) != len(p.segments) {
+		return false
+	}
+	for i, seg := range p.segments {
+		if seg != "*" && seg != parts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// IsExact 判断模式是否不含通配符
+func (p Pattern) IsExact() bool {
+	return p.literal || !p.prefix && !strings.Contains(p.raw, "*")
+}
+
+// Specificity 模式的具体程度，用于在多个模式同时匹配时选择最具体的一个
+// 精确模式最高，其次是字面段更多、通配段更少的模式，前缀模式最低
+func (p Pattern) Specificity() int {
+	score := 0
+	for _, seg := range p.segments {
+		if seg == "*" {
+			score += 1
+		} else {
+			score += 3
+		}
+	}
+	score *= 2
+	if !p.prefix {
+		score++
+	}
+	return score
+}
+
+func (p Pattern) String() string {
+	return p.raw
+}
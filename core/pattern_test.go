@@ -0,0 +1,59 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPattern_Match(t *testing.T) {
+	tests := []struct {
+		pattern string
+		msgType string
+		match   bool
+	}{
+		{"get_user", "get_user", true},
+		{"get_user", "get_users", false},
+		{"orders.create", "orders.create", true},
+		{"orders.create", "orders", false},
+		{"orders.*", "orders.create", true},
+		{"orders.*", "orders.create.v2", false},
+		{"orders.*", "orders", false},
+		{"*.get", "users.get", true},
+		{"orders.*.get", "orders.123.get", true},
+		{"orders.**", "orders.create", true},
+		{"orders.**", "orders.items.add", true},
+		{"orders.**", "orders", false},
+		{"orders.**", "ordersx.create", false},
+		{"**", "anything.at.all", true},
+		{"*", "get_user", true},
+		{"*", "a.b", false},
+	}
+	for _, tt := range tests {
+		p, err := ParsePattern(tt.pattern)
+		require.NoError(t, err)
+		assert.Equal(t, tt.match, p.Match(tt.msgType), "%s ~ %s", tt.pattern, tt.msgType)
+	}
+}
+
+func TestParsePattern_Invalid(t *testing.T) {
+	for _, pattern := range []string{"", "orders..create", "orders.**.get", "order*", ".orders"} {
+		_, err := ParsePattern(pattern)
+		assert.ErrorIs(t, err, ErrInvalidPattern, pattern)
+	}
+	assert.Panics(t, func() { MustParsePattern("a..b") })
+}
+
+func TestPattern_Specificity(t *testing.T) {
+	exact := MustParsePattern("orders.create")
+	wildcard := MustParsePattern("orders.*")
+	prefix := MustParsePattern("orders.**")
+	all := MustParsePattern("**")
+
+	assert.True(t, exact.IsExact())
+	assert.False(t, wildcard.IsExact())
+	assert.Greater(t, exact.Specificity(), wildcard.Specificity())
+	assert.Greater(t, wildcard.Specificity(), prefix.Specificity())
+	assert.Greater(t, prefix.Specificity(), all.Specificity())
+}
@@ -3,6 +3,7 @@ package core
 import (
 	"time"
 
+	"github.com/BadKid90s/chilix-msg/codec"
 	"github.com/BadKid90s/chilix-msg/log"
 	"github.com/BadKid90s/chilix-msg/serializer"
 	"github.com/BadKid90s/chilix-msg/transport"
@@ -16,10 +17,20 @@ type Handler func(ctx Context) error
 
 // Processor 消息处理器接口 - 用户面向的简洁接口
 type Processor interface {
-	// RegisterHandler 消息处理
-	RegisterHandler(msgType string, handler Handler)
-	// Use 中间件
+	// RegisterHandler 消息处理，msgType 可以是 Pattern 模式（orders.*.get、orders.**），middlewares 只作用于该处理器
+	// 精确类型优先，其次按模式的具体程度匹配
+	RegisterHandler(msgType string, handler Handler, middlewares ...Middleware)
+	// Group 处理器分组，组内的消息类型带 prefix 前缀，middlewares 只作用于组内的处理器
+	Group(prefix string, middlewares ...Middleware) Group
+	// SetNotFound 没有匹配路由时的处理器，默认 NotFoundHandler，nil表示只记录日志
+	SetNotFound(handler Handler)
+	// Routes 已注册的路由
+	Routes() []RouteInfo
+	// Use 全局中间件，对之前和之后注册的处理器都生效，处理器链在注册时组合并缓存
+	// 执行顺序: 全局中间件 -> 分组中间件（外层到内层）-> 处理器中间件 -> 处理器
 	Use(middleware Middleware)
+	// UseOutbound 出站中间件，包装 Send、Request、Reply 和 SendError 发出的每一条消息
+	UseOutbound(middleware OutboundMiddleware)
 
 	// Send 消息发送
 	Send(msgType string, payload interface{}) error
@@ -27,6 +38,25 @@ type Processor interface {
 	Request(msgType string, payload interface{}) (Response, error)
 	// Reply 回复消息
 	Reply(requestID uint64, msgType string, payload interface{}) error
+	// SendError 发送协议错误帧，对端的 Request 会返回该错误
+	SendError(requestID uint64, msgType string, err *ProtocolError) error
+
+	// Session 连接会话，保存认证身份等连接级别的状态
+	Session() Session
+
+	// SetEncryptor 启用或替换收发管道的加密器，对所有出站消息生效，nil表示关闭加密
+	// 双方需要在同一时刻切换，例如在应用层握手完成之后
+	SetEncryptor(encryptor codec.Encryptor)
+	// Encryptor 收发管道的加密器，未启用加密时返回nil
+	Encryptor() codec.Encryptor
+	// SetSigner 启用或替换收发管道的签名器，nil表示关闭签名
+	// required 为 true 时丢弃未签名的帧
+	SetSigner(signer codec.Signer, required bool)
+	// Signer 收发管道的签名器，未启用签名时返回nil
+	Signer() codec.Signer
+
+	// Stats 运行指标
+	Stats() Stats
 
 	// Listen 生命周期管理
 	Listen() error
@@ -45,6 +75,16 @@ type ProcessorConfig struct {
 	MessageSizeLimit int64                 // 消息大小限制（字节）
 	RequestTimeout   time.Duration         // 请求超时时间
 	Logger           log.Logger            // 日志记录器
+	// Encryptor 会话加密器，例如 codec.AESEncryptor、codec.KeyRing 或 handshake.Client/Server 的结果
+	// 设置后所有消息帧在序列化之后加密发送，收到的未加密帧被丢弃
+	Encryptor codec.Encryptor
+	// Signer 帧签名器，例如 codec.HMACSigner 或 codec.Ed25519Signer
+	// 设置后所有消息帧在加密之后签名发送，收到的带签名帧校验失败时被丢弃
+	Signer codec.Signer
+	// RequireSignature 设置 Signer 时丢弃未签名的帧，关闭时接受未签名的帧，便于双方逐步启用签名
+	RequireSignature bool
+	// PanicHandler 处理器panic后的回调。panic总是被恢复、带调用栈记录到日志，请求方收到 CodeInternal 错误帧
+	PanicHandler PanicHandler
 }
 
 // NewProcessor 创建新的消息处理器
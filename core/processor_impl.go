@@ -1,12 +1,17 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net"
+	"runtime/debug"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/BadKid90s/chilix-msg/codec"
@@ -18,14 +23,28 @@ import (
 var (
 	ErrRequestTimeout  = errors.New("request timeout")
 	ErrHandlerNotFound = errors.New("no handler for message type")
+	// ErrDatagramRequest 数据报连接不可靠，不支持请求-响应，需要时由应用层自行重试
+	ErrDatagramRequest = errors.New("request is not supported on datagram connections")
+	// ErrInvalidDatagram 数据报不是完整的消息帧
+	ErrInvalidDatagram = errors.New("invalid datagram")
+	// ErrUnencryptedFrame 配置了加密器时收到未加密的消息帧
+	ErrUnencryptedFrame = errors.New("unencrypted frame on encrypted session")
 )
 
+// maxLearnedTypes 从对端帧中学习并记录的类型名称上限
+const maxLearnedTypes = 1024
+
 // processor 内部实现，不对外暴露
 type processor struct {
 	conn         transport.Connection
-	codec        codec.Codec
-	handlers     map[string]Handler
+	packetConn   transport.PacketConn // 数据报连接，流式连接时为nil
+	codec        *codec.BalancedCodec
+	router       *router
+	announced    sync.Map // 已在连接上发送过名称的类型ID
+	learned      atomic.Int32
+	panics       atomic.Uint64
 	middlewares  []Middleware
+	outbound     []OutboundMiddleware
 	typeRegistry *Registry
 	requestMgr   *RequestManager
 	config       ProcessorConfig
@@ -33,6 +52,7 @@ type processor struct {
 	cancel       context.CancelFunc
 	logger       log.Logger
 	serializer   serializer.Serializer
+	session      *session
 	mutex        sync.RWMutex
 }
 
@@ -47,12 +67,17 @@ func newProcessor(conn transport.Connection, config ProcessorConfig) *processor
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
+	packetConn, _ := transport.As[transport.PacketConn](conn)
+	frameCodec := codec.NewBalancedCodecWithEncryption(config.Serializer, config.Encryptor)
+	frameCodec.SetSigner(config.Signer, config.RequireSignature)
 
 	return &processor{
 		conn:         conn,
-		codec:        codec.NewBalancedCodec(config.Serializer),
-		handlers:     make(map[string]Handler),
+		packetConn:   packetConn,
+		codec:        frameCodec,
+		router:       newRouter(),
 		middlewares:  make([]Middleware, 0),
+		outbound:     make([]OutboundMiddleware, 0),
 		typeRegistry: NewRegistry(),
 		requestMgr:   NewRequestManager(config.RequestTimeout),
 		config:       config,
@@ -60,44 +85,90 @@ func newProcessor(conn transport.Connection, config ProcessorConfig) *processor
 		cancel:       cancel,
 		logger:       config.Logger,
 		serializer:   config.Serializer,
+		session:      newSession(),
 	}
 }
 
 // RegisterHandler 注册消息处理器
-func (p *processor) RegisterHandler(msgType string, handler Handler) {
+func (p *processor) RegisterHandler(msgType string, handler Handler, middlewares ...Middleware) {
+	p.register(msgType, handler, middlewares, nil)
+}
+
+// Group 创建处理器分组
+func (p *processor) Group(prefix string, middlewares ...Middleware) Group {
+	return &handlerGroup{processor: p, prefix: prefix, middlewares: slices.Clone(middlewares)}
+}
+
+// register 注册处理器并组合处理器链，之后注册的中间件通过 rebuild 同样生效
+// msgType 可以是 Pattern 模式，模式不注册类型ID，对端首次发送某类型时会附带类型名称
+func (p *processor) register(msgType string, handler Handler, middlewares []Middleware, group *handlerGroup) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
-	// 注册类型到注册器
-	if _, err := p.typeRegistry.Register(msgType); err != nil {
-		p.logger.Fatalf("Failed to register message type '%s': %v", msgType, err)
+	pattern, err := ParsePattern(msgType)
+	if err != nil {
+		// 模式语法之前的消息类型名称可能不符合模式语法，按原样精确匹配
+		p.logger.Warnf("Message type '%s' is not a valid pattern, registering it as an exact type: %v", msgType, err)
+		pattern = literalPattern(msgType)
 	}
 
-	// 应用中间件
-	for i := len(p.middlewares) - 1; i >= 0; i-- {
-		handler = p.middlewares[i](handler)
+	// 注册类型到注册器
+	if pattern.IsExact() {
+		if _, err := p.typeRegistry.Register(msgType); err != nil {
+			p.logger.Fatalf("Failed to register message type '%s': %v", msgType, err)
+		}
 	}
 
 	// 注册处理器函数
-	p.handlers[msgType] = handler
+	rt := &route{pattern: pattern, handler: handler, middlewares: slices.Clone(middlewares), group: group}
+	rt.chained = rt.chain(p.middlewares)
+	p.router.add(rt)
 }
 
-// Use 注册中间件
+// SetNotFound 设置没有匹配路由时的处理器，nil表示只返回 ErrHandlerNotFound
+func (p *processor) SetNotFound(handler Handler) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.router.notFound = handler
+	p.router.rebuild(p.middlewares)
+}
+
+// Routes 返回已注册的路由
+func (p *processor) Routes() []RouteInfo {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.router.routes()
+}
+
+// Use 注册全局中间件，对之前和之后注册的处理器都生效
 func (p *processor) Use(middleware Middleware) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 	p.middlewares = append(p.middlewares, middleware)
+	p.router.rebuild(p.middlewares)
+}
+
+// UseOutbound 注册出站中间件
+func (p *processor) UseOutbound(middleware OutboundMiddleware) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.outbound = append(p.outbound, middleware)
 }
 
 // Listen 开始监听和处理消息
 func (p *processor) Listen() error {
+	var buf []byte
+	if p.packetConn != nil {
+		buf = make([]byte, transport.MaxDatagramSize)
+	}
+
 	for {
 		select {
 		case <-p.ctx.Done():
 			return nil
 		default:
 			// 读取消息
-			msgTypeID, rawData, requestID, err := p.codec.Decode(p.conn)
+			msgTypeID, rawData, requestID, signed, extensions, err := p.readMessage(buf)
 			if err != nil {
 				p.logger.Errorf("Failed to decode message: %v", err)
 				// 根据错误类型决定是否继续监听
@@ -108,7 +179,15 @@ func (p *processor) Listen() error {
 			}
 
 			// 将类型ID转换为类型字符串
-			msgType, exists := p.typeRegistry.GetName(msgTypeID)
+			msgType, exists := p.resolveType(msgTypeID, extensions)
+			extensions = slices.DeleteFunc(extensions, func(tlv codec.TLV) bool { return tlv.Type == codec.TLVTypeName })
+
+			// 协议错误帧不分发给处理器
+			if protoErr := protocolErrorOf(extensions); protoErr != nil {
+				p.handleProtocolError(msgType, requestID, protoErr)
+				continue
+			}
+
 			if !exists {
 				p.logger.Errorf("Unknown message type ID: %d", msgTypeID)
 				continue
@@ -128,6 +207,7 @@ func (p *processor) Listen() error {
 						msgType:   msgType,
 						requestID: requestID,
 						rawData:   rawData,
+						metadata:  extensions,
 						processor: p,
 					}
 					ch <- response
@@ -142,29 +222,136 @@ func (p *processor) Listen() error {
 				requestID:  requestID,
 				connection: p.conn,
 				rawData:    rawData,
+				metadata:   extensions,
+				signed:     signed,
 				processor:  p,
 				writer:     NewMessageWriter(p),
 				logger:     p.logger,
 			}
 
 			// 处理消息
-			go func() {
-				p.logger.Debugf("Dispatching message: msgType=%s, requestID=%d", msgType, requestID)
-				if err := p.dispatchMessage(msgType, ctx); err != nil {
-					p.logger.Errorf("Error processing message %s: %v", msgType, err)
-				}
-			}()
+			go p.handleMessage(ctx)
+		}
+	}
+}
+
+// readMessage 读取一条消息，signed 表示帧签名已校验
+// 数据报连接每次读取一个完整数据报并从中解码一帧，损坏的数据报被丢弃而不影响后续读取
+func (p *processor) readMessage(buf []byte) (uint32, []byte, uint64, bool, []codec.TLV, error) {
+	var r io.Reader = p.conn
+	if p.packetConn != nil {
+		n, err := p.packetConn.Read(buf)
+		if err != nil {
+			return 0, nil, 0, false, nil, err
 		}
+		r = bytes.NewReader(buf[:n])
 	}
+
+	msgTypeID, rawData, requestID, flags, extensions, err := p.codec.DecodeWithFlags(r)
+	if err != nil {
+		if p.packetConn != nil {
+			return 0, nil, 0, false, nil, fmt.Errorf("%w: %v", ErrInvalidDatagram, err)
+		}
+		return 0, nil, 0, false, nil, err
+	}
+	// 加密会话中的明文帧可能是伪造的，整帧已读取完毕，丢弃后可以继续读取
+	if p.codec.Encryptor() != nil && flags&codec.BalancedFlagEncrypted == 0 {
+		return 0, nil, 0, false, nil, ErrUnencryptedFrame
+	}
+	return msgTypeID, rawData, requestID, flags&codec.BalancedFlagSigned != 0, extensions, nil
+}
+
+// writeMessage 编码并发送一帧，负载序列化一次，启用加密时在组帧前加密，启用签名时对加密后的帧签名
+// Send、Request、Reply 和 SendError 都经过这里
+func (p *processor) writeMessage(msgTypeID uint32, payload interface{}, requestID uint64, extensions []codec.TLV) error {
+	var flags uint8 = codec.BalancedFlagNone
+	if p.codec.Encryptor() != nil {
+		flags |= codec.BalancedFlagEncrypted
+	}
+	if err := p.codec.EncodeWithFlags(p.conn, msgTypeID, payload, requestID, flags, extensions); err != nil {
+		return err
+	}
+	// 类型名称发送成功后，之后的帧不再附带
+	if _, ok := MetadataValue(extensions, codec.TLVTypeName); ok {
+		p.announced.Store(msgTypeID, struct{}{})
+	}
+	return nil
+}
+
+// handleProtocolError 处理对端发送的错误帧
+// 有等待中的请求时作为该请求的结果返回，否则只记录日志
+func (p *processor) handleProtocolError(msgType string, requestID uint64, protoErr *ProtocolError) {
+	if requestID > 0 {
+		if ch, ok := p.requestMgr.IsPending(requestID); ok {
+			ch <- &response{msgType: msgType, requestID: requestID, processor: p, err: protoErr}
+			p.requestMgr.CancelRequest(requestID)
+			return
+		}
+	}
+	p.logger.Warnf("Received %v for message %s", protoErr, msgType)
+}
+
+// handleMessage 在独立的goroutine中处理一条消息
+// 处理器的panic被恢复并转换为 *PanicError，不会影响其他消息、连接和进程
+func (p *processor) handleMessage(ctx *processorContext) {
+	p.logger.Debugf("Dispatching message: msgType=%s, requestID=%d", ctx.msgType, ctx.requestID)
+	err := p.safeDispatch(ctx)
+	var panicErr *PanicError
+	switch {
+	case errors.As(err, &panicErr):
+		p.handlePanic(ctx, panicErr)
+	case err != nil:
+		p.logger.Errorf("Error processing message %s: %v", ctx.msgType, err)
+	}
+}
+
+// safeDispatch 分发消息并恢复panic
+func (p *processor) safeDispatch(ctx *processorContext) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = NewPanicError(r)
+		}
+	}()
+	return p.dispatchMessage(ctx.msgType, ctx)
+}
+
+// handlePanic 记录panic，请求方收到 CodeInternal 错误帧，然后调用 PanicHandler
+// panic的内容只写入日志，不发送给对端
+func (p *processor) handlePanic(ctx Context, panicErr *PanicError) {
+	p.panics.Add(1)
+	p.logger.Errorf("Panic in handler %s (requestID=%d): %v\n%s", ctx.MessageType(), ctx.RequestID(), panicErr.Value, panicErr.Stack)
+
+	if ctx.IsRequest() {
+		if err := p.SendError(ctx.RequestID(), ctx.MessageType(), NewProtocolError(CodeInternal, "internal error")); err != nil {
+			p.logger.Errorf("Failed to send internal error for %s: %v", ctx.MessageType(), err)
+		}
+	}
+
+	if p.config.PanicHandler != nil {
+		defer func() {
+			if r := recover(); r != nil {
+				p.logger.Errorf("Panic in PanicHandler: %v\n%s", r, debug.Stack())
+			}
+		}()
+		p.config.PanicHandler(ctx, panicErr)
+	}
+}
+
+// Stats 返回处理器运行指标
+func (p *processor) Stats() Stats {
+	return Stats{Panics: p.panics.Load()}
 }
 
 // dispatchMessage 分发消息到对应的处理器
 func (p *processor) dispatchMessage(msgType string, ctx Context) error {
 	p.mutex.RLock()
-	handler, exists := p.handlers[msgType]
+	handler := p.router.notFoundChained
+	if route := p.router.match(msgType); route != nil {
+		handler = route.chained
+	}
 	p.mutex.RUnlock()
 
-	if !exists {
+	if handler == nil {
 		return ErrHandlerNotFound
 	}
 
@@ -174,72 +361,167 @@ func (p *processor) dispatchMessage(msgType string, ctx Context) error {
 // Send 发送消息
 func (p *processor) Send(msgType string, payload interface{}) error {
 	p.logger.Debugf("Sending message: msgType=%s", msgType)
-
-	// 获取类型ID
-	msgTypeID, exists := p.typeRegistry.GetID(msgType)
-	if !exists {
-		// 如果类型未注册，先注册
-		var err error
-		msgTypeID, err = p.typeRegistry.Register(msgType)
-		if err != nil {
-			return err
-		}
-	}
-
-	return p.codec.Encode(p.conn, msgTypeID, payload, 0)
+	return p.sendOutbound(&OutboundMessage{Kind: OutboundSend, MsgType: msgType, Payload: payload})
 }
 
 // Request 发送请求并等待响应
 func (p *processor) Request(msgType string, payload interface{}) (Response, error) {
 	p.logger.Debugf("Sending request: msgType=%s", msgType)
 
-	// 开始新请求
-	requestID, ch := p.requestMgr.StartRequest()
+	if p.packetConn != nil {
+		return nil, ErrDatagramRequest
+	}
 
-	// 获取类型ID
-	msgTypeID, exists := p.typeRegistry.GetID(msgType)
-	if !exists {
-		// 如果类型未注册，先注册
-		var err error
-		msgTypeID, err = p.typeRegistry.Register(msgType)
-		if err != nil {
-			p.requestMgr.CancelRequest(requestID)
-			return nil, err
+	msg := &OutboundMessage{Kind: OutboundRequest, MsgType: msgType, Payload: payload}
+	if err := p.sendOutbound(msg); err != nil {
+		return nil, err
+	}
+	if msg.Response == nil {
+		return nil, fmt.Errorf("no response for request %s", msgType)
+	}
+	return msg.Response, nil
+}
+
+// Reply 发送响应（内部方法）
+func (p *processor) Reply(requestID uint64, msgType string, payload interface{}) error {
+	p.logger.Debugf("Sending reply: requestID=%d, msgType=%s", requestID, msgType)
+	return p.sendOutbound(&OutboundMessage{Kind: OutboundReply, MsgType: msgType, RequestID: requestID, Payload: payload})
+}
+
+// SendError 发送协议错误帧
+func (p *processor) SendError(requestID uint64, msgType string, err *ProtocolError) error {
+	p.logger.Debugf("Sending error: requestID=%d, msgType=%s, %v", requestID, msgType, err)
+	return p.sendOutbound(&OutboundMessage{Kind: OutboundError, MsgType: msgType, RequestID: requestID, Error: err})
+}
+
+// sendOutbound 经过出站中间件链发送消息，先注册的中间件在外层
+func (p *processor) sendOutbound(msg *OutboundMessage) error {
+	p.mutex.RLock()
+	handler := OutboundHandler(p.writeOutbound)
+	for i := len(p.outbound) - 1; i >= 0; i-- {
+		handler = p.outbound[i](handler)
+	}
+	p.mutex.RUnlock()
+	msg.Done = p.ctx.Done()
+	return handler(msg)
+}
+
+// writeOutbound 出站中间件链的末端，编码并发送消息，请求还要等待响应
+func (p *processor) writeOutbound(msg *OutboundMessage) error {
+	if err := checkMetadata(msg.Metadata); err != nil {
+		return err
+	}
+	msgTypeID, err := p.typeIDOf(msg.MsgType)
+	if err != nil {
+		return err
+	}
+
+	// 数据报可能丢失，每帧都附带类型名称；流式连接只在首次发送时附带
+	extensions := slices.Clip(msg.Metadata)
+	_, announced := p.announced.Load(msgTypeID)
+	if (!announced || p.packetConn != nil) && msg.MsgType != "" {
+		extensions = append(extensions, codec.TLV{Type: codec.TLVTypeName, Length: uint16(len(msg.MsgType)), Value: []byte(msg.MsgType)})
+	}
+
+	switch msg.Kind {
+	case OutboundRequest:
+		return p.roundTrip(msgTypeID, msg, extensions)
+	case OutboundError:
+		protoErr := msg.Error
+		if protoErr == nil {
+			protoErr = NewProtocolError(CodeUnknown, "")
 		}
+		return p.writeMessage(msgTypeID, nil, msg.RequestID, append(extensions, errorTLV(protoErr)))
+	default:
+		return p.writeMessage(msgTypeID, msg.Payload, msg.RequestID, extensions)
 	}
+}
+
+// resolveType 将类型ID转换为类型名称
+// 未注册的类型ID使用帧中附带的名称，名称的哈希必须与类型ID一致，之后同一连接上的帧不再附带名称
+func (p *processor) resolveType(msgTypeID uint32, extensions []codec.TLV) (string, bool) {
+	if name, exists := p.typeRegistry.GetName(msgTypeID); exists {
+		return name, true
+	}
+	value, ok := MetadataValue(extensions, codec.TLVTypeName)
+	if !ok {
+		return "", false
+	}
+	name := string(value)
+	if typeHash(name) != msgTypeID {
+		p.logger.Warnf("Message type name %q does not match type ID %d", name, msgTypeID)
+		return "", false
+	}
+	// 限制对端可以让注册器记住的类型数量。超出后这一帧仍按名称分发，
+	// 但对端之后发送该类型时不再附带名称，这些帧因类型ID未知而被丢弃
+	if p.learned.Load() >= maxLearnedTypes || p.learned.Add(1) > maxLearnedTypes {
+		p.logger.Warnf("Learned message type limit %d reached, %q is dispatched by name but not remembered", maxLearnedTypes, name)
+		return name, true
+	}
+	if _, err := p.typeRegistry.Register(name); err != nil {
+		p.logger.Warnf("Failed to learn message type %q: %v", name, err)
+		return "", false
+	}
+	return name, true
+}
+
+// roundTrip 分配请求ID，发送请求并等待响应
+func (p *processor) roundTrip(msgTypeID uint32, msg *OutboundMessage, extensions []codec.TLV) error {
+	// 开始新请求
+	requestID, ch := p.requestMgr.StartRequest()
+	msg.RequestID = requestID
 
 	// 发送请求
-	if err := p.codec.Encode(p.conn, msgTypeID, payload, requestID); err != nil {
+	if err := p.writeMessage(msgTypeID, msg.Payload, requestID, extensions); err != nil {
 		p.requestMgr.CancelRequest(requestID)
-		return nil, err
+		return err
 	}
 
 	// 等待响应
 	select {
-	case response := <-ch:
-		return response, nil
+	case resp := <-ch:
+		if r, ok := resp.(*response); ok && r.err != nil {
+			return r.err
+		}
+		msg.Response = resp
+		return nil
 	case <-time.After(p.config.RequestTimeout):
 		p.requestMgr.CancelRequest(requestID)
-		return nil, ErrRequestTimeout
+		return ErrRequestTimeout
 	}
 }
 
-// Reply 发送响应（内部方法）
-func (p *processor) Reply(requestID uint64, msgType string, payload interface{}) error {
-	p.logger.Debugf("Sending reply: requestID=%d, msgType=%s", requestID, msgType)
-
-	// 获取类型ID
-	msgTypeID, exists := p.typeRegistry.GetID(msgType)
-	if !exists {
-		// 如果类型未注册，先注册
-		var err error
-		msgTypeID, err = p.typeRegistry.Register(msgType)
-		if err != nil {
-			return err
-		}
+// typeIDOf 获取类型ID，类型未注册时先注册
+func (p *processor) typeIDOf(msgType string) (uint32, error) {
+	if msgTypeID, exists := p.typeRegistry.GetID(msgType); exists {
+		return msgTypeID, nil
 	}
+	return p.typeRegistry.Register(msgType)
+}
+
+// SetEncryptor 启用或替换收发管道的加密器，nil表示关闭加密
+func (p *processor) SetEncryptor(encryptor codec.Encryptor) {
+	p.codec.SetEncryptor(encryptor)
+}
+
+// Encryptor 返回收发管道的加密器，未启用加密时返回nil
+func (p *processor) Encryptor() codec.Encryptor {
+	return p.codec.Encryptor()
+}
+
+// SetSigner 启用或替换收发管道的签名器，nil表示关闭签名
+func (p *processor) SetSigner(signer codec.Signer, required bool) {
+	p.codec.SetSigner(signer, required)
+}
+
+// Signer 返回收发管道的签名器，未启用签名时返回nil
+func (p *processor) Signer() codec.Signer {
+	return p.codec.Signer()
+}
 
-	return p.codec.Encode(p.conn, msgTypeID, payload, requestID)
+// Session 返回连接会话
+func (p *processor) Session() Session {
+	return p.session
 }
 
 // Logger 返回配置的日志记录器
@@ -2,12 +2,15 @@
 
 package core
 
+import "github.com/BadKid90s/chilix-msg/codec"
+
 // Response 响应接口
 type Response interface {
 	MsgType() string
 	RequestID() uint64
 	Bind(target interface{}) error
 	RawData() []byte
+	Metadata() []codec.TLV // 对端随响应发送的元数据
 }
 
 // response 响应实现
@@ -15,7 +18,9 @@ type response struct {
 	msgType   string
 	requestID uint64
 	rawData   []byte
+	metadata  []codec.TLV
 	processor Processor
+	err       *ProtocolError // 对端返回的协议错误
 }
 
 func (r *response) MsgType() string {
@@ -33,3 +38,7 @@ func (r *response) Bind(target interface{}) error {
 func (r *response) RawData() []byte {
 	return r.rawData
 }
+
+func (r *response) Metadata() []codec.TLV {
+	return r.metadata
+}
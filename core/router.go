@@ -0,0 +1,133 @@
+package core
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// RouteInfo 已注册路由的描述，用于调试和管理接口
+type RouteInfo struct {
+	Pattern     string // 消息类型或模式
+	Exact       bool   // 是否为精确的消息类型
+	Middlewares int    // 作用于该路由的分组和处理器中间件数量，不含全局中间件
+}
+
+// route 已注册的处理器，组合好的处理器链在注册和中间件变化时重建
+type route struct {
+	pattern     Pattern
+	handler     Handler
+	middlewares []Middleware // 处理器自己的中间件
+	group       *handlerGroup
+	chained     Handler // 组合了全部中间件的处理器，分发时直接使用
+}
+
+// chain 按 全局 -> 外层分组 -> 内层分组 -> 处理器 的顺序组合中间件，调用方需持有锁
+func (r *route) chain(global []Middleware) Handler {
+	handler := wrap(r.handler, r.middlewares)
+	for g := r.group; g != nil; g = g.parent {
+		handler = wrap(handler, g.middlewares)
+	}
+	return wrap(handler, global)
+}
+
+func (r *route) info() RouteInfo {
+	n := len(r.middlewares)
+	for g := r.group; g != nil; g = g.parent {
+		n += len(g.middlewares)
+	}
+	return RouteInfo{Pattern: r.pattern.String(), Exact: r.pattern.IsExact(), Middlewares: n}
+}
+
+// wrap 用中间件包装处理器，先注册的中间件在外层
+func wrap(handler Handler, middlewares []Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// router 消息路由表，由处理器的锁保护
+// 精确的消息类型直接查表，其余按模式的具体程度从高到低匹配，具体程度相同时先注册的优先
+type router struct {
+	exact           map[string]*route
+	patterns        []*route
+	notFound        Handler
+	notFoundChained Handler // 组合了全局中间件的 NotFound 处理器
+}
+
+func newRouter() *router {
+	return &router{exact: make(map[string]*route), notFound: NotFoundHandler, notFoundChained: NotFoundHandler}
+}
+
+// rebuild 中间件变化后重建所有路由的处理器链，调用方需持有写锁
+func (r *router) rebuild(global []Middleware) {
+	for _, rt := range r.exact {
+		rt.chained = rt.chain(global)
+	}
+	for _, rt := range r.patterns {
+		rt.chained = rt.chain(global)
+	}
+	r.notFoundChained = nil
+	if r.notFound != nil {
+		// NotFound 处理器同样经过全局中间件，例如认证和日志
+		r.notFoundChained = wrap(r.notFound, global)
+	}
+}
+
+// add 添加路由，相同的消息类型或模式替换之前的处理器
+func (r *router) add(rt *route) {
+	if rt.pattern.IsExact() {
+		r.exact[rt.pattern.String()] = rt
+		return
+	}
+	r.patterns = slices.DeleteFunc(r.patterns, func(existing *route) bool {
+		return existing.pattern.String() == rt.pattern.String()
+	})
+	// 按具体程度降序插入，相同时排在已有路由之后
+	i, _ := slices.BinarySearchFunc(r.patterns, rt.pattern.Specificity(), func(existing *route, specificity int) int {
+		if existing.pattern.Specificity() >= specificity {
+			return -1
+		}
+		return 1
+	})
+	r.patterns = slices.Insert(r.patterns, i, rt)
+}
+
+// match 查找消息类型对应的路由
+func (r *router) match(msgType string) *route {
+	if rt, ok := r.exact[msgType]; ok {
+		return rt
+	}
+	for _, rt := range r.patterns {
+		if rt.pattern.Match(msgType) {
+			return rt
+		}
+	}
+	return nil
+}
+
+// routes 按模式排序的路由列表
+func (r *router) routes() []RouteInfo {
+	infos := make([]RouteInfo, 0, len(r.exact)+len(r.patterns))
+	for _, rt := range r.exact {
+		infos = append(infos, rt.info())
+	}
+	for _, rt := range r.patterns {
+		infos = append(infos, rt.info())
+	}
+	slices.SortFunc(infos, func(a, b RouteInfo) int { return cmp.Compare(a.Pattern, b.Pattern) })
+	return infos
+}
+
+// NotFoundHandler 默认的 NotFound 处理器
+// 请求收到 CodeNotFound 错误帧，不必等到超时；推送消息只记录日志
+func NotFoundHandler(ctx Context) error {
+	err := fmt.Errorf("%w: %s", ErrHandlerNotFound, ctx.MessageType())
+	if ctx.IsRequest() {
+		if replyErr := ctx.ReplyError(CodeNotFound, err.Error()); replyErr != nil {
+			return replyErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/BadKid90s/chilix-msg/codec"
+	"github.com/BadKid90s/chilix-msg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_Match(t *testing.T) {
+	p := newProcessor(nil, ProcessorConfig{Logger: log.NewDefaultLogger()})
+	r := &traceRecorder{}
+
+	p.RegisterHandler("user.**", r.handler("user.**"))
+	p.RegisterHandler("user.*.get", r.handler("user.*.get"))
+	p.RegisterHandler("user.profile.get", r.handler("user.profile.get"))
+	p.RegisterHandler("*.profile.get", r.handler("*.profile.get"))
+	admin := p.Group("admin", r.middleware("admin"))
+	admin.RegisterHandler("**", r.handler("admin.**"))
+
+	assert.Equal(t, []string{"user.profile.get"}, r.dispatch(t, p, "user.profile.get"))
+	assert.Equal(t, []string{"user.*.get"}, r.dispatch(t, p, "user.settings.get"))
+	assert.Equal(t, []string{"user.**"}, r.dispatch(t, p, "user.settings.set"))
+	assert.Equal(t, []string{"*.profile.get"}, r.dispatch(t, p, "team.profile.get"))
+	assert.Equal(t, []string{"admin", "admin.**"}, r.dispatch(t, p, "admin.users.delete"))
+
+	// 重新注册同一模式替换处理器
+	p.RegisterHandler("user.*.get", r.handler("replaced"))
+	assert.Equal(t, []string{"replaced"}, r.dispatch(t, p, "user.settings.get"))
+
+	assert.Equal(t, []RouteInfo{
+		{Pattern: "*.profile.get"},
+		{Pattern: "admin.**", Middlewares: 1},
+		{Pattern: "user.**"},
+		{Pattern: "user.*.get"},
+		{Pattern: "user.profile.get", Exact: true},
+	}, p.Routes())
+}
+
+// 测试不符合模式语法的消息类型按原样精确匹配，注册时不会退出进程
+func TestRouter_LiteralTypes(t *testing.T) {
+	p := newProcessor(nil, ProcessorConfig{Logger: log.NewDefaultLogger()})
+	r := &traceRecorder{}
+
+	p.RegisterHandler("a..b", r.handler("a..b"))
+	p.RegisterHandler("v1*", r.handler("v1*"))
+	p.RegisterHandler("x.**.y", r.handler("x.**.y"))
+	p.Group("").RegisterHandler("ping", r.handler(".ping"))
+
+	for _, msgType := range []string{"a..b", "v1*", "x.**.y", ".ping"} {
+		_, exists := p.typeRegistry.GetID(msgType)
+		assert.True(t, exists, msgType)
+	}
+	assert.Equal(t, []string{"a..b"}, r.dispatch(t, p, "a..b"))
+	assert.Equal(t, []string{"v1*"}, r.dispatch(t, p, "v1*"))
+	assert.Equal(t, []string{"x.**.y"}, r.dispatch(t, p, "x.**.y"))
+	assert.Equal(t, []string{".ping"}, r.dispatch(t, p, ".ping"))
+	assert.ErrorIs(t, p.dispatchMessage("x.z.y", &processorContext{msgType: "x.z.y", processor: p}), ErrHandlerNotFound)
+}
+
+// 测试超过学习上限后带名称的帧仍可分发，但类型不再被记住
+func TestProcessor_LearnedTypeLimit(t *testing.T) {
+	p := newProcessor(nil, ProcessorConfig{Logger: log.NewDefaultLogger()})
+	nameTLV := func(name string) []codec.TLV {
+		return []codec.TLV{{Type: codec.TLVTypeName, Length: uint16(len(name)), Value: []byte(name)}}
+	}
+
+	name, ok := p.resolveType(typeHash("user.a.get"), nameTLV("user.a.get"))
+	assert.True(t, ok)
+	assert.Equal(t, "user.a.get", name)
+	_, ok = p.resolveType(typeHash("user.a.get"), nil)
+	assert.True(t, ok)
+
+	p.learned.Store(maxLearnedTypes)
+	name, ok = p.resolveType(typeHash("user.b.get"), nameTLV("user.b.get"))
+	assert.True(t, ok)
+	assert.Equal(t, "user.b.get", name)
+	_, ok = p.resolveType(typeHash("user.b.get"), nil)
+	assert.False(t, ok)
+	assert.Equal(t, int32(maxLearnedTypes), p.learned.Load())
+}
+
+func TestRouter_NotFound(t *testing.T) {
+	p := newProcessor(nil, ProcessorConfig{Logger: log.NewDefaultLogger()})
+	r := &traceRecorder{}
+	p.Use(r.middleware("global"))
+
+	p.SetNotFound(r.handler("not-found"))
+	assert.Equal(t, []string{"global", "not-found"}, r.dispatch(t, p, "missing"))
+
+	p.SetNotFound(nil)
+	assert.ErrorIs(t, p.dispatchMessage("missing", &processorContext{msgType: "missing", processor: p}), ErrHandlerNotFound)
+}
+
+func TestRouter_Wildcard(t *testing.T) {
+	received := make(chan string, 4)
+	client, _ := newProcessorPair(t, func(server Processor) {
+		server.RegisterHandler("user.*.get", func(ctx Context) error {
+			received <- ctx.MessageType()
+			return ctx.Reply(ctx.MessageType())
+		})
+	})
+
+	// 服务端没有注册 user.profile.get，依靠首帧附带的类型名称路由
+	for i := 0; i < 2; i++ {
+		resp, err := client.Request("user.profile.get", nil)
+		require.NoError(t, err)
+		var reply string
+		require.NoError(t, resp.Bind(&reply))
+		assert.Equal(t, "user.profile.get", reply)
+		assert.Empty(t, resp.Metadata())
+	}
+	require.NoError(t, client.Send("user.settings.get", nil))
+	assert.Equal(t, "user.profile.get", <-received)
+	assert.Equal(t, "user.profile.get", <-received)
+	assert.Equal(t, "user.settings.get", <-received)
+
+	// 没有匹配的路由时请求方立即收到错误
+	_, err := client.Request("order.create", nil)
+	assert.True(t, errors.Is(err, ErrNotFound), "got %v", err)
+}
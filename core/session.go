@@ -0,0 +1,80 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"sync"
+)
+
+// Principal 连接认证后的身份
+type Principal struct {
+	// ID 身份标识，例如用户ID或服务名
+	ID string
+	// Roles 角色列表，用于授权
+	Roles []string
+	// Attributes 认证器提供的其他属性，例如JWT声明
+	Attributes map[string]any
+}
+
+// HasRole 判断是否拥有指定角色
+func (p *Principal) HasRole(role string) bool {
+	return p != nil && slices.Contains(p.Roles, role)
+}
+
+// Session 连接级别的会话状态，同一连接上的所有消息共享
+type Session interface {
+	// ID 会话标识，随机生成，不同连接和进程之间不会重复，可以用作外部存储的键
+	ID() string
+	// Principal 返回已认证的身份，未认证时返回nil
+	Principal() *Principal
+	// SetPrincipal 设置已认证的身份
+	SetPrincipal(principal *Principal)
+	// Get 读取会话属性
+	Get(key string) (any, bool)
+	// Set 设置会话属性
+	Set(key string, value any)
+}
+
+// session 会话实现
+type session struct {
+	id        string
+	mutex     sync.RWMutex
+	principal *Principal
+	values    map[string]any
+}
+
+func newSession() *session {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return &session{id: hex.EncodeToString(id), values: make(map[string]any)}
+}
+
+func (s *session) ID() string {
+	return s.id
+}
+
+func (s *session) Principal() *Principal {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.principal
+}
+
+func (s *session) SetPrincipal(principal *Principal) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.principal = principal
+}
+
+func (s *session) Get(key string) (any, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	value, ok := s.values[key]
+	return value, ok
+}
+
+func (s *session) Set(key string, value any) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.values[key] = value
+}
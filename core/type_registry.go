@@ -29,12 +29,7 @@ func (r *Registry) Register(msgType string) (uint32, error) {
 	defer r.mutex.Unlock()
 
 	// 计算哈希
-	h := fnv.New32a()
-	if _, err := h.Write([]byte(msgType)); err != nil {
-		return 0, err
-	}
-
-	hash := h.Sum32()
+	hash := typeHash(msgType)
 
 	// 检查冲突
 	if existing, exists := r.idToName[hash]; exists && existing != msgType {
@@ -83,3 +78,10 @@ func (r *Registry) Clear() {
 	r.nameToID = make(map[string]uint32)
 	r.idToName = make(map[uint32]string)
 }
+
+// typeHash 消息类型名称的 FNV-1a 哈希，作为线上传输的类型ID
+func typeHash(msgType string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(msgType))
+	return h.Sum32()
+}
@@ -0,0 +1,114 @@
+package handshake
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/BadKid90s/chilix-msg/codec"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Suite 握手协商的对称加密套件
+type Suite uint16
+
+const (
+	// SuiteAES256GCM AES-256-GCM，有硬件加速的平台上最快
+	SuiteAES256GCM Suite = 1
+	// SuiteChaCha20Poly1305 ChaCha20-Poly1305，适合没有AES硬件加速的平台
+	SuiteChaCha20Poly1305 Suite = 2
+)
+
+// defaultSuites 默认支持的套件，按优先级排列
+var defaultSuites = []Suite{SuiteAES256GCM, SuiteChaCha20Poly1305}
+
+func (s Suite) String() string {
+	switch s {
+	case SuiteAES256GCM:
+		return "AES-256-GCM"
+	case SuiteChaCha20Poly1305:
+		return "ChaCha20-Poly1305"
+	default:
+		return fmt.Sprintf("Suite(%d)", uint16(s))
+	}
+}
+
+// keySize 套件使用的密钥长度
+const keySize = 32
+
+// newAEAD 根据套件创建AEAD
+func (s Suite) newAEAD(key []byte) (cipher.AEAD, error) {
+	switch s {
+	case SuiteAES256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case SuiteChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrNoCommonSuite, s)
+	}
+}
+
+// sessionCipher 会话加密器，发送和接收使用不同方向的密钥
+// 实现 codec.AEADEncryptor，格式与 codec.AESEncryptor 相同：nonce + 密文。
+// 每个方向的密钥只属于一个连接，nonce 使用递增计数器而不是随机数，保证同一密钥下永不重复
+type sessionCipher struct {
+	send    cipher.AEAD
+	recv    cipher.AEAD
+	counter atomic.Uint64
+}
+
+var _ codec.AEADEncryptor = (*sessionCipher)(nil)
+
+func newSessionCipher(suite Suite, sendKey, recvKey []byte) (*sessionCipher, error) {
+	send, err := suite.newAEAD(sendKey)
+	if err != nil {
+		return nil, err
+	}
+	recv, err := suite.newAEAD(recvKey)
+	if err != nil {
+		return nil, err
+	}
+	return &sessionCipher{send: send, recv: recv}, nil
+}
+
+// Encrypt 使用发送方向的密钥加密
+func (c *sessionCipher) Encrypt(data []byte) ([]byte, error) {
+	return c.Seal(data, nil)
+}
+
+// Decrypt 使用接收方向的密钥解密
+func (c *sessionCipher) Decrypt(data []byte) ([]byte, error) {
+	return c.Open(data, nil)
+}
+
+// Seal 使用发送方向的密钥加密并认证附加数据
+func (c *sessionCipher) Seal(data, additionalData []byte) ([]byte, error) {
+	n := c.counter.Add(1)
+	if n == 0 {
+		return nil, fmt.Errorf("%w: nonce space exhausted", codec.ErrEncryptionFailed)
+	}
+	// nonce: 前导零 + 64位计数器
+	nonceSize := c.send.NonceSize()
+	nonce := make([]byte, nonceSize, nonceSize+len(data)+c.send.Overhead())
+	binary.BigEndian.PutUint64(nonce[nonceSize-8:], n)
+	return c.send.Seal(nonce, nonce, data, additionalData), nil
+}
+
+// Open 使用接收方向的密钥解密并校验附加数据
+func (c *sessionCipher) Open(data, additionalData []byte) ([]byte, error) {
+	nonceSize := c.recv.NonceSize()
+	if len(data) < nonceSize+c.recv.Overhead() {
+		return nil, fmt.Errorf("%w: ciphertext too short", codec.ErrDecryptionFailed)
+	}
+	plaintext, err := c.recv.Open(nil, data[:nonceSize], data[nonceSize:], additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", codec.ErrDecryptionFailed, err)
+	}
+	return plaintext, nil
+}
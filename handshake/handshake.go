@@ -0,0 +1,477 @@
+// Package handshake 实现建立加密会话的握手协议
+//
+// 双方交换临时 X25519 公钥，服务端（可选地还有客户端）用长期 Ed25519 或 RSA 身份私钥对握手记录签名，
+// 共享密钥经 HKDF-SHA256 派生出两个方向各自的 AES-256-GCM 或 ChaCha20-Poly1305 密钥。
+// 临时密钥在握手结束后丢弃，长期私钥泄露也无法解密之前的会话（前向安全）。
+//
+// 握手流程:
+//
+//	Client                                Server
+//	ClientHello  (临时公钥, 随机数, 套件)  ──>
+//	                                <──  ServerHello (临时公钥, 随机数, 套件, 身份公钥, 签名)
+//	ClientFinish (身份公钥, 签名)      ──>
+//	                                <──  ServerFinish (用派生密钥加密的握手摘要)
+//
+// 握手完成后把 Result.Encryptor 交给 core.ProcessorConfig.Encryptor，之后的消息帧都带加密标志。
+package handshake
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/codec"
+	"github.com/BadKid90s/chilix-msg/log"
+	"github.com/BadKid90s/chilix-msg/transport"
+	"golang.org/x/crypto/cryptobyte"
+	"golang.org/x/crypto/hkdf"
+)
+
+var (
+	// ErrNoIdentity 需要身份私钥但未配置
+	ErrNoIdentity = errors.New("no identity configured")
+	// ErrUnsupportedKey 身份密钥不是 Ed25519 或 RSA
+	ErrUnsupportedKey = errors.New("unsupported identity key")
+	// ErrBadSignature 握手签名无效
+	ErrBadSignature = errors.New("invalid handshake signature")
+	// ErrUntrustedPeer 对端身份不受信任
+	ErrUntrustedPeer = errors.New("untrusted peer identity")
+	// ErrNoCommonSuite 双方没有共同支持的加密套件
+	ErrNoCommonSuite = errors.New("no common cipher suite")
+	// ErrProtocol 握手消息格式错误或顺序错误
+	ErrProtocol = errors.New("handshake protocol error")
+	// ErrRejected 对端拒绝了握手
+	ErrRejected = errors.New("handshake rejected by peer")
+)
+
+const (
+	protocolVersion = 1
+
+	msgClientHello  = 1
+	msgServerHello  = 2
+	msgClientFinish = 3
+	msgServerFinish = 4
+	msgAlert        = 5
+
+	// maxMessageSize 握手消息的最大长度，足够容纳4096位RSA公钥和签名
+	maxMessageSize = 8 * 1024
+
+	nonceSize = 32
+
+	// alertReason 发送给对端的告警内容，不透露失败的具体原因
+	alertReason = "handshake failed"
+
+	serverSignatureContext = "chilix-msg handshake v1 server signature\x00"
+	clientSignatureContext = "chilix-msg handshake v1 client signature\x00"
+	keyDerivationInfo      = "chilix-msg handshake v1 keys\x00"
+)
+
+// Config 握手配置
+type Config struct {
+	// Identity 本端长期身份私钥，ed25519.PrivateKey 或 *rsa.PrivateKey
+	// 服务端必填；客户端在服务端要求双向认证时必填
+	Identity crypto.Signer
+	// TrustedKeys 信任的对端身份公钥
+	// 客户端用来校验服务端；服务端设置后要求客户端提供身份（双向认证）
+	TrustedKeys []crypto.PublicKey
+	// VerifyPeer 自定义对端身份校验，设置后忽略 TrustedKeys
+	VerifyPeer func(key crypto.PublicKey) error
+	// Suites 支持的加密套件，按优先级排列，默认 AES-256-GCM、ChaCha20-Poly1305
+	Suites []Suite
+	// Timeout 握手超时，默认10秒
+	Timeout time.Duration
+	// InsecureSkipVerify 客户端不校验服务端身份，仅用于测试
+	InsecureSkipVerify bool
+	// Logger 记录握手失败的详细原因，默认 log.NewDefaultLogger()
+	Logger log.Logger
+}
+
+func (c Config) suites() []Suite {
+	if len(c.Suites) > 0 {
+		return c.Suites
+	}
+	return defaultSuites
+}
+
+func (c Config) logger() log.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return log.NewDefaultLogger()
+}
+
+func (c Config) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return 10 * time.Second
+}
+
+// requirePeer 是否要求对端提供并通过校验的身份
+func (c Config) requirePeer() bool {
+	return c.VerifyPeer != nil || len(c.TrustedKeys) > 0
+}
+
+// verifyPeer 校验对端身份公钥
+func (c Config) verifyPeer(key crypto.PublicKey) error {
+	if c.VerifyPeer != nil {
+		return c.VerifyPeer(key)
+	}
+	if slices.ContainsFunc(c.TrustedKeys, func(trusted crypto.PublicKey) bool { return equalKey(trusted, key) }) {
+		return nil
+	}
+	return ErrUntrustedPeer
+}
+
+// Result 握手结果
+type Result struct {
+	// Suite 协商的加密套件
+	Suite Suite
+	// PeerKey 对端身份公钥，对端未提供身份时为nil
+	PeerKey crypto.PublicKey
+	// Encryptor 会话加密器，用于 core.ProcessorConfig.Encryptor 或 codec.BalancedCodec
+	Encryptor codec.Encryptor
+}
+
+// Client 在连接上以客户端身份完成握手
+func Client(conn transport.Connection, config Config) (*Result, error) {
+	if err := conn.SetDeadline(time.Now().Add(config.timeout())); err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.SetDeadline(time.Time{}) }()
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	clientNonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, clientNonce); err != nil {
+		return nil, err
+	}
+
+	// ClientHello
+	var b cryptobyte.Builder
+	b.AddUint8(protocolVersion)
+	b.AddBytes(ephemeral.PublicKey().Bytes())
+	b.AddBytes(clientNonce)
+	b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, suite := range config.suites() {
+			b.AddUint16(uint16(suite))
+		}
+	})
+	clientHello := b.BytesOrPanic()
+	if err := writeMessage(conn, msgClientHello, clientHello); err != nil {
+		return nil, err
+	}
+
+	// ServerHello
+	serverHello, err := readMessage(conn, msgServerHello)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		version                   uint8
+		serverPublic, serverNonce []byte
+		suiteID                   uint16
+		clientAuth                uint8
+		identity, signature       cryptobyte.String
+	)
+	s := cryptobyte.String(serverHello)
+	if !s.ReadUint8(&version) || !s.ReadBytes(&serverPublic, 32) || !s.ReadBytes(&serverNonce, nonceSize) ||
+		!s.ReadUint16(&suiteID) || !s.ReadUint8(&clientAuth) || !s.ReadUint16LengthPrefixed(&identity) {
+		return nil, config.abort(conn, fmt.Errorf("%w: malformed ServerHello", ErrProtocol))
+	}
+	signedLen := len(serverHello) - len(s)
+	if !s.ReadUint16LengthPrefixed(&signature) || !s.Empty() {
+		return nil, config.abort(conn, fmt.Errorf("%w: malformed ServerHello", ErrProtocol))
+	}
+	suite := Suite(suiteID)
+	if version != protocolVersion || !slices.Contains(config.suites(), suite) {
+		return nil, config.abort(conn, fmt.Errorf("%w: server selected %v", ErrNoCommonSuite, suite))
+	}
+
+	// 校验服务端身份
+	serverKey, err := parseIdentity(identity)
+	if err != nil {
+		return nil, config.abort(conn, err)
+	}
+	if err := verify(serverKey, serverSignatureContext, digest(clientHello, serverHello[:signedLen]), signature); err != nil {
+		return nil, config.abort(conn, err)
+	}
+	if !config.InsecureSkipVerify {
+		if err := config.verifyPeer(serverKey); err != nil {
+			return nil, config.abort(conn, err)
+		}
+	}
+
+	sendKey, recvKey, err := deriveKeys(ephemeral, serverPublic, clientNonce, serverNonce, digest(clientHello, serverHello))
+	if err != nil {
+		return nil, config.abort(conn, err)
+	}
+	session, err := newSessionCipher(suite, sendKey, recvKey)
+	if err != nil {
+		return nil, config.abort(conn, err)
+	}
+
+	// ClientFinish
+	var identityDER, clientSignature []byte
+	if clientAuth != 0 || config.Identity != nil {
+		if config.Identity == nil {
+			return nil, config.abort(conn, fmt.Errorf("%w: server requires client identity", ErrNoIdentity))
+		}
+		if identityDER, err = marshalIdentity(config.Identity); err != nil {
+			return nil, config.abort(conn, err)
+		}
+		clientSignature, err = sign(config.Identity, clientSignatureContext, digest(clientHello, serverHello, identityDER))
+		if err != nil {
+			return nil, config.abort(conn, err)
+		}
+	}
+	b = cryptobyte.Builder{}
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(identityDER) })
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(clientSignature) })
+	clientFinish := b.BytesOrPanic()
+	if err := writeMessage(conn, msgClientFinish, clientFinish); err != nil {
+		return nil, err
+	}
+
+	// ServerFinish 确认双方派生出相同的密钥
+	serverFinish, err := readMessage(conn, msgServerFinish)
+	if err != nil {
+		return nil, err
+	}
+	confirm, err := session.Decrypt(serverFinish)
+	if err != nil || !bytes.Equal(confirm, digest(clientHello, serverHello, clientFinish)) {
+		return nil, fmt.Errorf("%w: key confirmation failed", ErrProtocol)
+	}
+
+	return &Result{Suite: suite, PeerKey: serverKey, Encryptor: session}, nil
+}
+
+// Server 在连接上以服务端身份完成握手
+func Server(conn transport.Connection, config Config) (*Result, error) {
+	if config.Identity == nil {
+		return nil, ErrNoIdentity
+	}
+	identityDER, err := marshalIdentity(config.Identity)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Now().Add(config.timeout())); err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.SetDeadline(time.Time{}) }()
+
+	// ClientHello
+	clientHello, err := readMessage(conn, msgClientHello)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		version                   uint8
+		clientPublic, clientNonce []byte
+		suiteList                 cryptobyte.String
+	)
+	s := cryptobyte.String(clientHello)
+	if !s.ReadUint8(&version) || !s.ReadBytes(&clientPublic, 32) || !s.ReadBytes(&clientNonce, nonceSize) ||
+		!s.ReadUint8LengthPrefixed(&suiteList) || !s.Empty() {
+		return nil, config.abort(conn, fmt.Errorf("%w: malformed ClientHello", ErrProtocol))
+	}
+	if version != protocolVersion {
+		return nil, config.abort(conn, fmt.Errorf("%w: unsupported version %d", ErrProtocol, version))
+	}
+	var offered []Suite
+	for !suiteList.Empty() {
+		var id uint16
+		if !suiteList.ReadUint16(&id) {
+			return nil, config.abort(conn, fmt.Errorf("%w: malformed ClientHello", ErrProtocol))
+		}
+		offered = append(offered, Suite(id))
+	}
+	// 按服务端的优先级选择套件
+	idx := slices.IndexFunc(config.suites(), func(s Suite) bool { return slices.Contains(offered, s) })
+	if idx < 0 {
+		return nil, config.abort(conn, ErrNoCommonSuite)
+	}
+	suite := config.suites()[idx]
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serverNonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, serverNonce); err != nil {
+		return nil, err
+	}
+
+	// ServerHello，签名覆盖 ClientHello 和签名之前的所有字段
+	var b cryptobyte.Builder
+	b.AddUint8(protocolVersion)
+	b.AddBytes(ephemeral.PublicKey().Bytes())
+	b.AddBytes(serverNonce)
+	b.AddUint16(uint16(suite))
+	if config.requirePeer() {
+		b.AddUint8(1)
+	} else {
+		b.AddUint8(0)
+	}
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(identityDER) })
+	signed := b.BytesOrPanic()
+	signature, err := sign(config.Identity, serverSignatureContext, digest(clientHello, signed))
+	if err != nil {
+		return nil, config.abort(conn, err)
+	}
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(signature) })
+	serverHello := b.BytesOrPanic()
+	if err := writeMessage(conn, msgServerHello, serverHello); err != nil {
+		return nil, err
+	}
+
+	// ClientFinish
+	clientFinish, err := readMessage(conn, msgClientFinish)
+	if err != nil {
+		return nil, err
+	}
+	var clientIdentity, clientSignature cryptobyte.String
+	s = cryptobyte.String(clientFinish)
+	if !s.ReadUint16LengthPrefixed(&clientIdentity) || !s.ReadUint16LengthPrefixed(&clientSignature) || !s.Empty() {
+		return nil, config.abort(conn, fmt.Errorf("%w: malformed ClientFinish", ErrProtocol))
+	}
+
+	var clientKey crypto.PublicKey
+	if len(clientIdentity) > 0 {
+		if clientKey, err = parseIdentity(clientIdentity); err != nil {
+			return nil, config.abort(conn, err)
+		}
+		if err := verify(clientKey, clientSignatureContext, digest(clientHello, serverHello, clientIdentity), clientSignature); err != nil {
+			return nil, config.abort(conn, err)
+		}
+	}
+	if config.requirePeer() {
+		if clientKey == nil {
+			return nil, config.abort(conn, fmt.Errorf("%w: client did not present an identity", ErrUntrustedPeer))
+		}
+		if err := config.verifyPeer(clientKey); err != nil {
+			return nil, config.abort(conn, err)
+		}
+	}
+
+	recvKey, sendKey, err := deriveKeys(ephemeral, clientPublic, clientNonce, serverNonce, digest(clientHello, serverHello))
+	if err != nil {
+		return nil, config.abort(conn, err)
+	}
+	session, err := newSessionCipher(suite, sendKey, recvKey)
+	if err != nil {
+		return nil, config.abort(conn, err)
+	}
+
+	// ServerFinish
+	confirm, err := session.Encrypt(digest(clientHello, serverHello, clientFinish))
+	if err != nil {
+		return nil, err
+	}
+	if err := writeMessage(conn, msgServerFinish, confirm); err != nil {
+		return nil, err
+	}
+
+	return &Result{Suite: suite, PeerKey: clientKey, Encryptor: session}, nil
+}
+
+// deriveKeys 由ECDH共享密钥派生客户端到服务端、服务端到客户端两个方向的密钥
+func deriveKeys(private *ecdh.PrivateKey, peerPublic, clientNonce, serverNonce, transcript []byte) (clientKey, serverKey []byte, err error) {
+	public, err := ecdh.X25519().NewPublicKey(peerPublic)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid ephemeral key", ErrProtocol)
+	}
+	shared, err := private.ECDH(public)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrProtocol, err)
+	}
+
+	salt := append(slices.Clip(clientNonce), serverNonce...)
+	info := append([]byte(keyDerivationInfo), transcript...)
+	keys := make([]byte, 2*keySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, info), keys); err != nil {
+		return nil, nil, err
+	}
+	return keys[:keySize], keys[keySize:], nil
+}
+
+// digest 计算握手记录的摘要
+func digest(parts ...[]byte) []byte {
+	h := sha256.New()
+	for _, part := range parts {
+		// 写入长度避免不同分段方式产生相同的摘要
+		_ = binary.Write(h, binary.BigEndian, uint32(len(part)))
+		h.Write(part)
+	}
+	return h.Sum(nil)
+}
+
+func marshalIdentity(signer crypto.Signer) ([]byte, error) {
+	if err := checkKey(signer.Public()); err != nil {
+		return nil, err
+	}
+	return x509.MarshalPKIXPublicKey(signer.Public())
+}
+
+func parseIdentity(der []byte) (crypto.PublicKey, error) {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid identity key: %v", ErrProtocol, err)
+	}
+	return key, checkKey(key)
+}
+
+// writeMessage 写入一条握手消息：类型(8bit) + 长度(16bit) + 内容
+func writeMessage(w io.Writer, msgType uint8, body []byte) error {
+	if len(body) > maxMessageSize {
+		return fmt.Errorf("%w: message too large", ErrProtocol)
+	}
+	msg := make([]byte, 3, 3+len(body))
+	msg[0] = msgType
+	binary.BigEndian.PutUint16(msg[1:3], uint16(len(body)))
+	_, err := w.Write(append(msg, body...))
+	return err
+}
+
+// readMessage 读取一条指定类型的握手消息，收到告警时返回 ErrRejected
+func readMessage(r io.Reader, want uint8) ([]byte, error) {
+	header := make([]byte, 3)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint16(header[1:3]))
+	if length > maxMessageSize {
+		return nil, fmt.Errorf("%w: message too large", ErrProtocol)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	switch header[0] {
+	case want:
+		return body, nil
+	case msgAlert:
+		return nil, fmt.Errorf("%w: %s", ErrRejected, body)
+	default:
+		return nil, fmt.Errorf("%w: unexpected message type %d", ErrProtocol, header[0])
+	}
+}
+
+// abort 向对端发送告警后返回原错误
+// 对端尚未通过认证，告警只包含固定的原因，详细错误只记录在本端日志
+func (c Config) abort(w io.Writer, err error) error {
+	c.logger().Warnf("Handshake failed: %v", err)
+	_ = writeMessage(w, msgAlert, []byte(alertReason))
+	return err
+}
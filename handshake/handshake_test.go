@@ -0,0 +1,270 @@
+package handshake
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/core"
+	"github.com/BadKid90s/chilix-msg/log"
+	"github.com/BadKid90s/chilix-msg/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEd25519(t *testing.T) crypto.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func newRSA(t *testing.T) crypto.Signer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key
+}
+
+type outcome struct {
+	result *Result
+	err    error
+}
+
+// connect 建立一对内存连接
+func connect(t *testing.T) (client, server transport.Connection) {
+	t.Helper()
+	tr := transport.NewMemoryTransport()
+	listener, err := tr.Listen("")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	accepted := make(chan transport.Connection, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+	client, err = tr.Dial(listener.Addr().String())
+	require.NoError(t, err)
+	server = <-accepted
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	return client, server
+}
+
+// run 在内存连接上执行握手
+func run(t *testing.T, clientConfig, serverConfig Config) (client, server outcome) {
+	t.Helper()
+	clientConn, serverConn := connect(t)
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := Server(serverConn, serverConfig)
+		done <- outcome{result, err}
+	}()
+	result, err := Client(clientConn, clientConfig)
+	return outcome{result, err}, <-done
+}
+
+func TestHandshake_Suites(t *testing.T) {
+	serverKey := newEd25519(t)
+	for _, suite := range []Suite{SuiteAES256GCM, SuiteChaCha20Poly1305} {
+		t.Run(suite.String(), func(t *testing.T) {
+			client, server := run(t,
+				Config{TrustedKeys: []crypto.PublicKey{serverKey.Public()}, Suites: []Suite{suite}},
+				Config{Identity: serverKey},
+			)
+			require.NoError(t, client.err)
+			require.NoError(t, server.err)
+			assert.Equal(t, suite, client.result.Suite)
+			assert.Equal(t, suite, server.result.Suite)
+			assert.True(t, equalKey(serverKey.Public(), client.result.PeerKey))
+			assert.Nil(t, server.result.PeerKey)
+
+			// 两个方向使用不同的密钥
+			sealed, err := client.result.Encryptor.Encrypt([]byte("ping"))
+			require.NoError(t, err)
+			plain, err := server.result.Encryptor.Decrypt(sealed)
+			require.NoError(t, err)
+			assert.Equal(t, "ping", string(plain))
+			_, err = client.result.Encryptor.Decrypt(sealed)
+			assert.Error(t, err)
+
+			sealed, err = server.result.Encryptor.Encrypt([]byte("pong"))
+			require.NoError(t, err)
+			plain, err = client.result.Encryptor.Decrypt(sealed)
+			require.NoError(t, err)
+			assert.Equal(t, "pong", string(plain))
+		})
+	}
+}
+
+func TestHandshake_MutualRSA(t *testing.T) {
+	serverKey, clientKey := newRSA(t), newEd25519(t)
+	client, server := run(t,
+		Config{Identity: clientKey, TrustedKeys: []crypto.PublicKey{serverKey.Public()}},
+		Config{Identity: serverKey, TrustedKeys: []crypto.PublicKey{clientKey.Public()}},
+	)
+	require.NoError(t, client.err)
+	require.NoError(t, server.err)
+	assert.True(t, equalKey(serverKey.Public(), client.result.PeerKey))
+	assert.True(t, equalKey(clientKey.Public(), server.result.PeerKey))
+}
+
+func TestHandshake_Rejected(t *testing.T) {
+	serverKey, clientKey := newEd25519(t), newEd25519(t)
+	other := newEd25519(t).Public()
+
+	t.Run("untrusted server", func(t *testing.T) {
+		client, server := run(t,
+			Config{TrustedKeys: []crypto.PublicKey{other}},
+			Config{Identity: serverKey},
+		)
+		assert.ErrorIs(t, client.err, ErrUntrustedPeer)
+		assert.ErrorIs(t, server.err, ErrRejected)
+	})
+
+	t.Run("untrusted client", func(t *testing.T) {
+		client, server := run(t,
+			Config{Identity: clientKey, InsecureSkipVerify: true},
+			Config{Identity: serverKey, TrustedKeys: []crypto.PublicKey{other}},
+		)
+		assert.ErrorIs(t, server.err, ErrUntrustedPeer)
+		assert.ErrorIs(t, client.err, ErrRejected)
+		// 告警不透露服务端拒绝的具体原因
+		assert.Contains(t, client.err.Error(), alertReason)
+		assert.NotContains(t, client.err.Error(), ErrUntrustedPeer.Error())
+	})
+
+	t.Run("client identity required", func(t *testing.T) {
+		client, server := run(t,
+			Config{InsecureSkipVerify: true},
+			Config{Identity: serverKey, VerifyPeer: func(crypto.PublicKey) error { return nil }},
+		)
+		assert.ErrorIs(t, client.err, ErrNoIdentity)
+		assert.ErrorIs(t, server.err, ErrRejected)
+	})
+
+	t.Run("no common suite", func(t *testing.T) {
+		client, server := run(t,
+			Config{InsecureSkipVerify: true, Suites: []Suite{SuiteChaCha20Poly1305}},
+			Config{Identity: serverKey, Suites: []Suite{SuiteAES256GCM}},
+		)
+		assert.ErrorIs(t, server.err, ErrNoCommonSuite)
+		assert.ErrorIs(t, client.err, ErrRejected)
+	})
+}
+
+// tamperConn 修改写出的第n条握手消息的最后一个字节
+type tamperConn struct {
+	transport.Connection
+	target, count int
+}
+
+func (c *tamperConn) Write(p []byte) (int, error) {
+	c.count++
+	if c.count == c.target {
+		p = append([]byte(nil), p...)
+		p[len(p)-1] ^= 0xFF
+	}
+	return c.Connection.Write(p)
+}
+
+func TestHandshake_Tampered(t *testing.T) {
+	serverKey := newEd25519(t)
+	clientConn, serverConn := connect(t)
+
+	// 篡改 ServerHello 的签名
+	done := make(chan error, 1)
+	go func() {
+		_, err := Server(&tamperConn{Connection: serverConn, target: 1}, Config{Identity: serverKey})
+		done <- err
+	}()
+	_, err := Client(clientConn, Config{TrustedKeys: []crypto.PublicKey{serverKey.Public()}})
+	assert.ErrorIs(t, err, ErrBadSignature)
+	assert.ErrorIs(t, <-done, ErrRejected)
+}
+
+func TestHandshake_Timeout(t *testing.T) {
+	clientConn, _ := connect(t)
+	start := time.Now()
+	_, err := Client(clientConn, Config{InsecureSkipVerify: true, Timeout: 100 * time.Millisecond})
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestHandshake_Processor(t *testing.T) {
+	serverKey := newEd25519(t)
+	clientConn, serverConn := connect(t)
+
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := Server(serverConn, Config{Identity: serverKey})
+		done <- outcome{result, err}
+	}()
+	clientResult, err := Client(clientConn, Config{TrustedKeys: []crypto.PublicKey{serverKey.Public()}})
+	require.NoError(t, err)
+	server := <-done
+	require.NoError(t, server.err)
+
+	config := core.ProcessorConfig{Logger: log.NewDefaultLogger(), RequestTimeout: time.Second}
+	serverConfig := config
+	serverConfig.Encryptor = server.result.Encryptor
+	serverProcessor := core.NewProcessor(serverConn, serverConfig)
+	serverProcessor.RegisterHandler("echo", func(ctx core.Context) error {
+		var msg string
+		if err := ctx.Bind(&msg); err != nil {
+			return err
+		}
+		return ctx.Reply("echo: " + msg)
+	})
+	go func() { _ = serverProcessor.Listen() }()
+
+	clientConfig := config
+	clientConfig.Encryptor = clientResult.Encryptor
+	clientProcessor := core.NewProcessor(clientConn, clientConfig)
+	go func() { _ = clientProcessor.Listen() }()
+
+	resp, err := clientProcessor.Request("echo", "hello")
+	require.NoError(t, err)
+	var reply string
+	require.NoError(t, resp.Bind(&reply))
+	assert.Equal(t, "echo: hello", reply)
+}
+
+func TestLoadKeys(t *testing.T) {
+	dir := t.TempDir()
+	for name, key := range map[string]crypto.Signer{"ed25519": newEd25519(t), "rsa": newRSA(t)} {
+		t.Run(name, func(t *testing.T) {
+			der, err := x509.MarshalPKCS8PrivateKey(key)
+			require.NoError(t, err)
+			privateFile := filepath.Join(dir, name+".key")
+			require.NoError(t, os.WriteFile(privateFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+			der, err = x509.MarshalPKIXPublicKey(key.Public())
+			require.NoError(t, err)
+			publicFile := filepath.Join(dir, name+".pub")
+			require.NoError(t, os.WriteFile(publicFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+
+			identity, err := LoadIdentity(privateFile)
+			require.NoError(t, err)
+			public, err := LoadPublicKey(publicFile)
+			require.NoError(t, err)
+			assert.True(t, equalKey(identity.Public(), public))
+		})
+	}
+
+	_, err := LoadIdentity(filepath.Join(dir, "missing.key"))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
@@ -0,0 +1,116 @@
+package handshake
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+// LoadIdentity 从PEM文件加载身份私钥，支持 PKCS#8（Ed25519、RSA）和 PKCS#1（RSA）
+func LoadIdentity(file string) (crypto.Signer, error) {
+	block, err := readPEM(file)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", file, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok || checkKey(signer.Public()) != nil {
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+	}
+	return signer, nil
+}
+
+// LoadPublicKey 从PEM文件加载对端身份公钥（PKIX格式或证书）
+func LoadPublicKey(file string) (crypto.PublicKey, error) {
+	block, err := readPEM(file)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate %s: %w", file, err)
+		}
+		return cert.PublicKey, checkKey(cert.PublicKey)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", file, err)
+	}
+	return key, checkKey(key)
+}
+
+func readPEM(file string) (*pem.Block, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", file)
+	}
+	return block, nil
+}
+
+// checkKey 身份密钥只支持 Ed25519 和 RSA
+func checkKey(key crypto.PublicKey) error {
+	switch key.(type) {
+	case ed25519.PublicKey, *rsa.PublicKey:
+		return nil
+	default:
+		return fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+	}
+}
+
+// sign 对握手摘要签名，Ed25519直接签名，RSA使用PSS
+func sign(signer crypto.Signer, context string, digest []byte) ([]byte, error) {
+	msg := append([]byte(context), digest...)
+	switch signer.Public().(type) {
+	case ed25519.PublicKey:
+		return signer.Sign(rand.Reader, msg, crypto.Hash(0))
+	case *rsa.PublicKey:
+		h := sha256.Sum256(msg)
+		return signer.Sign(rand.Reader, h[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256})
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, signer.Public())
+	}
+}
+
+// verify 校验握手摘要的签名
+func verify(key crypto.PublicKey, context string, digest, signature []byte) error {
+	msg := append([]byte(context), digest...)
+	switch pub := key.(type) {
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, msg, signature) {
+			return ErrBadSignature
+		}
+		return nil
+	case *rsa.PublicKey:
+		h := sha256.Sum256(msg)
+		opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+		if err := rsa.VerifyPSS(pub, crypto.SHA256, h[:], signature, opts); err != nil {
+			return ErrBadSignature
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+	}
+}
+
+// equalKey 比较两个公钥是否相同
+func equalKey(a, b crypto.PublicKey) bool {
+	k, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && k.Equal(b)
+}
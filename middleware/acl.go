@@ -0,0 +1,263 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/core"
+	"github.com/BadKid90s/chilix-msg/log"
+)
+
+// ACLEffect 规则的效果
+type ACLEffect string
+
+const (
+	// ACLAllow 允许
+	ACLAllow ACLEffect = "allow"
+	// ACLDeny 拒绝
+	ACLDeny ACLEffect = "deny"
+)
+
+// ACLRule 一条访问规则
+// 消息类型匹配 Types 中任一模式，且身份满足 Roles、Principals 和 Attributes 条件时规则生效
+type ACLRule struct {
+	// Types 消息类型模式，语法见 core.Pattern：精确 "orders.create"，通配 "orders.*"，前缀 "orders.**"
+	Types []string `json:"types"`
+	// Principals 身份ID列表，拥有其中任一ID即满足，为空时不限
+	Principals []string `json:"principals,omitempty"`
+	// Roles 角色列表，拥有其中任一角色即满足，为空时不限
+	Roles []string `json:"roles,omitempty"`
+	// Attributes 身份属性必须全部相等，为空时不限
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Effect 规则效果，默认 ACLAllow
+	Effect ACLEffect `json:"effect,omitempty"`
+}
+
+// ACLPolicy 访问控制策略
+// 规则按顺序匹配，第一条生效的规则决定结果；没有规则生效时使用 Default
+type ACLPolicy struct {
+	// Default 默认效果，默认 ACLDeny
+	Default ACLEffect `json:"default,omitempty"`
+	// Rules 规则列表
+	Rules []ACLRule `json:"rules"`
+}
+
+// aclRule 编译后的规则
+type aclRule struct {
+	ACLRule
+	patterns []core.Pattern
+}
+
+// aclPolicy 编译后的策略
+type aclPolicy struct {
+	rules        []aclRule
+	defaultAllow bool
+}
+
+// compile 校验并编译策略
+func (p ACLPolicy) compile() (*aclPolicy, error) {
+	compiled := &aclPolicy{}
+	switch p.Default {
+	case "", ACLDeny:
+	case ACLAllow:
+		compiled.defaultAllow = true
+	default:
+		return nil, fmt.Errorf("invalid default effect %q", p.Default)
+	}
+
+	for i, rule := range p.Rules {
+		switch rule.Effect {
+		case "":
+			rule.Effect = ACLAllow
+		case ACLAllow, ACLDeny:
+		default:
+			return nil, fmt.Errorf("rule %d: invalid effect %q", i, rule.Effect)
+		}
+		if len(rule.Types) == 0 {
+			return nil, fmt.Errorf("rule %d: no message types", i)
+		}
+		r := aclRule{ACLRule: rule}
+		for _, t := range rule.Types {
+			pattern, err := core.ParsePattern(t)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: %w", i, err)
+			}
+			r.patterns = append(r.patterns, pattern)
+		}
+		compiled.rules = append(compiled.rules, r)
+	}
+	return compiled, nil
+}
+
+// matches 判断规则是否对该身份和消息类型生效
+func (r *aclRule) matches(principal *core.Principal, msgType string) bool {
+	if !slices.ContainsFunc(r.patterns, func(p core.Pattern) bool { return p.Match(msgType) }) {
+		return false
+	}
+	if len(r.Principals) == 0 && len(r.Roles) == 0 && len(r.Attributes) == 0 {
+		return true
+	}
+	// 有身份条件的规则不适用于未认证的连接
+	if principal == nil {
+		return false
+	}
+	if len(r.Principals) > 0 && !slices.Contains(r.Principals, principal.ID) {
+		return false
+	}
+	if len(r.Roles) > 0 && !slices.ContainsFunc(r.Roles, principal.HasRole) {
+		return false
+	}
+	for key, want := range r.Attributes {
+		value, ok := principal.Attributes[key]
+		if !ok || fmt.Sprint(value) != want {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *aclPolicy) allowed(principal *core.Principal, msgType string) bool {
+	for i := range p.rules {
+		if p.rules[i].matches(principal, msgType) {
+			return p.rules[i].Effect == ACLAllow
+		}
+	}
+	return p.defaultAllow
+}
+
+// ACL 按消息类型授权的访问控制列表
+// 策略可以在运行时通过 Update 或 Reload 替换，已建立的连接立即使用新策略
+type ACL struct {
+	policy atomic.Pointer[aclPolicy]
+	file   string
+
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+// NewACL 根据策略创建访问控制列表
+func NewACL(policy ACLPolicy) (*ACL, error) {
+	a := &ACL{}
+	if err := a.Update(policy); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// LoadACL 从JSON策略文件创建访问控制列表，之后可以调用 Reload 或 Watch 重新加载
+// 文件内容为 ACLPolicy 的JSON格式
+func LoadACL(file string) (*ACL, error) {
+	a := &ACL{file: file}
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Update 替换策略，策略无效时保留原策略
+func (a *ACL) Update(policy ACLPolicy) error {
+	compiled, err := policy.compile()
+	if err != nil {
+		return fmt.Errorf("invalid ACL policy: %w", err)
+	}
+	a.policy.Store(compiled)
+	return nil
+}
+
+// Reload 从策略文件重新加载，文件无效时保留原策略
+func (a *ACL) Reload() error {
+	if a.file == "" {
+		return errors.New("ACL has no policy file")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	info, err := os.Stat(a.file)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(a.file)
+	if err != nil {
+		return err
+	}
+	var policy ACLPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return fmt.Errorf("failed to parse ACL file %s: %w", a.file, err)
+	}
+	if err := a.Update(policy); err != nil {
+		return err
+	}
+	a.modTime = info.ModTime()
+	return nil
+}
+
+// Watch 定时检查策略文件，修改后自动重新加载，返回停止检查的函数
+func (a *ACL) Watch(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	var once sync.Once
+
+	a.mu.Lock()
+	lastSeen := a.modTime
+	a.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				info, err := os.Stat(a.file)
+				if err != nil || info.ModTime().Equal(lastSeen) {
+					continue
+				}
+				// 每个版本的文件只尝试加载一次
+				lastSeen = info.ModTime()
+				if err := a.Reload(); err != nil {
+					// 加载失败时继续使用旧策略
+					log.Errorf("Failed to reload ACL %s: %v", a.file, err)
+				} else {
+					log.Infof("Reloaded ACL %s", a.file)
+				}
+			}
+		}
+	}()
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// Allowed 判断身份是否可以发送该类型的消息，principal 为nil表示未认证
+func (a *ACL) Allowed(principal *core.Principal, msgType string) bool {
+	return a.policy.Load().allowed(principal, msgType)
+}
+
+// ACLMiddleware 访问控制中间件
+// 使用会话中的身份（通常由 AuthMiddleware 设置）检查消息类型，拒绝时回复 core.CodePermissionDenied 错误帧。
+// 与 AuthMiddleware 一起使用时应注册在其之后，并为认证消息类型添加允许规则。
+func ACLMiddleware(acl *ACL) core.Middleware {
+	return func(next core.Handler) core.Handler {
+		return func(ctx core.Context) error {
+			principal := ctx.Session().Principal()
+			if acl.Allowed(principal, ctx.MessageType()) {
+				return next(ctx)
+			}
+
+			id := "anonymous"
+			if principal != nil {
+				id = principal.ID
+			}
+			ctx.Logger().Warnf("Permission denied: %s may not send %s", id, ctx.MessageType())
+			message := "permission denied for " + ctx.MessageType()
+			if err := ctx.ReplyError(core.CodePermissionDenied, message); err != nil {
+				ctx.Logger().Errorf("Failed to send permission error: %v", err)
+			}
+			return core.NewProtocolError(core.CodePermissionDenied, message)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACL_Allowed(t *testing.T) {
+	acl, err := NewACL(ACLPolicy{Rules: []ACLRule{
+		{Types: []string{"auth", "ping"}},
+		{Types: []string{"admin.**"}, Roles: []string{"admin"}},
+		{Types: []string{"orders.delete"}, Effect: ACLDeny},
+		{Types: []string{"orders.*"}, Roles: []string{"writer", "admin"}},
+		{Types: []string{"orders.*.get"}, Attributes: map[string]string{"tenant": "t1"}},
+		{Types: []string{"reports.daily"}, Principals: []string{"svc-reports"}},
+	}})
+	require.NoError(t, err)
+
+	admin := &core.Principal{ID: "root", Roles: []string{"admin"}}
+	writer := &core.Principal{ID: "w", Roles: []string{"writer"}}
+	tenant := &core.Principal{ID: "t", Attributes: map[string]any{"tenant": "t1"}}
+	reports := &core.Principal{ID: "svc-reports"}
+
+	tests := []struct {
+		name      string
+		principal *core.Principal
+		msgType   string
+		allowed   bool
+	}{
+		{"AnonymousAuth", nil, "auth", true},
+		{"AnonymousOther", nil, "orders.create", false},
+		{"AdminPrefix", admin, "admin.users.list", true},
+		{"WriterNotAdmin", writer, "admin.users.list", false},
+		{"WriterOrders", writer, "orders.create", true},
+		{"DenyBeforeAllow", admin, "orders.delete", false},
+		{"TenantAttribute", tenant, "orders.42.get", true},
+		{"TenantWrongShape", tenant, "orders.create", false},
+		{"PrincipalID", reports, "reports.daily", true},
+		{"OtherPrincipal", admin, "reports.daily", false},
+		{"DefaultDeny", admin, "unknown", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.allowed, acl.Allowed(tt.principal, tt.msgType))
+		})
+	}
+
+	// 默认允许
+	require.NoError(t, acl.Update(ACLPolicy{Default: ACLAllow, Rules: []ACLRule{
+		{Types: []string{"admin.**"}, Effect: ACLDeny},
+	}}))
+	assert.True(t, acl.Allowed(nil, "unknown"))
+	assert.False(t, acl.Allowed(admin, "admin.users.list"))
+}
+
+func TestACL_InvalidPolicy(t *testing.T) {
+	invalid := []ACLPolicy{
+		{Default: "maybe"},
+		{Rules: []ACLRule{{Types: []string{"a"}, Effect: "maybe"}}},
+		{Rules: []ACLRule{{}}},
+		{Rules: []ACLRule{{Types: []string{"a..b"}}}},
+	}
+	for _, policy := range invalid {
+		_, err := NewACL(policy)
+		assert.Error(t, err)
+	}
+
+	// 更新失败时保留原策略
+	acl, err := NewACL(ACLPolicy{Rules: []ACLRule{{Types: []string{"ping"}}}})
+	require.NoError(t, err)
+	assert.Error(t, acl.Update(invalid[0]))
+	assert.True(t, acl.Allowed(nil, "ping"))
+}
+
+func writeACLFile(t *testing.T, file, content string, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(file, modTime, modTime))
+}
+
+func TestACL_ReloadFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "acl.json")
+	start := time.Now().Add(-time.Hour)
+	writeACLFile(t, file, `{"rules":[{"types":["orders.*"],"roles":["writer"]}]}`, start)
+
+	acl, err := LoadACL(file)
+	require.NoError(t, err)
+	writer := &core.Principal{ID: "w", Roles: []string{"writer"}}
+	assert.True(t, acl.Allowed(writer, "orders.create"))
+
+	// 无效文件不替换当前策略
+	writeACLFile(t, file, `{"rules":[{"types":[]}]}`, start.Add(time.Minute))
+	assert.Error(t, acl.Reload())
+	assert.True(t, acl.Allowed(writer, "orders.create"))
+
+	stop := acl.Watch(10 * time.Millisecond)
+	defer stop()
+	writeACLFile(t, file, `{"rules":[{"types":["orders.*"],"effect":"deny"}]}`, start.Add(2*time.Minute))
+	assert.Eventually(t, func() bool {
+		return !acl.Allowed(writer, "orders.create")
+	}, 2*time.Second, 10*time.Millisecond)
+
+	_, err = LoadACL(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestACLMiddleware(t *testing.T) {
+	acl, err := NewACL(ACLPolicy{Rules: []ACLRule{
+		{Types: []string{"auth"}},
+		{Types: []string{"orders.**"}, Roles: []string{"admin"}},
+	}})
+	require.NoError(t, err)
+
+	client, closed := startAuthPair(t, func(p core.Processor) {
+		authServer(time.Second)(p)
+		p.Use(ACLMiddleware(acl))
+		p.RegisterHandler("orders.list", func(ctx core.Context) error {
+			return ctx.Reply([]string{"o1"})
+		})
+		p.RegisterHandler("reports.daily", func(ctx core.Context) error {
+			return ctx.Reply("report")
+		})
+	})
+
+	_, err = client.Request("auth", AuthRequest{Token: "secret-token"})
+	require.NoError(t, err)
+
+	_, err = client.Request("orders.list", nil)
+	assert.NoError(t, err)
+
+	_, err = client.Request("reports.daily", nil)
+	var protoErr *core.ProtocolError
+	require.True(t, errors.As(err, &protoErr), "unexpected error: %v", err)
+	assert.Equal(t, core.CodePermissionDenied, protoErr.Code)
+	assert.True(t, errors.Is(err, core.ErrPermissionDenied))
+
+	// 授权失败不关闭连接，策略更新后立即生效
+	require.NoError(t, acl.Update(ACLPolicy{Rules: []ACLRule{{Types: []string{"**"}}}}))
+	_, err = client.Request("reports.daily", nil)
+	assert.NoError(t, err)
+	select {
+	case <-closed:
+		t.Fatal("Connection should stay open after permission denied")
+	default:
+	}
+}
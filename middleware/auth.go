@@ -0,0 +1,259 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/core"
+)
+
+var (
+	// ErrInvalidToken 令牌格式错误、签名无效或不被认可
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired 令牌已过期或尚未生效
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// DefaultAuthMessageType 认证消息的默认类型
+const DefaultAuthMessageType = "auth"
+
+// AuthRequest 认证消息的负载
+type AuthRequest struct {
+	Token string `json:"token"`
+}
+
+// Authenticator 校验凭证并返回对应的身份
+type Authenticator interface {
+	Authenticate(token string) (*core.Principal, error)
+}
+
+// AuthenticatorFunc 函数形式的认证器
+type AuthenticatorFunc func(token string) (*core.Principal, error)
+
+func (f AuthenticatorFunc) Authenticate(token string) (*core.Principal, error) {
+	return f(token)
+}
+
+// AuthConfig 认证中间件配置
+type AuthConfig struct {
+	// Authenticator 凭证校验器，必填
+	Authenticator Authenticator
+	// MessageType 认证消息类型，默认 DefaultAuthMessageType
+	MessageType string
+	// GracePeriod 拒绝后关闭连接前的等待时间，让错误帧有机会送达，默认1秒
+	GracePeriod time.Duration
+}
+
+// AuthMiddleware 认证中间件
+// 认证通过后身份保存在会话中，可通过 ctx.Session().Principal() 获取。
+// 消息在各自的goroutine中并发处理，客户端必须等待认证结果后再发送其他消息，
+// 认证完成前处理的其他消息和认证失败都会收到 core.CodeUnauthenticated 错误帧，连接在 GracePeriod 后关闭。
+// 已认证或正在认证的连接再次发送认证消息时收到 core.CodeBadRequest 错误帧，原有身份保持不变。
+// 认证消息类型需要注册处理器，认证通过后才会调用它，例如回复认证结果。
+// 从不发送消息的连接不经过中间件，需要配合 AuthDeadline 关闭。
+func AuthMiddleware(config AuthConfig) core.Middleware {
+	if config.Authenticator == nil {
+		panic("middleware: AuthMiddleware requires an Authenticator")
+	}
+	if config.MessageType == "" {
+		config.MessageType = DefaultAuthMessageType
+	}
+	if config.GracePeriod <= 0 {
+		config.GracePeriod = time.Second
+	}
+
+	// 已被拒绝、等待关闭的会话
+	var closing sync.Map
+	// 正在认证的会话
+	var authenticating sync.Map
+
+	reject := func(ctx core.Context, message string) error {
+		session := ctx.Session()
+		if _, loaded := closing.LoadOrStore(session, struct{}{}); !loaded {
+			processor := ctx.Processor()
+			time.AfterFunc(config.GracePeriod, func() {
+				defer closing.Delete(session)
+				if err := processor.Close(); err != nil {
+					ctx.Logger().Errorf("Failed to close unauthenticated connection: %v", err)
+				}
+			})
+		}
+		if err := ctx.ReplyError(core.CodeUnauthenticated, message); err != nil {
+			ctx.Logger().Errorf("Failed to send authentication error: %v", err)
+		}
+		return core.NewProtocolError(core.CodeUnauthenticated, message)
+	}
+
+	return func(next core.Handler) core.Handler {
+		return func(ctx core.Context) error {
+			session := ctx.Session()
+			if _, rejected := closing.Load(session); rejected {
+				return reject(ctx, "connection is closing")
+			}
+
+			if ctx.MessageType() != config.MessageType {
+				if session.Principal() == nil {
+					return reject(ctx, "authentication required")
+				}
+				return next(ctx)
+			}
+
+			// 先占位再检查身份，并发的认证消息中只有一条能设置身份
+			if _, loaded := authenticating.LoadOrStore(session, struct{}{}); loaded {
+				return rejectDuplicate(ctx)
+			}
+			defer authenticating.Delete(session)
+			if session.Principal() != nil {
+				return rejectDuplicate(ctx)
+			}
+
+			var req AuthRequest
+			if err := ctx.Bind(&req); err != nil || req.Token == "" {
+				return reject(ctx, "invalid authentication message")
+			}
+			principal, err := config.Authenticator.Authenticate(req.Token)
+			if err != nil {
+				// 具体原因只记录在服务端
+				ctx.Logger().Warnf("Authentication failed from %v: %v", remoteAddr(ctx), err)
+				return reject(ctx, "invalid credentials")
+			}
+			session.SetPrincipal(principal)
+			return next(ctx)
+		}
+	}
+}
+
+// rejectDuplicate 拒绝重复的认证消息，连接保持打开
+func rejectDuplicate(ctx core.Context) error {
+	const message = "already authenticated"
+	if err := ctx.ReplyError(core.CodeBadRequest, message); err != nil {
+		ctx.Logger().Errorf("Failed to send authentication error: %v", err)
+	}
+	return core.NewProtocolError(core.CodeBadRequest, message)
+}
+
+// AuthDeadline 连接在 timeout 内没有完成认证时关闭，在创建处理器后调用，返回的函数取消检查
+func AuthDeadline(processor core.Processor, timeout time.Duration) (stop func()) {
+	timer := time.AfterFunc(timeout, func() {
+		if processor.Session().Principal() != nil {
+			return
+		}
+		processor.Logger().Warnf("Closing connection not authenticated within %v", timeout)
+		if err := processor.Close(); err != nil {
+			processor.Logger().Errorf("Failed to close unauthenticated connection: %v", err)
+		}
+	})
+	return func() { timer.Stop() }
+}
+
+// remoteAddr 返回对端地址，用于日志
+func remoteAddr(ctx core.Context) any {
+	if conn := ctx.Connection(); conn != nil {
+		return conn.RemoteAddr()
+	}
+	return "unknown"
+}
+
+// staticTokenAuthenticator 静态令牌认证器
+type staticTokenAuthenticator struct {
+	tokens []staticToken
+}
+
+type staticToken struct {
+	token     []byte
+	principal *core.Principal
+}
+
+// NewStaticTokenAuthenticator 创建静态令牌认证器
+// 令牌使用常量时间比较，适用于服务间调用等令牌数量较少的场景。
+func NewStaticTokenAuthenticator(tokens map[string]*core.Principal) Authenticator {
+	a := &staticTokenAuthenticator{}
+	for token, principal := range tokens {
+		a.tokens = append(a.tokens, staticToken{token: []byte(token), principal: principal})
+	}
+	return a
+}
+
+func (a *staticTokenAuthenticator) Authenticate(token string) (*core.Principal, error) {
+	var principal *core.Principal
+	for _, t := range a.tokens {
+		// 遍历全部令牌，避免通过耗时推断匹配位置
+		if subtle.ConstantTimeCompare(t.token, []byte(token)) == 1 {
+			principal = t.principal
+		}
+	}
+	if principal == nil {
+		return nil, ErrInvalidToken
+	}
+	return principal, nil
+}
+
+// TokenClaims HMAC令牌携带的声明
+type TokenClaims struct {
+	// Subject 身份标识，对应 Principal.ID
+	Subject string `json:"sub"`
+	// Roles 角色列表
+	Roles []string `json:"roles,omitempty"`
+	// Attributes 其他属性
+	Attributes map[string]any `json:"attrs,omitempty"`
+	// ExpiresAt 过期时间（Unix秒），0 表示不过期
+	ExpiresAt int64 `json:"exp,omitempty"`
+}
+
+// SignHMACToken 签发HMAC-SHA256令牌，格式为 base64url(声明JSON).base64url(签名)
+func SignHMACToken(secret []byte, claims TokenClaims) (string, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(hmacSign(secret, payload)), nil
+}
+
+func hmacSign(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// hmacAuthenticator HMAC签名令牌认证器
+type hmacAuthenticator struct {
+	secret []byte
+	now    func() time.Time
+}
+
+// NewHMACAuthenticator 创建校验 SignHMACToken 签发的令牌的认证器
+func NewHMACAuthenticator(secret []byte) Authenticator {
+	return &hmacAuthenticator{secret: secret, now: time.Now}
+}
+
+func (a *hmacAuthenticator) Authenticate(token string) (*core.Principal, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, hmacSign(a.secret, payload)) {
+		return nil, ErrInvalidToken
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var claims TokenClaims
+	if err := json.Unmarshal(data, &claims); err != nil || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	if claims.ExpiresAt != 0 && a.now().Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+	return &core.Principal{ID: claims.Subject, Roles: claims.Roles, Attributes: claims.Attributes}, nil
+}
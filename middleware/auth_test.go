@@ -0,0 +1,411 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/core"
+	"github.com/BadKid90s/chilix-msg/log"
+	"github.com/BadKid90s/chilix-msg/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startAuthPair 创建使用中间件的服务端处理器和客户端处理器
+// 返回的通道在客户端 Listen 退出（连接被关闭）时关闭
+func startAuthPair(t *testing.T, setup func(server core.Processor)) (core.Processor, <-chan struct{}) {
+	t.Helper()
+	return startPair(t, core.ProcessorConfig{Logger: log.NewDefaultLogger(), RequestTimeout: time.Second}, setup)
+}
+
+// startPair 使用相同的配置创建服务端处理器和客户端处理器
+func startPair(t *testing.T, config core.ProcessorConfig, setup func(server core.Processor)) (core.Processor, <-chan struct{}) {
+	t.Helper()
+	tr := transport.NewMemoryTransport()
+	listener, err := tr.Listen("")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	ready := make(chan struct{})
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		server := core.NewProcessor(conn, config)
+		setup(server)
+		close(ready)
+		_ = server.Listen()
+	}()
+
+	conn, err := tr.Dial(listener.Addr().String())
+	require.NoError(t, err)
+	client := core.NewProcessor(conn, config)
+	closed := make(chan struct{})
+	go func() {
+		_ = client.Listen()
+		close(closed)
+	}()
+	<-ready
+	t.Cleanup(func() { _ = client.Close() })
+	return client, closed
+}
+
+func authServer(grace time.Duration) func(core.Processor) {
+	return func(p core.Processor) {
+		p.Use(AuthMiddleware(AuthConfig{
+			Authenticator: NewStaticTokenAuthenticator(map[string]*core.Principal{
+				"secret-token": {ID: "alice", Roles: []string{"admin"}},
+			}),
+			GracePeriod: grace,
+		}))
+		p.RegisterHandler("auth", func(ctx core.Context) error {
+			return ctx.Reply("welcome")
+		})
+		p.RegisterHandler("whoami", func(ctx core.Context) error {
+			principal := ctx.Session().Principal()
+			return ctx.Reply(principal.ID)
+		})
+	}
+}
+
+func TestAuthMiddleware_Authenticated(t *testing.T) {
+	client, closed := startAuthPair(t, authServer(time.Second))
+
+	resp, err := client.Request("auth", AuthRequest{Token: "secret-token"})
+	require.NoError(t, err)
+	var welcome string
+	require.NoError(t, resp.Bind(&welcome))
+	assert.Equal(t, "welcome", welcome)
+
+	resp, err = client.Request("whoami", nil)
+	require.NoError(t, err)
+	var id string
+	require.NoError(t, resp.Bind(&id))
+	assert.Equal(t, "alice", id)
+
+	select {
+	case <-closed:
+		t.Fatal("Authenticated connection should stay open")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestAuthMiddleware_Rejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		msgType string
+		payload interface{}
+	}{
+		{"NotAuthenticated", "whoami", nil},
+		{"InvalidToken", "auth", AuthRequest{Token: "wrong"}},
+		{"EmptyToken", "auth", AuthRequest{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, closed := startAuthPair(t, authServer(200*time.Millisecond))
+
+			_, err := client.Request(tt.msgType, tt.payload)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, core.ErrUnauthenticated), "unexpected error: %v", err)
+
+			// 宽限期内连接仍然打开，但所有消息都被拒绝
+			_, err = client.Request("auth", AuthRequest{Token: "secret-token"})
+			assert.True(t, errors.Is(err, core.ErrUnauthenticated), "unexpected error: %v", err)
+
+			select {
+			case <-closed:
+			case <-time.After(2 * time.Second):
+				t.Fatal("Connection was not closed after grace period")
+			}
+		})
+	}
+}
+
+// 测试重复的认证消息被拒绝，原有身份保持不变
+func TestAuthMiddleware_DuplicateAuth(t *testing.T) {
+	client, closed := startAuthPair(t, authServer(time.Second))
+	_, err := client.Request("auth", AuthRequest{Token: "secret-token"})
+	require.NoError(t, err)
+
+	_, err = client.Request("auth", AuthRequest{Token: "secret-token"})
+	var protoErr *core.ProtocolError
+	require.ErrorAs(t, err, &protoErr)
+	assert.Equal(t, core.CodeBadRequest, protoErr.Code)
+
+	resp, err := client.Request("whoami", nil)
+	require.NoError(t, err)
+	var id string
+	require.NoError(t, resp.Bind(&id))
+	assert.Equal(t, "alice", id)
+
+	select {
+	case <-closed:
+		t.Fatal("Duplicate authentication should not close the connection")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestAuthDeadline(t *testing.T) {
+	t.Run("Unauthenticated", func(t *testing.T) {
+		_, closed := startAuthPair(t, func(p core.Processor) {
+			authServer(time.Second)(p)
+			AuthDeadline(p, 100*time.Millisecond)
+		})
+		select {
+		case <-closed:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Idle connection was not closed after auth deadline")
+		}
+	})
+
+	t.Run("Authenticated", func(t *testing.T) {
+		client, closed := startAuthPair(t, func(p core.Processor) {
+			authServer(time.Second)(p)
+			AuthDeadline(p, 100*time.Millisecond)
+		})
+		_, err := client.Request("auth", AuthRequest{Token: "secret-token"})
+		require.NoError(t, err)
+		select {
+		case <-closed:
+			t.Fatal("Authenticated connection should stay open")
+		case <-time.After(300 * time.Millisecond):
+		}
+	})
+}
+
+func TestStaticTokenAuthenticator(t *testing.T) {
+	a := NewStaticTokenAuthenticator(map[string]*core.Principal{
+		"token-a": {ID: "a"},
+		"token-b": {ID: "b"},
+	})
+
+	p, err := a.Authenticate("token-b")
+	require.NoError(t, err)
+	assert.Equal(t, "b", p.ID)
+
+	_, err = a.Authenticate("token-c")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = a.Authenticate("")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestHMACAuthenticator(t *testing.T) {
+	secret := []byte("hmac-secret")
+	token, err := SignHMACToken(secret, TokenClaims{
+		Subject:    "svc-orders",
+		Roles:      []string{"reader", "writer"},
+		Attributes: map[string]any{"tenant": "t1"},
+		ExpiresAt:  time.Now().Add(time.Hour).Unix(),
+	})
+	require.NoError(t, err)
+
+	a := NewHMACAuthenticator(secret)
+	p, err := a.Authenticate(token)
+	require.NoError(t, err)
+	assert.Equal(t, "svc-orders", p.ID)
+	assert.True(t, p.HasRole("writer"))
+	assert.Equal(t, "t1", p.Attributes["tenant"])
+
+	// 错误的密钥
+	_, err = NewHMACAuthenticator([]byte("other")).Authenticate(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// 篡改声明
+	forged, _ := SignHMACToken([]byte("other"), TokenClaims{Subject: "admin"})
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+	_, signature, _ := strings.Cut(token, ".")
+	_, err = a.Authenticate(forgedPayload + "." + signature)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// 过期
+	ha := a.(*hmacAuthenticator)
+	ha.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	_, err = a.Authenticate(token)
+	assert.ErrorIs(t, err, ErrTokenExpired)
+
+	_, err = a.Authenticate("not-a-token")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+// jwtSigner 测试用的JWT签发者
+type jwtSigner struct {
+	alg  string
+	kid  string
+	sign func(data []byte) []byte
+}
+
+func (s jwtSigner) token(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": s.alg, "kid": s.kid, "typ": "JWT"})
+	require.NoError(t, err)
+	body, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(s.sign([]byte(signed)))
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// newJWTFixture 生成RSA、EC和Ed25519密钥并写入JWKS文件
+func newJWTFixture(t *testing.T) (string, map[string]jwtSigner) {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	jwks := map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "alg": "RS256", "use": "sig",
+			"n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256",
+			"x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "OKP", "kid": "ed-1", "crv": "Ed25519", "x": b64(edPub)},
+		{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": "AQAB", "e": "AQAB"},
+	}}
+	data, err := json.Marshal(jwks)
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(file, data, 0o600))
+
+	signers := map[string]jwtSigner{
+		"RS256": {alg: "RS256", kid: "rsa-1", sign: func(data []byte) []byte {
+			digest := sha256.Sum256(data)
+			sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+			require.NoError(t, err)
+			return sig
+		}},
+		"ES256": {alg: "ES256", kid: "ec-1", sign: func(data []byte) []byte {
+			digest := sha256.Sum256(data)
+			r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+			require.NoError(t, err)
+			return append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}},
+		"EdDSA": {alg: "EdDSA", kid: "ed-1", sign: func(data []byte) []byte {
+			return ed25519.Sign(edKey, data)
+		}},
+	}
+	return file, signers
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	file, signers := newJWTFixture(t)
+	a, err := NewJWTAuthenticator(JWTConfig{JWKSFile: file, Issuer: "https://issuer", Audience: "chilix"})
+	require.NoError(t, err)
+
+	claims := func(extra map[string]any) map[string]any {
+		c := map[string]any{
+			"sub": "user-1",
+			"iss": "https://issuer",
+			"aud": []string{"chilix", "other"},
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+
+	for alg, signer := range signers {
+		t.Run(alg, func(t *testing.T) {
+			p, err := a.Authenticate(signer.token(t, claims(map[string]any{"roles": []string{"admin"}})))
+			require.NoError(t, err)
+			assert.Equal(t, "user-1", p.ID)
+			assert.True(t, p.HasRole("admin"))
+			assert.Equal(t, "https://issuer", p.Attributes["iss"])
+		})
+	}
+
+	rs := signers["RS256"]
+	t.Run("ScopeRoles", func(t *testing.T) {
+		b, err := NewJWTAuthenticator(JWTConfig{JWKSFile: file, RolesClaim: "scope"})
+		require.NoError(t, err)
+		p, err := b.Authenticate(rs.token(t, claims(map[string]any{"scope": "read write"})))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"read", "write"}, p.Roles)
+	})
+
+	failures := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"Expired", rs.token(t, claims(map[string]any{"exp": time.Now().Add(-time.Minute).Unix()})), ErrTokenExpired},
+		{"NotYetValid", rs.token(t, claims(map[string]any{"nbf": time.Now().Add(time.Hour).Unix()})), ErrTokenExpired},
+		{"WrongIssuer", rs.token(t, claims(map[string]any{"iss": "https://evil"})), ErrInvalidToken},
+		{"WrongAudience", rs.token(t, claims(map[string]any{"aud": "other"})), ErrInvalidToken},
+		{"MissingSubject", rs.token(t, claims(map[string]any{"sub": ""})), ErrInvalidToken},
+		{"UnknownKid", jwtSigner{alg: "RS256", kid: "nope", sign: rs.sign}.token(t, claims(nil)), ErrInvalidToken},
+		{"EncryptionKey", jwtSigner{alg: "RS256", kid: "enc-1", sign: rs.sign}.token(t, claims(nil)), ErrInvalidToken},
+		{"AlgNone", jwtSigner{alg: "none", kid: "ec-1", sign: func([]byte) []byte { return nil }}.token(t, claims(nil)), ErrInvalidToken},
+		{"AlgMismatch", jwtSigner{alg: "ES256", kid: "rsa-1", sign: rs.sign}.token(t, claims(nil)), ErrInvalidToken},
+		{"HMACConfusion", jwtSigner{alg: "HS256", kid: "ed-1", sign: rs.sign}.token(t, claims(nil)), ErrInvalidToken},
+		{"Malformed", "a.b", ErrInvalidToken},
+	}
+	for _, tt := range failures {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.Authenticate(tt.token)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	// 签名被篡改
+	token := rs.token(t, claims(nil))
+	_, err = a.Authenticate(token[:len(token)-4] + "AAAA")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+// 测试 ES 算法与密钥曲线不一致时拒绝令牌
+func TestJWTAuthenticator_CurveMismatch(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	data, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256",
+			"x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+	}})
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(file, data, 0o600))
+	a, err := NewJWTAuthenticator(JWTConfig{JWKSFile: file})
+	require.NoError(t, err)
+
+	// 使用P-256密钥和SHA-384摘要签名，声明为 ES384
+	signer := jwtSigner{alg: "ES384", kid: "ec-1", sign: func(data []byte) []byte {
+		digest := sha512.Sum384(data)
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+		require.NoError(t, err)
+		return append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}}
+	_, err = a.Authenticate(signer.token(t, map[string]any{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()}))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestNewJWTAuthenticator_Errors(t *testing.T) {
+	_, err := NewJWTAuthenticator(JWTConfig{JWKSFile: filepath.Join(t.TempDir(), "missing.json")})
+	assert.Error(t, err)
+
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"keys":[{"kty":"oct","kid":"k"}]}`), 0o600))
+	_, err = NewJWTAuthenticator(JWTConfig{JWKSFile: file})
+	assert.ErrorContains(t, err, "unsupported key type")
+}
@@ -0,0 +1,214 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/core"
+)
+
+// ErrCircuitOpen 断路器打开，请求没有发送
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState 断路器状态
+type BreakerState int
+
+const (
+	// BreakerClosed 正常发送请求
+	BreakerClosed BreakerState = iota
+	// BreakerOpen 拒绝所有请求，OpenTimeout 后转为半开
+	BreakerOpen
+	// BreakerHalfOpen 放行少量探测请求，全部成功后关闭，任一失败则重新打开
+	BreakerHalfOpen
+)
+
+var breakerStateNames = map[BreakerState]string{
+	BreakerClosed:   "closed",
+	BreakerOpen:     "open",
+	BreakerHalfOpen: "half-open",
+}
+
+func (s BreakerState) String() string {
+	if name, ok := breakerStateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("state %d", int(s))
+}
+
+// BreakerConfig 断路器配置
+type BreakerConfig struct {
+	// FailureThreshold 连续失败多少次后打开，默认5
+	FailureThreshold int
+	// OpenTimeout 打开后经过多久转为半开，默认30秒
+	OpenTimeout time.Duration
+	// HalfOpenRequests 半开状态放行的探测请求数，默认1
+	HalfOpenRequests int
+	// PerType 按 对端+消息类型 分别熔断，默认只按对端
+	PerType bool
+	// IsFailure 判断请求结果是否计为失败，默认 IsBreakerFailure
+	IsFailure func(err error) bool
+	// OnStateChange 状态变化时调用，key 为 对端 或 对端/消息类型
+	// 调用时持有断路器的锁，回调中不能调用断路器的方法
+	OnStateChange func(key string, from, to BreakerState)
+}
+
+// IsBreakerFailure 默认的失败判断：本地错误（例如超时）、对端内部错误和对端限流计为失败，
+// 对端返回的其他协议错误说明对端工作正常，不计为失败
+func IsBreakerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var protoErr *core.ProtocolError
+	if errors.As(err, &protoErr) {
+		switch protoErr.Code {
+		case core.CodeUnknown, core.CodeInternal, core.CodeRateLimited:
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// circuit 一个键的断路器状态，由 CircuitBreaker 的锁保护
+type circuit struct {
+	state      BreakerState
+	generation uint64 // 每次状态变化加一，忽略之前状态发出的请求的结果
+	failures   int
+	probes     int // 半开状态已放行的探测请求数
+	successes  int // 半开状态成功的探测请求数
+}
+
+// CircuitBreaker 断路器
+// 同一个断路器可以用于多个连接的处理器，按对端区分
+type CircuitBreaker struct {
+	config   BreakerConfig
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// NewCircuitBreaker 创建出站请求的熔断器
+func NewCircuitBreaker(config BreakerConfig) *CircuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 5
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = 30 * time.Second
+	}
+	if config.HalfOpenRequests <= 0 {
+		config.HalfOpenRequests = 1
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = IsBreakerFailure
+	}
+	return &CircuitBreaker{config: config, circuits: make(map[string]*circuit)}
+}
+
+// State 返回对端（和消息类型）当前的状态，没有 PerType 时忽略 msgType
+func (b *CircuitBreaker) State(peer, msgType string) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.circuits[b.key(peer, msgType)]; ok {
+		return c.state
+	}
+	return BreakerClosed
+}
+
+func (b *CircuitBreaker) key(peer, msgType string) string {
+	if b.config.PerType {
+		return peer + "/" + msgType
+	}
+	return peer
+}
+
+// allow 判断是否放行请求，返回放行时的状态版本
+func (b *CircuitBreaker) allow(key string) (uint64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+	switch c.state {
+	case BreakerOpen:
+		return 0, false
+	case BreakerHalfOpen:
+		if c.probes >= b.config.HalfOpenRequests {
+			return 0, false
+		}
+		c.probes++
+	}
+	return c.generation, true
+}
+
+// record 记录请求结果
+func (b *CircuitBreaker) record(key string, generation uint64, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuits[key]
+	if c.generation != generation {
+		return
+	}
+	failure := b.config.IsFailure(err)
+	switch c.state {
+	case BreakerClosed:
+		if !failure {
+			c.failures = 0
+			return
+		}
+		c.failures++
+		if c.failures >= b.config.FailureThreshold {
+			b.transition(key, c, BreakerOpen)
+		}
+	case BreakerHalfOpen:
+		if failure {
+			b.transition(key, c, BreakerOpen)
+			return
+		}
+		c.successes++
+		if c.successes >= b.config.HalfOpenRequests {
+			b.transition(key, c, BreakerClosed)
+		}
+	}
+}
+
+// transition 切换状态，打开时启动转为半开的定时器，调用方需持有锁
+func (b *CircuitBreaker) transition(key string, c *circuit, to BreakerState) {
+	from := c.state
+	*c = circuit{state: to, generation: c.generation + 1}
+	if to == BreakerOpen {
+		generation := c.generation
+		time.AfterFunc(b.config.OpenTimeout, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if c.generation == generation {
+				b.transition(key, c, BreakerHalfOpen)
+			}
+		})
+	}
+	if b.config.OnStateChange != nil {
+		b.config.OnStateChange(key, from, to)
+	}
+}
+
+// CircuitBreakerMiddleware 断路器出站中间件，只作用于请求
+// peer 标识连接的对端，例如服务地址；断路器打开时请求返回 ErrCircuitOpen，不会发送
+func CircuitBreakerMiddleware(breaker *CircuitBreaker, peer string) core.OutboundMiddleware {
+	return func(next core.OutboundHandler) core.OutboundHandler {
+		return func(msg *core.OutboundMessage) error {
+			if msg.Kind != core.OutboundRequest {
+				return next(msg)
+			}
+			key := breaker.key(peer, msg.MsgType)
+			generation, ok := breaker.allow(key)
+			if !ok {
+				return fmt.Errorf("%w: %s", ErrCircuitOpen, key)
+			}
+			err := next(msg)
+			breaker.record(key, generation, err)
+			return err
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stateRecorder 记录断路器的状态变化
+type stateRecorder struct {
+	mu          sync.Mutex
+	transitions []string
+}
+
+func (r *stateRecorder) record(key string, from, to BreakerState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.transitions = append(r.transitions, key+":"+from.String()+"->"+to.String())
+}
+
+func (r *stateRecorder) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.transitions...)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	recorder := &stateRecorder{}
+	breaker := NewCircuitBreaker(BreakerConfig{
+		FailureThreshold: 2,
+		OpenTimeout:      100 * time.Millisecond,
+		OnStateChange:    recorder.record,
+	})
+
+	var fail bool
+	calls := 0
+	handler := CircuitBreakerMiddleware(breaker, "peer-1")(func(msg *core.OutboundMessage) error {
+		calls++
+		if fail && msg.Kind == core.OutboundRequest {
+			return core.ErrRequestTimeout
+		}
+		return nil
+	})
+	request := func() error {
+		return handler(&core.OutboundMessage{Kind: core.OutboundRequest, MsgType: "ping"})
+	}
+
+	// 连续失败达到阈值后打开
+	fail = true
+	assert.ErrorIs(t, request(), core.ErrRequestTimeout)
+	assert.Equal(t, BreakerClosed, breaker.State("peer-1", "ping"))
+	assert.ErrorIs(t, request(), core.ErrRequestTimeout)
+	assert.Equal(t, BreakerOpen, breaker.State("peer-1", "ping"))
+
+	// 打开时请求不发送
+	assert.ErrorIs(t, request(), ErrCircuitOpen)
+	assert.Equal(t, 2, calls)
+	// 推送消息不经过断路器
+	require.NoError(t, handler(&core.OutboundMessage{Kind: core.OutboundSend, MsgType: "ping"}))
+
+	// 半开状态探测失败重新打开
+	require.Eventually(t, func() bool { return breaker.State("peer-1", "") == BreakerHalfOpen }, time.Second, 10*time.Millisecond)
+	assert.ErrorIs(t, request(), core.ErrRequestTimeout)
+	assert.Equal(t, BreakerOpen, breaker.State("peer-1", ""))
+
+	// 探测成功后关闭
+	require.Eventually(t, func() bool { return breaker.State("peer-1", "") == BreakerHalfOpen }, time.Second, 10*time.Millisecond)
+	fail = false
+	assert.NoError(t, request())
+	assert.Equal(t, BreakerClosed, breaker.State("peer-1", ""))
+
+	assert.Equal(t, []string{
+		"peer-1:closed->open",
+		"peer-1:open->half-open",
+		"peer-1:half-open->open",
+		"peer-1:open->half-open",
+		"peer-1:half-open->closed",
+	}, recorder.list())
+}
+
+func TestCircuitBreaker_PerType(t *testing.T) {
+	breaker := NewCircuitBreaker(BreakerConfig{FailureThreshold: 1, PerType: true})
+	handler := CircuitBreakerMiddleware(breaker, "peer-1")(func(msg *core.OutboundMessage) error {
+		if msg.MsgType == "slow" {
+			return core.ErrRequestTimeout
+		}
+		if msg.MsgType == "invalid" {
+			return core.NewProtocolError(core.CodeBadRequest, "invalid")
+		}
+		return nil
+	})
+
+	for _, msgType := range []string{"slow", "invalid", "fast"} {
+		_ = handler(&core.OutboundMessage{Kind: core.OutboundRequest, MsgType: msgType})
+	}
+	assert.Equal(t, BreakerOpen, breaker.State("peer-1", "slow"))
+	assert.Equal(t, BreakerClosed, breaker.State("peer-1", "invalid"))
+	assert.Equal(t, BreakerClosed, breaker.State("peer-1", "fast"))
+	assert.Equal(t, BreakerClosed, breaker.State("peer-2", "slow"))
+
+	err := handler(&core.OutboundMessage{Kind: core.OutboundRequest, MsgType: "slow"})
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.Contains(t, err.Error(), "peer-1/slow")
+}
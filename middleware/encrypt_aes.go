@@ -9,106 +9,68 @@ import (
 	"errors"
 	"io"
 
+	"github.com/BadKid90s/chilix-msg/codec"
 	"github.com/BadKid90s/chilix-msg/core"
-	"github.com/BadKid90s/chilix-msg/log"
 )
 
-// EncryptionMiddleware 加密中间件
-func EncryptionMiddleware(key []byte) core.Middleware {
-	// 验证密钥长度
+// ErrEncryptionRequired 处理器未启用收发管道加密
+var ErrEncryptionRequired = errors.New("encryption is not enabled on the processor")
+
+// NewEncryptor 创建AES-GCM加密器，用于 core.ProcessorConfig.Encryptor 或 Processor.SetEncryptor
+// 密钥长度不是16、24或32字节时用SHA-256派生，与 KeyFromString 一致
+// 防重放只在单个连接内有效，需要跨连接防重放时使用 handshake 包为每个连接协商密钥
+func NewEncryptor(key []byte) codec.Encryptor {
 	if len(key) != 16 && len(key) != 24 && len(key) != 32 {
-		// 如果密钥长度不正确，生成一个正确的密钥
 		key = generateKey(key)
 	}
+	encryptor, _ := codec.NewAESEncryptor(key)
+	return encryptor
+}
 
+// RequireEncryption 要求处理器启用收发管道加密的中间件
+// 加密在处理器的收发管道中完成，加密的处理器会丢弃明文帧；该中间件用于防止遗漏配置导致明文通信
+func RequireEncryption() core.Middleware {
 	return func(next core.Handler) core.Handler {
 		return func(ctx core.Context) error {
-			// 解密接收到的消息
-			decryptedData, err := decrypt(key, ctx.RawData())
-			if err != nil {
-				ctx.Logger().Errorf("Decryption failed: %v", err)
-				// 解密失败是框架层错误，直接返回error而不是业务响应
-				return err
-			}
-
-			// 更新上下文中的原始数据
-			ctx.SetRawData(decryptedData)
-
-			// 创建加密写入器
-			encryptedWriter := &encryptedWriter{
-				writer:    ctx.Writer(),
-				key:       key,
-				logger:    ctx.Logger(),
-				processor: ctx.Processor(),
+			if ctx.Processor().Encryptor() == nil {
+				ctx.Logger().Errorf("Rejected %s: %v", ctx.MessageType(), ErrEncryptionRequired)
+				return ErrEncryptionRequired
 			}
-			ctx.SetWriter(encryptedWriter)
-
-			// 调用下一个处理器
 			return next(ctx)
 		}
 	}
 }
 
-// encryptedWriter 加密写入器
-type encryptedWriter struct {
-	writer    core.Writer
-	key       []byte
-	logger    log.Logger
-	processor core.Processor
-}
-
-func (w *encryptedWriter) Write(msgType string, payload interface{}) error {
-	// 使用Processor的序列化器序列化payload
-	data, err := w.processor.Serializer().Serialize(payload)
-	if err != nil {
-		w.logger.Errorf("Failed to serialize payload: %v", err)
-		return err
-	}
-
-	// 加密数据
-	encryptedData, err := encrypt(w.key, data)
-	if err != nil {
-		w.logger.Errorf("Failed to encrypt data: %v", err)
-		return err
-	}
-
-	// 发送加密后的数据
-	return w.writer.Write(msgType, encryptedData)
-}
-
-func (w *encryptedWriter) Reply(requestID uint64, msgType string, payload interface{}) error {
-	// 使用Processor的序列化器序列化payload
-	data, err := w.processor.Serializer().Serialize(payload)
-	if err != nil {
-		w.logger.Errorf("Failed to serialize payload: %v", err)
-		return err
-	}
-
-	// 加密数据
-	encryptedData, err := encrypt(w.key, data)
-	if err != nil {
-		w.logger.Errorf("Failed to encrypt data: %v", err)
-		return err
-	}
-
-	// 发送加密后的回复
-	return w.writer.Reply(requestID, msgType, encryptedData)
+// EncryptionMiddleware 在处理器上启用AES-GCM收发管道加密并要求加密的中间件
+//
+// Deprecated: 加密已移入处理器的收发管道，请在两端设置 core.ProcessorConfig.Encryptor 为 NewEncryptor(key)，
+// 并按需使用 RequireEncryption。该中间件只在收到第一条消息时安装加密器，之前收发的帧都是明文
+func EncryptionMiddleware(key []byte) core.Middleware {
+	return EncryptionMiddlewareWithEncryptor(NewEncryptor(key))
 }
 
-func (w *encryptedWriter) Error(errorMsg string) error {
-	// 加密错误消息
-	encryptedError, err := encrypt(w.key, []byte(errorMsg))
-	if err != nil {
-		w.logger.Errorf("Failed to encrypt error message: %v", err)
-		return err
+// EncryptionMiddlewareWithEncryptor 在处理器上启用指定加密器并要求加密的中间件
+//
+// Deprecated: 请在两端设置 core.ProcessorConfig.Encryptor 或调用 Processor.SetEncryptor，并按需使用 RequireEncryption
+func EncryptionMiddlewareWithEncryptor(encryptor codec.Encryptor) core.Middleware {
+	require := RequireEncryption()
+	return func(next core.Handler) core.Handler {
+		guarded := require(next)
+		return func(ctx core.Context) error {
+			processor := ctx.Processor()
+			if processor.Encryptor() == nil {
+				processor.SetEncryptor(encryptor)
+				// 安装加密器之前读取的帧是明文，不交给业务处理器
+				ctx.Logger().Errorf("Rejected %s: %v", ctx.MessageType(), ErrEncryptionRequired)
+				return ErrEncryptionRequired
+			}
+			return guarded(ctx)
+		}
 	}
-
-	// 发送加密后的错误消息
-	return w.writer.Write("error", encryptedError)
 }
 
-// encrypt 加密函数
-func encrypt(key, data []byte) ([]byte, error) {
+// seal AES-GCM加密并认证附加数据，格式: nonce + 密文
+func seal(key, data, additionalData []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -124,11 +86,11 @@ func encrypt(key, data []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	return gcm.Seal(nonce, nonce, data, nil), nil
+	return gcm.Seal(nonce, nonce, data, additionalData), nil
 }
 
-// decrypt 解密函数
-func decrypt(key, data []byte) ([]byte, error) {
+// open AES-GCM解密并校验附加数据
+func open(key, data, additionalData []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -145,7 +107,7 @@ func decrypt(key, data []byte) ([]byte, error) {
 	}
 
 	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
-	return gcm.Open(nil, nonce, ciphertext, nil)
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
 }
 
 // generateKey 从任意长度的字节生成固定长度的密钥
@@ -4,14 +4,17 @@ import (
 	"bytes"
 	"fmt"
 	"net"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/BadKid90s/chilix-msg/codec"
 	"github.com/BadKid90s/chilix-msg/core"
 	"github.com/BadKid90s/chilix-msg/log"
 	"github.com/BadKid90s/chilix-msg/serializer"
 	"github.com/BadKid90s/chilix-msg/transport"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // MockConnection 是 transport.Connection 的模拟实现
@@ -92,13 +95,15 @@ func TestEncryptDecrypt(t *testing.T) {
 	// 测试数据
 	originalData := []byte("This is a secret message for testing")
 
+	encryptor := NewEncryptor(key)
+
 	// 加密数据
-	encryptedData, err := encrypt(key, originalData)
+	encryptedData, err := encryptor.Encrypt(originalData)
 	assert.NoError(t, err)
 	assert.NotEqual(t, originalData, encryptedData)
 
 	// 解密数据
-	decryptedData, err := decrypt(key, encryptedData)
+	decryptedData, err := encryptor.Decrypt(encryptedData)
 	assert.NoError(t, err)
 	assert.Equal(t, originalData, decryptedData)
 }
@@ -123,129 +128,148 @@ func TestKeyGeneration(t *testing.T) {
 	assert.NotEmpty(t, key4)
 }
 
-// 测试加密中间件
-func TestEncryptionMiddleware(t *testing.T) {
-	// 生成测试密钥
-	key := KeyFromString("test-key")
-
-	// 创建加密中间件
-	encryptionMiddleware := EncryptionMiddleware(key)
+// 测试未启用管道加密的处理器拒绝消息
+func TestRequireEncryption(t *testing.T) {
+	logger := log.NewDefaultLogger()
+	processor := core.NewProcessor(NewMockConnection(), core.ProcessorConfig{Logger: logger})
+	called := false
+	handler := RequireEncryption()(func(ctx core.Context) error {
+		called = true
+		return nil
+	})
+	ctx := &MockContext{msgType: "test", writer: &MockWriter{}, logger: logger, processor: processor}
 
-	// 准备测试数据
-	plainText := []byte("Secret message content")
+	assert.ErrorIs(t, handler(ctx), ErrEncryptionRequired)
+	assert.False(t, called)
 
-	// 模拟加密后的数据（作为接收到的消息）
-	encryptedData, err := encrypt(key, plainText)
-	assert.NoError(t, err)
+	processor.SetEncryptor(NewEncryptor(KeyFromString("test-key")))
+	assert.NoError(t, handler(ctx))
+	assert.True(t, called)
+}
 
-	// 创建模拟上下文
+// 测试已弃用的加密中间件在处理器上安装加密器，安装前收到的明文消息被拒绝
+func TestEncryptionMiddleware_Deprecated(t *testing.T) {
 	logger := log.NewDefaultLogger()
-	writer := &MockWriter{}
-
-	// 创建模拟processor
-	conn := NewMockConnection()
-	processor := core.NewProcessor(conn, core.ProcessorConfig{
-		Logger: logger,
-	})
-
-	ctx := &MockContext{
-		msgType:   "test",
-		requestID: 1,
-		rawData:   encryptedData,
-		writer:    writer,
-		logger:    logger,
-		processor: processor,
+	privateKey, publicKey, err := GenerateRSAKeyPair(2048)
+	require.NoError(t, err)
+	tests := []struct {
+		name       string
+		middleware core.Middleware
+	}{
+		{"aes", EncryptionMiddleware(KeyFromString("test-key"))},
+		{"rsa", RSAEncryptionMiddleware(privateKey, publicKey)},
 	}
-
-	// 创建测试处理器
-	handlerCalled := false
-	var processedData []byte
-	nextHandler := func(ctx core.Context) error {
-		handlerCalled = true
-		// 验证数据已被解密
-		processedData = ctx.RawData()
-		return nil
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := core.NewProcessor(NewMockConnection(), core.ProcessorConfig{Logger: logger})
+			called := false
+			handler := tt.middleware(func(ctx core.Context) error {
+				called = true
+				return nil
+			})
+			ctx := &MockContext{msgType: "test", writer: &MockWriter{}, logger: logger, processor: processor}
+
+			assert.ErrorIs(t, handler(ctx), ErrEncryptionRequired)
+			assert.False(t, called)
+			require.NotNil(t, processor.Encryptor())
+
+			assert.NoError(t, handler(ctx))
+			assert.True(t, called)
+		})
 	}
-
-	// 应用加密中间件
-	enhancedHandler := encryptionMiddleware(nextHandler)
-
-	// 执行处理器
-	err = enhancedHandler(ctx)
-	assert.NoError(t, err)
-	assert.True(t, handlerCalled)
-	assert.Equal(t, plainText, processedData)
 }
 
-// 测试加密写入器的Write方法
-func TestEncryptedWriter_Write(t *testing.T) {
-	// 生成测试密钥
+// 测试管道加密对 Request、Reply、Send 都生效，并且负载只序列化一次
+func TestEncryptedPipeline(t *testing.T) {
 	key := KeyFromString("test-key")
-
-	// 创建模拟writer和processor
-	mockWriter := &MockWriter{}
-	logger := log.NewDefaultLogger()
-	conn := NewMockConnection()
-	processor := core.NewProcessor(conn, core.ProcessorConfig{
-		Logger: logger,
-	})
-
-	// 创建加密写入器
-	encryptedWriter := &encryptedWriter{
-		writer:    mockWriter,
-		key:       key,
-		logger:    logger,
-		processor: processor,
+	tests := []struct {
+		name       string
+		serializer serializer.Serializer
+		payload    interface{}
+		newTarget  func() interface{}
+	}{
+		{"json", serializer.DefaultSerializer, map[string]string{"secret": "value"}, func() interface{} { return &map[string]string{} }},
+		{"binary", &serializer.BinarySerializer{}, []byte{0x00, 0xFF, 0x10}, func() interface{} { return &[]byte{} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := core.ProcessorConfig{
+				Serializer:     tt.serializer,
+				Logger:         log.NewDefaultLogger(),
+				RequestTimeout: time.Second,
+				Encryptor:      NewEncryptor(key),
+			}
+			pushed := make(chan interface{}, 1)
+			client, _ := startPair(t, config, func(server core.Processor) {
+				server.Use(RequireEncryption())
+				server.RegisterHandler("echo", func(ctx core.Context) error {
+					target := tt.newTarget()
+					if err := ctx.Bind(target); err != nil {
+						return err
+					}
+					if err := ctx.Processor().Send("push", tt.payload); err != nil {
+						return err
+					}
+					return ctx.Reply(target)
+				})
+			})
+			client.RegisterHandler("push", func(ctx core.Context) error {
+				target := tt.newTarget()
+				if err := ctx.Bind(target); err != nil {
+					return err
+				}
+				pushed <- target
+				return nil
+			})
+
+			resp, err := client.Request("echo", tt.payload)
+			require.NoError(t, err)
+			reply := tt.newTarget()
+			require.NoError(t, resp.Bind(reply))
+			assert.Equal(t, tt.payload, reflect.ValueOf(reply).Elem().Interface())
+
+			select {
+			case got := <-pushed:
+				assert.Equal(t, tt.payload, reflect.ValueOf(got).Elem().Interface())
+			case <-time.After(time.Second):
+				t.Fatal("Timed out waiting for push")
+			}
+		})
 	}
-
-	// 测试写入消息
-	testPayload := "Hello, World!"
-	err := encryptedWriter.Write("test", testPayload)
-	assert.NoError(t, err)
-	assert.Equal(t, "test", mockWriter.lastMsgType)
-	assert.NotEmpty(t, mockWriter.lastPayload)
-
-	// 验证数据已被加密（通过尝试解密来验证）
-	encryptedData, ok := mockWriter.lastPayload.([]byte)
-	assert.True(t, ok)
-
-	decryptedData, err := decrypt(key, encryptedData)
-	assert.NoError(t, err)
-
-	var decodedPayload string
-	err = serializer.DefaultSerializer.Deserialize(decryptedData, &decodedPayload)
-	assert.NoError(t, err)
-	assert.Equal(t, testPayload, decodedPayload)
 }
 
-// 测试加密写入器的Reply方法
-func TestEncryptedWriter_Reply(t *testing.T) {
-	// 生成测试密钥
+// 测试管道加密使用密钥环，发送方轮换密钥后接收方自动跟随
+func TestEncryptedPipeline_KeyRing(t *testing.T) {
 	key := KeyFromString("test-key")
-
-	// 创建模拟writer和processor
-	mockWriter := &MockWriter{}
-	logger := log.NewDefaultLogger()
-	conn := NewMockConnection()
-	processor := core.NewProcessor(conn, core.ProcessorConfig{
-		Logger: logger,
+	serverRing, err := codec.NewKeyRing(1, key, codec.KeyRingConfig{})
+	require.NoError(t, err)
+	clientRing, err := codec.NewKeyRing(1, key, codec.KeyRingConfig{})
+	require.NoError(t, err)
+
+	config := core.ProcessorConfig{Logger: log.NewDefaultLogger(), RequestTimeout: time.Second}
+	client, _ := startPair(t, config, func(server core.Processor) {
+		server.SetEncryptor(serverRing)
+		server.RegisterHandler("echo", func(ctx core.Context) error {
+			var msg string
+			if err := ctx.Bind(&msg); err != nil {
+				return err
+			}
+			return ctx.Reply(msg)
+		})
 	})
+	client.SetEncryptor(clientRing)
 
-	// 创建加密写入器
-	encryptedWriter := &encryptedWriter{
-		writer:    mockWriter,
-		key:       key,
-		logger:    logger,
-		processor: processor,
-	}
+	for _, msg := range []string{"before rotation", "after rotation"} {
+		resp, err := client.Request("echo", msg)
+		require.NoError(t, err)
+		var reply string
+		require.NoError(t, resp.Bind(&reply))
+		assert.Equal(t, msg, reply)
 
-	// 测试回复消息
-	requestID := uint64(123)
-	testPayload := "Reply message"
-	err := encryptedWriter.Reply(requestID, "test_reply", testPayload)
-	assert.NoError(t, err)
-	assert.Equal(t, "test_reply", mockWriter.lastMsgType)
-	assert.NotEmpty(t, mockWriter.lastPayload)
+		_, err = clientRing.Rotate()
+		require.NoError(t, err)
+	}
+	assert.Equal(t, uint32(2), serverRing.CurrentKey())
 }
 
 // MockContext 是 Context 接口的模拟实现
@@ -253,6 +277,7 @@ type MockContext struct {
 	msgType   string
 	requestID uint64
 	rawData   []byte
+	signed    bool
 	writer    core.Writer
 	logger    log.Logger
 	processor core.Processor
@@ -290,6 +315,14 @@ func (c *MockContext) SetRawData(data []byte) {
 	c.rawData = data
 }
 
+func (c *MockContext) Metadata() []codec.TLV {
+	return nil
+}
+
+func (c *MockContext) Signed() bool {
+	return c.signed
+}
+
 func (c *MockContext) Writer() core.Writer {
 	return c.writer
 }
@@ -309,3 +342,11 @@ func (c *MockContext) Reply(payload interface{}) error {
 func (c *MockContext) Logger() log.Logger {
 	return c.logger
 }
+
+func (c *MockContext) ReplyError(code core.ErrorCode, message string) error {
+	return c.processor.SendError(c.requestID, c.msgType, core.NewProtocolError(code, message))
+}
+
+func (c *MockContext) Session() core.Session {
+	return c.processor.Session()
+}
@@ -8,101 +8,49 @@ import (
 	"encoding/pem"
 	"errors"
 
+	"github.com/BadKid90s/chilix-msg/codec"
 	"github.com/BadKid90s/chilix-msg/core"
-	"github.com/BadKid90s/chilix-msg/log"
 )
 
-// RSAEncryptionMiddleware 非对称加密中间件
-func RSAEncryptionMiddleware(privateKey *rsa.PrivateKey, publicKey *rsa.PublicKey) core.Middleware {
-	return func(next core.Handler) core.Handler {
-		return func(ctx core.Context) error {
-			// 解密接收到的消息（使用私钥解密）
-			decryptedData, err := rsaDecrypt(privateKey, ctx.RawData())
-			if err != nil {
-				ctx.Logger().Errorf("RSA decryption failed: %v", err)
-				// RSA解密失败是框架层错误，直接返回error而不是业务响应
-				return err
-			}
-
-			// 更新上下文中的原始数据
-			ctx.SetRawData(decryptedData)
-
-			// 创建RSA加密写入器
-			rsaWriter := &rsaEncryptedWriter{
-				writer:    ctx.Writer(),
-				publicKey: publicKey,
-				logger:    ctx.Logger(),
-				processor: ctx.Processor(),
-			}
-			ctx.SetWriter(rsaWriter)
-
-			// 调用下一个处理器
-			return next(ctx)
-		}
-	}
+// rsaEncryptor RSA混合加密器，用对端公钥加密，用本端私钥解密
+type rsaEncryptor struct {
+	privateKey    *rsa.PrivateKey
+	peerPublicKey *rsa.PublicKey
 }
 
-// rsaEncryptedWriter RSA加密写入器
-type rsaEncryptedWriter struct {
-	writer    core.Writer
-	publicKey *rsa.PublicKey
-	logger    log.Logger
-	processor core.Processor
-}
+var _ codec.AEADEncryptor = (*rsaEncryptor)(nil)
 
-func (w *rsaEncryptedWriter) Write(msgType string, payload interface{}) error {
-	// 使用Processor的序列化器序列化payload
-	data, err := w.processor.Serializer().Serialize(payload)
-	if err != nil {
-		w.logger.Errorf("Failed to serialize payload: %v", err)
-		return err
-	}
-
-	// RSA加密数据
-	encryptedData, err := rsaEncrypt(w.publicKey, data)
-	if err != nil {
-		w.logger.Errorf("Failed to encrypt data with RSA: %v", err)
-		return err
-	}
-
-	// 发送加密后的数据
-	return w.writer.Write(msgType, encryptedData)
+// NewRSAEncryptor 创建RSA混合加密器，用于 core.ProcessorConfig.Encryptor 或 Processor.SetEncryptor
+// 出站消息用对端公钥加密，入站消息用本端私钥解密
+func NewRSAEncryptor(privateKey *rsa.PrivateKey, peerPublicKey *rsa.PublicKey) codec.Encryptor {
+	return &rsaEncryptor{privateKey: privateKey, peerPublicKey: peerPublicKey}
 }
 
-func (w *rsaEncryptedWriter) Reply(requestID uint64, msgType string, payload interface{}) error {
-	// 使用Processor的序列化器序列化payload
-	data, err := w.processor.Serializer().Serialize(payload)
-
-	if err != nil {
-		w.logger.Errorf("Failed to serialize payload: %v", err)
-		return err
-	}
+func (e *rsaEncryptor) Encrypt(data []byte) ([]byte, error) {
+	return rsaSeal(e.peerPublicKey, data, nil)
+}
 
-	// RSA加密数据
-	encryptedData, err := rsaEncrypt(w.publicKey, data)
-	if err != nil {
-		w.logger.Errorf("Failed to encrypt data with RSA: %v", err)
-		return err
-	}
+func (e *rsaEncryptor) Decrypt(data []byte) ([]byte, error) {
+	return rsaOpen(e.privateKey, data, nil)
+}
 
-	// 发送加密后的回复
-	return w.writer.Reply(requestID, msgType, encryptedData)
+func (e *rsaEncryptor) Seal(data, additionalData []byte) ([]byte, error) {
+	return rsaSeal(e.peerPublicKey, data, additionalData)
 }
 
-func (w *rsaEncryptedWriter) Error(errorMsg string) error {
-	// RSA加密错误消息
-	encryptedError, err := rsaEncrypt(w.publicKey, []byte(errorMsg))
-	if err != nil {
-		w.logger.Errorf("Failed to encrypt error message with RSA: %v", err)
-		return err
-	}
+func (e *rsaEncryptor) Open(data, additionalData []byte) ([]byte, error) {
+	return rsaOpen(e.privateKey, data, additionalData)
+}
 
-	// 发送加密后的错误消息
-	return w.writer.Write("error", encryptedError)
+// RSAEncryptionMiddleware 在处理器上启用RSA混合收发管道加密并要求加密的中间件
+//
+// Deprecated: 请在两端设置 core.ProcessorConfig.Encryptor 为 NewRSAEncryptor(本端私钥, 对端公钥)，并按需使用 RequireEncryption
+func RSAEncryptionMiddleware(privateKey *rsa.PrivateKey, publicKey *rsa.PublicKey) core.Middleware {
+	return EncryptionMiddlewareWithEncryptor(NewRSAEncryptor(privateKey, publicKey))
 }
 
-// rsaEncrypt RSA加密函数
-func rsaEncrypt(publicKey *rsa.PublicKey, data []byte) ([]byte, error) {
+// rsaSeal RSA混合加密，附加数据由AES-GCM认证
+func rsaSeal(publicKey *rsa.PublicKey, data, additionalData []byte) ([]byte, error) {
 	// RSA加密有长度限制，通常为密钥长度-11字节（使用PKCS#1 v1.5填充时）
 	// 因此我们使用混合加密：用RSA加密一个随机AES密钥，然后用AES加密实际数据
 
@@ -113,7 +61,7 @@ func rsaEncrypt(publicKey *rsa.PublicKey, data []byte) ([]byte, error) {
 	}
 
 	// 使用AES密钥加密数据
-	encryptedData, err := encrypt(aesKey, data)
+	encryptedData, err := seal(aesKey, data, additionalData)
 	if err != nil {
 		return nil, err
 	}
@@ -140,8 +88,8 @@ func rsaEncrypt(publicKey *rsa.PublicKey, data []byte) ([]byte, error) {
 	return result, nil
 }
 
-// rsaDecrypt RSA解密函数
-func rsaDecrypt(privateKey *rsa.PrivateKey, data []byte) ([]byte, error) {
+// rsaOpen RSA混合解密
+func rsaOpen(privateKey *rsa.PrivateKey, data, additionalData []byte) ([]byte, error) {
 	if len(data) < 4 {
 		return nil, errors.New("invalid data format")
 	}
@@ -166,7 +114,7 @@ func rsaDecrypt(privateKey *rsa.PrivateKey, data []byte) ([]byte, error) {
 	}
 
 	// 使用AES密钥解密数据
-	decryptedData, err := decrypt(aesKey, encryptedData)
+	decryptedData, err := open(aesKey, encryptedData, additionalData)
 	if err != nil {
 		return nil, err
 	}
@@ -2,69 +2,48 @@ package middleware
 
 import (
 	"testing"
+	"time"
 
+	"github.com/BadKid90s/chilix-msg/codec"
 	"github.com/BadKid90s/chilix-msg/core"
 	"github.com/BadKid90s/chilix-msg/log"
-	"github.com/BadKid90s/chilix-msg/serializer"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-// TestRSAEncryptionMiddleware 测试RSA加密中间件
-func TestRSAEncryptionMiddleware(t *testing.T) {
-	// 生成RSA密钥对
-	privateKey, publicKey, err := GenerateRSAKeyPair(2048)
+// TestRSAEncryptor 测试RSA混合加密器在收发管道中的使用，双方各自持有对端公钥
+func TestRSAEncryptor(t *testing.T) {
+	serverPrivate, serverPublic, err := GenerateRSAKeyPair(2048)
+	require.NoError(t, err)
+	clientPrivate, clientPublic, err := GenerateRSAKeyPair(2048)
 	require.NoError(t, err)
-	require.NotNil(t, privateKey)
-	require.NotNil(t, publicKey)
-
-	// 创建RSA加密中间件
-	rsaMiddleware := RSAEncryptionMiddleware(privateKey, publicKey)
-
-	// 准备测试数据
-	plainText := []byte("Secret message for RSA encryption")
-
-	// 模拟加密后的数据（作为接收到的消息）
-	encryptedData, err := rsaEncrypt(publicKey, plainText)
-	assert.NoError(t, err)
-
-	// 创建模拟上下文
-	logger := log.NewDefaultLogger()
-	writer := &MockWriter{}
 
-	// 创建模拟processor
-	conn := NewMockConnection()
-	processor := core.NewProcessor(conn, core.ProcessorConfig{
-		Logger: logger,
+	config := core.ProcessorConfig{Logger: log.NewDefaultLogger(), RequestTimeout: time.Second}
+	client, _ := startPair(t, config, func(server core.Processor) {
+		server.SetEncryptor(NewRSAEncryptor(serverPrivate, clientPublic))
+		server.Use(RequireEncryption())
+		server.RegisterHandler("echo", func(ctx core.Context) error {
+			var msg string
+			if err := ctx.Bind(&msg); err != nil {
+				return err
+			}
+			return ctx.Reply("echo: " + msg)
+		})
 	})
+	client.SetEncryptor(NewRSAEncryptor(clientPrivate, serverPublic))
 
-	ctx := &MockContext{
-		msgType:   "test",
-		requestID: 1,
-		rawData:   encryptedData,
-		writer:    writer,
-		logger:    logger,
-		processor: processor,
-	}
-
-	// 创建测试处理器
-	handlerCalled := false
-	var processedData []byte
-	nextHandler := func(ctx core.Context) error {
-		handlerCalled = true
-		// 验证数据已被解密
-		processedData = ctx.RawData()
-		return nil
-	}
-
-	// 应用RSA加密中间件
-	enhancedHandler := rsaMiddleware(nextHandler)
+	resp, err := client.Request("echo", "Secret message for RSA encryption")
+	require.NoError(t, err)
+	var reply string
+	require.NoError(t, resp.Bind(&reply))
+	assert.Equal(t, "echo: Secret message for RSA encryption", reply)
 
-	// 执行处理器
-	err = enhancedHandler(ctx)
-	assert.NoError(t, err)
-	assert.True(t, handlerCalled)
-	assert.Equal(t, plainText, processedData)
+	// 附加数据不匹配时解密失败
+	encryptor := NewRSAEncryptor(serverPrivate, serverPublic).(codec.AEADEncryptor)
+	sealed, err := encryptor.Seal([]byte("data"), []byte("header"))
+	require.NoError(t, err)
+	_, err = encryptor.Open(sealed, []byte("other"))
+	assert.Error(t, err)
 }
 
 // TestRsaEncryptDecrypt 测试RSA加解密功能
@@ -76,13 +55,15 @@ func TestRsaEncryptDecrypt(t *testing.T) {
 	// 测试数据
 	originalData := []byte("This is a test message for RSA encryption")
 
+	encryptor := NewRSAEncryptor(privateKey, publicKey)
+
 	// 加密数据
-	encryptedData, err := rsaEncrypt(publicKey, originalData)
+	encryptedData, err := encryptor.Encrypt(originalData)
 	assert.NoError(t, err)
 	assert.NotEqual(t, originalData, encryptedData)
 
 	// 解密数据
-	decryptedData, err := rsaDecrypt(privateKey, encryptedData)
+	decryptedData, err := encryptor.Decrypt(encryptedData)
 	assert.NoError(t, err)
 	assert.Equal(t, originalData, decryptedData)
 }
@@ -133,141 +114,20 @@ func TestRSAKeyExportImport(t *testing.T) {
 	assert.Equal(t, publicKey.E, importedPublicKey.E)
 }
 
-// TestRSAEncryptedWriter 测试RSA加密写入器
-func TestRSAEncryptedWriter(t *testing.T) {
-	// 生成RSA密钥对
-	privateKey, publicKey, err := GenerateRSAKeyPair(2048)
-	require.NoError(t, err)
-
-	// 创建模拟writer和processor
-	mockWriter := &MockWriter{}
-	logger := log.NewDefaultLogger()
-	conn := NewMockConnection()
-	processor := core.NewProcessor(conn, core.ProcessorConfig{
-		Logger: logger,
-	})
-
-	// 创建RSA加密写入器
-	rsaWriter := &rsaEncryptedWriter{
-		writer:    mockWriter,
-		publicKey: publicKey,
-		logger:    logger,
-		processor: processor,
-	}
-
-	// 测试写入消息
-	testPayload := "Hello, RSA World!"
-	err = rsaWriter.Write("test", testPayload)
-	assert.NoError(t, err)
-	assert.Equal(t, "test", mockWriter.lastMsgType)
-	assert.NotEmpty(t, mockWriter.lastPayload)
-
-	// 验证数据已被加密（通过尝试解密来验证）
-	encryptedData, ok := mockWriter.lastPayload.([]byte)
-	assert.True(t, ok)
-
-	decryptedData, err := rsaDecrypt(privateKey, encryptedData)
-	assert.NoError(t, err)
-
-	var decodedPayload string
-	err = serializer.DefaultSerializer.Deserialize(decryptedData, &decodedPayload)
-	assert.NoError(t, err)
-	assert.Equal(t, testPayload, decodedPayload)
-}
-
-// TestRSAEncryptedWriterReply 测试RSA加密写入器的Reply方法
-func TestRSAEncryptedWriterReply(t *testing.T) {
-	// 生成RSA密钥对
-	privateKey, publicKey, err := GenerateRSAKeyPair(2048)
-	require.NoError(t, err)
-
-	// 创建模拟writer和processor
-	mockWriter := &MockWriter{}
-	logger := log.NewDefaultLogger()
-	conn := NewMockConnection()
-	processor := core.NewProcessor(conn, core.ProcessorConfig{
-		Logger: logger,
-	})
-
-	// 创建RSA加密写入器
-	rsaWriter := &rsaEncryptedWriter{
-		writer:    mockWriter,
-		publicKey: publicKey,
-		logger:    logger,
-		processor: processor,
-	}
-
-	// 测试回复消息
-	requestID := uint64(123)
-	testPayload := "Reply message"
-	err = rsaWriter.Reply(requestID, "test_reply", testPayload)
-	assert.NoError(t, err)
-	assert.Equal(t, "test_reply", mockWriter.lastMsgType)
-	assert.NotEmpty(t, mockWriter.lastPayload)
-
-	// 验证数据已被加密（通过尝试解密来验证）
-	encryptedData, ok := mockWriter.lastPayload.([]byte)
-	assert.True(t, ok)
-
-	decryptedData, err := rsaDecrypt(privateKey, encryptedData)
-	assert.NoError(t, err)
-
-	var decodedPayload string
-	err = serializer.DefaultSerializer.Deserialize(decryptedData, &decodedPayload)
-	assert.NoError(t, err)
-	assert.Equal(t, testPayload, decodedPayload)
-}
-
-// TestRSAEncryptedWriterError 测试RSA加密写入器的Error方法
-func TestRSAEncryptedWriterError(t *testing.T) {
-	// 生成RSA密钥对
-	privateKey, publicKey, err := GenerateRSAKeyPair(2048)
-	require.NoError(t, err)
-
-	// 创建模拟writer和processor
-	mockWriter := &MockWriter{}
-	logger := log.NewDefaultLogger()
-	conn := NewMockConnection()
-	processor := core.NewProcessor(conn, core.ProcessorConfig{
-		Logger: logger,
-	})
-
-	// 创建RSA加密写入器
-	rsaWriter := &rsaEncryptedWriter{
-		writer:    mockWriter,
-		publicKey: publicKey,
-		logger:    logger,
-		processor: processor,
-	}
-
-	// 测试错误消息
-	errorMsg := "Test error message"
-	err = rsaWriter.Error(errorMsg)
-	assert.NoError(t, err)
-	assert.NotEmpty(t, mockWriter.lastPayload)
-
-	// 验证错误消息已被加密（通过尝试解密来验证）
-	encryptedData, ok := mockWriter.lastPayload.([]byte)
-	assert.True(t, ok)
-
-	decryptedData, err := rsaDecrypt(privateKey, encryptedData)
-	assert.NoError(t, err)
-	assert.Equal(t, errorMsg, string(decryptedData))
-}
-
 // TestRsaDecryptWithInvalidData 测试使用无效数据解密的情况
 func TestRsaDecryptWithInvalidData(t *testing.T) {
 	// 生成RSA密钥对
-	privateKey, _, err := GenerateRSAKeyPair(2048)
+	privateKey, publicKey, err := GenerateRSAKeyPair(2048)
 	require.NoError(t, err)
+	encryptor := NewRSAEncryptor(privateKey, publicKey)
 
 	// 测试使用太短的数据解密
-	_, err = rsaDecrypt(privateKey, []byte("short"))
+	_, err = encryptor.Decrypt([]byte("short"))
 	assert.Error(t, err)
 
 	// 测试使用格式错误的数据解密
 	invalidData := make([]byte, 100)
-	_, err = rsaDecrypt(privateKey, invalidData)
+	_, err = encryptor.Decrypt(invalidData)
 	assert.Error(t, err)
 }
 
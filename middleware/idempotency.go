@@ -0,0 +1,282 @@
+package middleware
+
+import (
+	"container/list"
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/codec"
+	"github.com/BadKid90s/chilix-msg/core"
+)
+
+// TLVTypeIdempotencyKey 客户端附带幂等键的默认元数据类型
+const TLVTypeIdempotencyKey uint8 = 0x10
+
+// IdempotencyKey 从请求中提取幂等键，返回false表示该请求不去重
+type IdempotencyKey func(ctx core.Context) (string, bool)
+
+// KeyFromMetadata 使用客户端随请求发送的元数据作为幂等键
+func KeyFromMetadata(tlvType uint8) IdempotencyKey {
+	return func(ctx core.Context) (string, bool) {
+		value, ok := core.MetadataValue(ctx.Metadata(), tlvType)
+		return string(value), ok
+	}
+}
+
+// KeyFromRequestID 使用 会话ID+请求ID 作为幂等键，用于客户端用同一个请求ID重发的场景
+// Retry 每次重试分配新的请求ID，需要使用 KeyFromMetadata
+var KeyFromRequestID IdempotencyKey = func(ctx core.Context) (string, bool) {
+	return fmt.Sprintf("%s/%d", ctx.Session().ID(), ctx.RequestID()), true
+}
+
+// IdempotentReply 缓存的响应
+type IdempotentReply struct {
+	// Payload 序列化后的响应负载
+	Payload []byte
+	// Error 处理器回复的协议错误，为nil时表示成功响应
+	Error *core.ProtocolError
+}
+
+// IdempotencyStore 已完成请求的响应存储，可以替换为共享的外部存储
+type IdempotencyStore interface {
+	// Get 返回未过期的响应
+	Get(key string) (*IdempotentReply, bool)
+	// Set 保存响应，ttl 后过期
+	Set(key string, reply *IdempotentReply, ttl time.Duration)
+}
+
+// IdempotencyConfig 幂等中间件配置
+type IdempotencyConfig struct {
+	// Key 幂等键，默认 KeyFromMetadata(TLVTypeIdempotencyKey)
+	Key IdempotencyKey
+	// Store 响应存储，默认 NewLRUStore(10000)
+	Store IdempotencyStore
+	// TTL 响应的缓存时间，默认5分钟
+	TTL time.Duration
+}
+
+// inflight 正在执行的请求，重复的请求等待其完成
+type inflight struct {
+	done  chan struct{}
+	reply *IdempotentReply
+}
+
+// Idempotency 幂等中间件，只作用于请求
+// 携带相同幂等键的请求只执行一次，之后的重复请求直接收到缓存的响应；第一个请求仍在执行时，
+// 重复的请求等待它完成。处理器没有回复（例如返回错误或panic）或回复了 IsRetryable 的临时错误
+// （例如 core.CodeRateLimited、core.CodeInternal）时不缓存，等待中的请求重新执行。
+// 幂等键按消息类型和认证身份区分，未认证的连接按会话区分，与 AuthMiddleware 一起使用时应注册在其之后。
+func Idempotency(config IdempotencyConfig) core.Middleware {
+	if config.Key == nil {
+		config.Key = KeyFromMetadata(TLVTypeIdempotencyKey)
+	}
+	if config.Store == nil {
+		config.Store = NewLRUStore(10000)
+	}
+	if config.TTL <= 0 {
+		config.TTL = 5 * time.Minute
+	}
+
+	var mu sync.Mutex
+	running := make(map[string]*inflight)
+
+	return func(next core.Handler) core.Handler {
+		return func(ctx core.Context) error {
+			if !ctx.IsRequest() {
+				return next(ctx)
+			}
+			id, ok := config.Key(ctx)
+			if !ok || id == "" {
+				return next(ctx)
+			}
+			key := idempotencyScope(ctx, id)
+
+			var call *inflight
+			for call == nil {
+				if reply, ok := config.Store.Get(key); ok {
+					return replay(ctx, reply)
+				}
+				mu.Lock()
+				if running[key] == nil {
+					call = &inflight{done: make(chan struct{})}
+					running[key] = call
+					mu.Unlock()
+					continue
+				}
+				waiting := running[key]
+				mu.Unlock()
+
+				<-waiting.done
+				if waiting.reply != nil {
+					return replay(ctx, waiting.reply)
+				}
+			}
+
+			recorder := &replyRecorder{Context: ctx}
+			defer func() {
+				reply := recorder.reply
+				if reply != nil && reply.Error != nil && IsRetryable(reply.Error) {
+					reply = nil
+				}
+				if reply != nil {
+					config.Store.Set(key, reply, config.TTL)
+				}
+				mu.Lock()
+				delete(running, key)
+				mu.Unlock()
+				call.reply = reply
+				close(call.done)
+			}()
+			return next(recorder)
+		}
+	}
+}
+
+// idempotencyScope 按消息类型和认证身份区分幂等键，避免不同客户端的键冲突
+// 未认证的连接没有共同的身份，按会话ID区分
+func idempotencyScope(ctx core.Context, id string) string {
+	owner := "session:" + ctx.Session().ID()
+	if p := ctx.Session().Principal(); p != nil {
+		owner = "principal:" + p.ID
+	}
+	return fmt.Sprintf("%s\x00%s\x00%s", ctx.MessageType(), owner, id)
+}
+
+// replay 向重复的请求发送缓存的响应
+func replay(ctx core.Context, reply *IdempotentReply) error {
+	ctx.Logger().Debugf("Replaying cached reply: msgType=%s, requestID=%d", ctx.MessageType(), ctx.RequestID())
+	if reply.Error != nil {
+		return ctx.ReplyError(reply.Error.Code, reply.Error.Message)
+	}
+	return ctx.Reply(codec.RawPayload(reply.Payload))
+}
+
+// replyRecorder 记录处理器发送的第一个响应
+type replyRecorder struct {
+	core.Context
+	mu    sync.Mutex
+	reply *IdempotentReply
+}
+
+func (r *replyRecorder) Reply(payload interface{}) error {
+	data, err := r.Processor().Serializer().Serialize(payload)
+	if err != nil {
+		return r.Context.Reply(payload)
+	}
+	r.record(&IdempotentReply{Payload: data})
+	return r.Context.Reply(codec.RawPayload(data))
+}
+
+func (r *replyRecorder) ReplyError(code core.ErrorCode, message string) error {
+	r.record(&IdempotentReply{Error: core.NewProtocolError(code, message)})
+	return r.Context.ReplyError(code, message)
+}
+
+func (r *replyRecorder) Writer() core.Writer {
+	return &recordingWriter{Writer: r.Context.Writer(), recorder: r}
+}
+
+func (r *replyRecorder) record(reply *IdempotentReply) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reply == nil {
+		r.reply = reply
+	}
+}
+
+// recordingWriter 记录通过 ctx.Writer() 发送的响应
+type recordingWriter struct {
+	core.Writer
+	recorder *replyRecorder
+}
+
+func (w *recordingWriter) Reply(requestID uint64, msgType string, payload interface{}) error {
+	if requestID != w.recorder.RequestID() {
+		return w.Writer.Reply(requestID, msgType, payload)
+	}
+	return w.recorder.Reply(payload)
+}
+
+// LRUStore 内存中的 IdempotencyStore，超出容量时淘汰最久未使用的响应
+type LRUStore struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // 前端为最近使用
+}
+
+type lruEntry struct {
+	key     string
+	reply   *IdempotentReply
+	expires time.Time
+}
+
+// NewLRUStore 创建内存中的幂等响应存储，最多保存 capacity 个响应
+func NewLRUStore(capacity int) *LRUStore {
+	if capacity <= 0 {
+		capacity = 10000
+	}
+	return &LRUStore{capacity: capacity, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+func (s *LRUStore) Get(key string) (*IdempotentReply, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		s.order.Remove(elem)
+		delete(s.entries, key)
+		return nil, false
+	}
+	s.order.MoveToFront(elem)
+	return entry.reply, true
+}
+
+func (s *LRUStore) Set(key string, reply *IdempotentReply, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := &lruEntry{key: key, reply: reply, expires: time.Now().Add(ttl)}
+	if elem, ok := s.entries[key]; ok {
+		elem.Value = entry
+		s.order.MoveToFront(elem)
+		return
+	}
+	s.entries[key] = s.order.PushFront(entry)
+	for s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Len 返回缓存的响应数，包括尚未清理的过期响应
+func (s *LRUStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// IdempotencyKeys 为每个请求生成随机的幂等键，作为 tlvType 元数据发送
+// 注册在 Retry 和 Hedge 之前，重试和对冲请求使用相同的幂等键；已经设置幂等键的请求保持不变
+func IdempotencyKeys(tlvType uint8) core.OutboundMiddleware {
+	return func(next core.OutboundHandler) core.OutboundHandler {
+		return func(msg *core.OutboundMessage) error {
+			if msg.Kind == core.OutboundRequest {
+				if _, ok := core.MetadataValue(msg.Metadata, tlvType); !ok {
+					key := make([]byte, 16)
+					if _, err := rand.Read(key); err != nil {
+						return err
+					}
+					msg.SetMetadata(tlvType, key)
+				}
+			}
+			return next(msg)
+		}
+	}
+}
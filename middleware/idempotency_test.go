@@ -0,0 +1,188 @@
+package middleware
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/core"
+	"github.com/BadKid90s/chilix-msg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keyFromPayload 使用字符串负载作为幂等键
+func keyFromPayload(next core.OutboundHandler) core.OutboundHandler {
+	return func(msg *core.OutboundMessage) error {
+		if key, ok := msg.Payload.(string); ok && msg.Kind == core.OutboundRequest {
+			msg.SetMetadata(TLVTypeIdempotencyKey, []byte(key))
+		}
+		return next(msg)
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	var executions atomic.Int32
+	client, _ := startAuthPair(t, func(server core.Processor) {
+		server.Use(Idempotency(IdempotencyConfig{}))
+		server.RegisterHandler("charge", func(ctx core.Context) error {
+			n := executions.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			return ctx.Reply(map[string]int32{"charge": n})
+		})
+		server.RegisterHandler("refund", func(ctx core.Context) error {
+			executions.Add(1)
+			return ctx.ReplyError(core.CodeBadRequest, "nothing to refund")
+		})
+		server.RegisterHandler("busy", func(ctx core.Context) error {
+			if executions.Add(1) == 1 {
+				return ctx.ReplyError(core.CodeRateLimited, "try again")
+			}
+			return ctx.Reply("ok")
+		})
+		server.RegisterHandler("flaky", func(ctx core.Context) error {
+			if executions.Add(1) == 1 {
+				return errors.New("no reply")
+			}
+			return ctx.Writer().Reply(ctx.RequestID(), ctx.MessageType(), "ok")
+		})
+	})
+	client.UseOutbound(keyFromPayload)
+
+	charge := func(key string) int32 {
+		resp, err := client.Request("charge", key)
+		require.NoError(t, err)
+		var reply map[string]int32
+		require.NoError(t, resp.Bind(&reply))
+		return reply["charge"]
+	}
+
+	// 并发的重复请求等待第一个请求完成
+	var wg sync.WaitGroup
+	results := make([]int32, 3)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = charge("key-1")
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, []int32{1, 1, 1}, results)
+
+	// 之后的重复请求直接收到缓存的响应，不同的幂等键和没有幂等键的请求正常执行
+	assert.Equal(t, int32(1), charge("key-1"))
+	assert.Equal(t, int32(2), charge("key-2"))
+	_, err := client.Request("charge", nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), executions.Load())
+
+	// 协议错误响应同样缓存
+	executions.Store(0)
+	for i := 0; i < 2; i++ {
+		_, err = client.Request("refund", "key-1")
+		assert.True(t, errors.Is(err, core.NewProtocolError(core.CodeBadRequest, "")), "unexpected error: %v", err)
+	}
+	assert.Equal(t, int32(1), executions.Load())
+
+	// 临时错误不缓存，重试时重新执行
+	executions.Store(0)
+	_, err = client.Request("busy", "key-1")
+	assert.ErrorIs(t, err, core.ErrRateLimited)
+	for i := 0; i < 2; i++ {
+		_, err = client.Request("busy", "key-1")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), executions.Load())
+
+	// 没有回复时不缓存
+	executions.Store(0)
+	_, err = client.Request("flaky", "key-1")
+	assert.ErrorIs(t, err, core.ErrRequestTimeout)
+	for i := 0; i < 2; i++ {
+		resp, err := client.Request("flaky", "key-1")
+		require.NoError(t, err)
+		var reply string
+		require.NoError(t, resp.Bind(&reply))
+		assert.Equal(t, "ok", reply)
+	}
+	assert.Equal(t, int32(2), executions.Load())
+}
+
+// 测试超时重试的请求不会重复执行
+func TestIdempotency_Retry(t *testing.T) {
+	var executions atomic.Int32
+	config := core.ProcessorConfig{Logger: log.NewDefaultLogger(), RequestTimeout: 100 * time.Millisecond}
+	client, _ := startPair(t, config, func(server core.Processor) {
+		server.Use(Idempotency(IdempotencyConfig{}))
+		server.RegisterHandler("orders.create", func(ctx core.Context) error {
+			executions.Add(1)
+			time.Sleep(150 * time.Millisecond)
+			return ctx.Reply("created")
+		})
+	})
+
+	retry, err := Retry(map[string]RetryPolicy{"orders.create": {MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond}})
+	require.NoError(t, err)
+	client.UseOutbound(IdempotencyKeys(TLVTypeIdempotencyKey))
+	client.UseOutbound(retry)
+
+	resp, err := client.Request("orders.create", nil)
+	require.NoError(t, err)
+	var reply string
+	require.NoError(t, resp.Bind(&reply))
+	assert.Equal(t, "created", reply)
+	assert.Equal(t, int32(1), executions.Load())
+}
+
+// 测试未认证的连接使用相同的幂等键时互不影响
+func TestIdempotency_AnonymousScope(t *testing.T) {
+	store := NewLRUStore(10)
+	setup := func(server core.Processor) {
+		server.Use(Idempotency(IdempotencyConfig{Store: store}))
+		server.RegisterHandler("whoami", func(ctx core.Context) error {
+			return ctx.Reply(ctx.Session().ID())
+		})
+	}
+	first, _ := startAuthPair(t, setup)
+	second, _ := startAuthPair(t, setup)
+	first.UseOutbound(keyFromPayload)
+	second.UseOutbound(keyFromPayload)
+
+	whoami := func(client core.Processor) string {
+		resp, err := client.Request("whoami", "same-key")
+		require.NoError(t, err)
+		var id string
+		require.NoError(t, resp.Bind(&id))
+		return id
+	}
+	a, b := whoami(first), whoami(second)
+	assert.NotEmpty(t, a)
+	assert.NotEqual(t, a, b)
+	assert.Equal(t, a, whoami(first))
+	assert.Equal(t, 2, store.Len())
+}
+
+func TestLRUStore(t *testing.T) {
+	store := NewLRUStore(2)
+	store.Set("a", &IdempotentReply{Payload: []byte("a")}, time.Minute)
+	store.Set("b", &IdempotentReply{Payload: []byte("b")}, time.Minute)
+
+	// 读取 a 后 b 成为最久未使用的响应
+	_, ok := store.Get("a")
+	assert.True(t, ok)
+	store.Set("c", &IdempotentReply{Payload: []byte("c")}, time.Minute)
+	_, ok = store.Get("b")
+	assert.False(t, ok)
+	reply, ok := store.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, []byte("a"), reply.Payload)
+	assert.Equal(t, 2, store.Len())
+
+	store.Set("d", &IdempotentReply{}, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	_, ok = store.Get("d")
+	assert.False(t, ok, "expired")
+}
@@ -0,0 +1,283 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/core"
+)
+
+// RateLimitKey 计算限流维度的键，键相同的消息共享一个令牌桶
+// 返回值作为map的键，必须可比较；返回nil表示该消息不限流
+type RateLimitKey func(ctx core.Context) any
+
+// 常用的限流维度
+var (
+	// KeyByConnection 每个连接一个令牌桶
+	KeyByConnection RateLimitKey = func(ctx core.Context) any { return ctx.Session() }
+	// KeyByPrincipal 每个认证身份一个令牌桶，同一身份的多个连接共享；未认证的连接按连接限流
+	KeyByPrincipal RateLimitKey = func(ctx core.Context) any {
+		if principal := ctx.Session().Principal(); principal != nil {
+			return "principal:" + principal.ID
+		}
+		return ctx.Session()
+	}
+	// KeyByMessageType 每个消息类型一个令牌桶，所有连接共享
+	KeyByMessageType RateLimitKey = func(ctx core.Context) any { return "type:" + ctx.MessageType() }
+)
+
+// RateLimitAction 超出限制时的动作
+type RateLimitAction int
+
+const (
+	// RateLimitReject 回复 core.CodeRateLimited 错误帧，不调用处理器
+	RateLimitReject RateLimitAction = iota
+	// RateLimitDelay 等待令牌补充后再调用处理器，需要等待的时间超过 MaxDelay 时按拒绝处理
+	RateLimitDelay
+)
+
+// RateLimitConfig 限流配置
+type RateLimitConfig struct {
+	// Rate 每秒补充的令牌数，必须大于0
+	Rate float64
+	// Burst 令牌桶容量，即允许的突发消息数，默认为 Rate 向上取整
+	Burst int
+	// Key 限流维度，默认 KeyByConnection
+	Key RateLimitKey
+	// Action 超出限制时的动作，默认 RateLimitReject
+	Action RateLimitAction
+	// MaxDelay RateLimitDelay 的最长等待时间，默认1秒
+	MaxDelay time.Duration
+	// MaxViolations 同一个连接在 ViolationWindow 内被拒绝的次数达到该值时关闭该连接，0表示不关闭
+	// 按连接计数，按身份或消息类型限流时只关闭反复超限的连接
+	MaxViolations int
+	// ViolationWindow 超限次数的统计窗口，从窗口内第一次超限开始计时，过期后重新计数，默认1分钟
+	ViolationWindow time.Duration
+	// GracePeriod 关闭连接前的等待时间，让错误帧有机会送达，默认1秒
+	GracePeriod time.Duration
+	// IdleTimeout 令牌桶空闲超过该时间后被清理，默认10分钟
+	IdleTimeout time.Duration
+}
+
+// rateLimit 当前生效的速率和容量
+type rateLimit struct {
+	rate  float64
+	burst float64
+}
+
+// tokenBucket 令牌桶，令牌数可以为负，表示已被 RateLimitDelay 预留
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// violation 连接在当前统计窗口内的超限次数
+type violation struct {
+	count int
+	start time.Time
+}
+
+// RateLimiter 令牌桶限流器
+// 同一个限流器可以用于多个连接的处理器，按身份和消息类型限流时应共享
+type RateLimiter struct {
+	config RateLimitConfig
+	limit  atomic.Pointer[rateLimit]
+
+	mu         sync.Mutex
+	buckets    map[any]*tokenBucket
+	violations map[core.Session]violation
+	lastSweep  time.Time
+	closing    sync.Map // 因超限被关闭、等待关闭的会话
+}
+
+// NewRateLimiter 创建令牌桶限流器
+func NewRateLimiter(config RateLimitConfig) (*RateLimiter, error) {
+	if config.Key == nil {
+		config.Key = KeyByConnection
+	}
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = time.Second
+	}
+	if config.GracePeriod <= 0 {
+		config.GracePeriod = time.Second
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = 10 * time.Minute
+	}
+	if config.ViolationWindow <= 0 {
+		config.ViolationWindow = time.Minute
+	}
+	l := &RateLimiter{
+		config:     config,
+		buckets:    make(map[any]*tokenBucket),
+		violations: make(map[core.Session]violation),
+		lastSweep:  time.Now(),
+	}
+	if err := l.SetLimit(config.Rate, config.Burst); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// SetLimit 调整速率和容量，对已有的令牌桶立即生效
+func (l *RateLimiter) SetLimit(rate float64, burst int) error {
+	if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+		return fmt.Errorf("invalid rate limit %v", rate)
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+	l.limit.Store(&rateLimit{rate: rate, burst: float64(burst)})
+	return nil
+}
+
+// Limit 返回当前的速率和容量
+func (l *RateLimiter) Limit() (rate float64, burst int) {
+	limit := l.limit.Load()
+	return limit.rate, int(limit.burst)
+}
+
+// Allow 从键对应的令牌桶取一个令牌，成功时返回true
+func (l *RateLimiter) Allow(key any) bool {
+	wait, ok := l.reserve(key, 0)
+	return ok && wait == 0
+}
+
+// reserve 取一个令牌，令牌不足时预留一个之后补充的令牌并返回需要等待的时间
+// 等待时间超过 maxWait 时不预留，返回false
+func (l *RateLimiter) reserve(key any, maxWait time.Duration) (time.Duration, bool) {
+	limit := l.limit.Load()
+	now := time.Now()
+	b := l.bucket(key, now, limit)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(limit.burst, b.tokens+now.Sub(b.last).Seconds()*limit.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	wait := time.Duration((1 - b.tokens) / limit.rate * float64(time.Second))
+	if wait > maxWait {
+		return 0, false
+	}
+	b.tokens--
+	return wait, true
+}
+
+// violate 记录连接的一次超限，返回当前统计窗口内的次数
+func (l *RateLimiter) violate(session core.Session) int {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	v := l.violations[session]
+	if now.Sub(v.start) > l.config.ViolationWindow {
+		v = violation{start: now}
+	}
+	v.count++
+	l.violations[session] = v
+	return v.count
+}
+
+// bucket 获取键对应的令牌桶，新的令牌桶是满的；顺便清理空闲的令牌桶和过期的超限记录
+func (l *RateLimiter) bucket(key any, now time.Time, limit *rateLimit) *tokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) > l.config.IdleTimeout {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: limit.burst, last: now}
+		l.buckets[key] = b
+	}
+	return b
+}
+
+// sweep 清理空闲的令牌桶和过期的超限记录，调用方需持有锁
+func (l *RateLimiter) sweep(now time.Time) {
+	for k, b := range l.buckets {
+		b.mu.Lock()
+		idle := now.Sub(b.last) > l.config.IdleTimeout
+		b.mu.Unlock()
+		if idle {
+			delete(l.buckets, k)
+		}
+	}
+	for session, v := range l.violations {
+		if now.Sub(v.start) > l.config.ViolationWindow {
+			delete(l.violations, session)
+		}
+	}
+	l.lastSweep = now
+}
+
+// RateLimit 限流中间件
+// 超出限制的消息收到 core.CodeRateLimited 错误帧（RateLimitDelay 在等待超过 MaxDelay 时同样拒绝），
+// 设置 MaxViolations 时同一个连接在 ViolationWindow 内被拒绝的次数达到该值后关闭该连接。
+// 与 AuthMiddleware 一起按身份限流时应注册在其之后。
+func RateLimit(limiter *RateLimiter) core.Middleware {
+	config := limiter.config
+	maxWait := time.Duration(0)
+	if config.Action == RateLimitDelay {
+		maxWait = config.MaxDelay
+	}
+
+	return func(next core.Handler) core.Handler {
+		return func(ctx core.Context) error {
+			session := ctx.Session()
+			if _, closing := limiter.closing.Load(session); closing {
+				return limiter.reject(ctx, "connection is closing")
+			}
+			key := config.Key(ctx)
+			if key == nil {
+				return next(ctx)
+			}
+
+			wait, ok := limiter.reserve(key, maxWait)
+			if ok {
+				if wait > 0 {
+					time.Sleep(wait)
+				}
+				return next(ctx)
+			}
+
+			ctx.Logger().Warnf("Rate limit exceeded: %s from %v", ctx.MessageType(), remoteAddr(ctx))
+			if config.MaxViolations > 0 && limiter.violate(session) >= config.MaxViolations {
+				limiter.disconnect(ctx)
+			}
+			return limiter.reject(ctx, "rate limit exceeded for "+ctx.MessageType())
+		}
+	}
+}
+
+func (l *RateLimiter) reject(ctx core.Context, message string) error {
+	if err := ctx.ReplyError(core.CodeRateLimited, message); err != nil {
+		ctx.Logger().Errorf("Failed to send rate limit error: %v", err)
+	}
+	return core.NewProtocolError(core.CodeRateLimited, message)
+}
+
+// disconnect 在 GracePeriod 后关闭当前连接
+func (l *RateLimiter) disconnect(ctx core.Context) {
+	session := ctx.Session()
+	if _, loaded := l.closing.LoadOrStore(session, struct{}{}); loaded {
+		return
+	}
+	ctx.Logger().Warnf("Closing connection from %v: too many rate limit violations", remoteAddr(ctx))
+	processor := ctx.Processor()
+	time.AfterFunc(l.config.GracePeriod, func() {
+		defer l.closing.Delete(session)
+		l.mu.Lock()
+		delete(l.violations, session)
+		l.mu.Unlock()
+		if err := processor.Close(); err != nil {
+			ctx.Logger().Errorf("Failed to close rate limited connection: %v", err)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/core"
+	"github.com/BadKid90s/chilix-msg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rateLimitServer(t *testing.T, config RateLimitConfig) (func(core.Processor), *RateLimiter) {
+	t.Helper()
+	limiter, err := NewRateLimiter(config)
+	require.NoError(t, err)
+	return func(server core.Processor) {
+		server.Use(RateLimit(limiter))
+		server.RegisterHandler("ping", func(ctx core.Context) error {
+			return ctx.Reply("pong")
+		})
+		server.RegisterHandler("other", func(ctx core.Context) error {
+			return ctx.Reply("ok")
+		})
+	}, limiter
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimitConfig{Rate: 10, Burst: 2})
+	require.NoError(t, err)
+
+	assert.True(t, limiter.Allow("a"))
+	assert.True(t, limiter.Allow("a"))
+	assert.False(t, limiter.Allow("a"), "burst exhausted")
+	assert.True(t, limiter.Allow("b"), "keys have separate buckets")
+
+	time.Sleep(150 * time.Millisecond)
+	assert.True(t, limiter.Allow("a"), "tokens refilled")
+
+	// 运行时调整对已有的令牌桶生效
+	require.NoError(t, limiter.SetLimit(1000, 5))
+	rate, burst := limiter.Limit()
+	assert.Equal(t, 1000.0, rate)
+	assert.Equal(t, 5, burst)
+	time.Sleep(10 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		assert.True(t, limiter.Allow("a"))
+	}
+
+	assert.Error(t, limiter.SetLimit(0, 1))
+	_, err = NewRateLimiter(RateLimitConfig{})
+	assert.Error(t, err)
+}
+
+func TestRateLimit_Reject(t *testing.T) {
+	setup, limiter := rateLimitServer(t, RateLimitConfig{Rate: 1, Burst: 2})
+	client, _ := startAuthPair(t, setup)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.Request("ping", nil)
+		require.NoError(t, err)
+	}
+	_, err := client.Request("ping", nil)
+	assert.True(t, errors.Is(err, core.ErrRateLimited), "unexpected error: %v", err)
+
+	require.NoError(t, limiter.SetLimit(1000, 2))
+	time.Sleep(10 * time.Millisecond)
+	_, err = client.Request("ping", nil)
+	assert.NoError(t, err)
+}
+
+func TestRateLimit_KeyByMessageType(t *testing.T) {
+	setup, _ := rateLimitServer(t, RateLimitConfig{Rate: 1, Burst: 1, Key: KeyByMessageType})
+	client, _ := startAuthPair(t, setup)
+
+	_, err := client.Request("ping", nil)
+	require.NoError(t, err)
+	_, err = client.Request("other", nil)
+	require.NoError(t, err)
+	_, err = client.Request("ping", nil)
+	assert.True(t, errors.Is(err, core.ErrRateLimited), "unexpected error: %v", err)
+}
+
+func TestRateLimit_Delay(t *testing.T) {
+	setup, _ := rateLimitServer(t, RateLimitConfig{Rate: 10, Burst: 1, Action: RateLimitDelay, MaxDelay: 500 * time.Millisecond})
+	client, _ := startAuthPair(t, setup)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := client.Request("ping", nil)
+		require.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond, "requests beyond burst are delayed")
+}
+
+func TestRateLimit_Disconnect(t *testing.T) {
+	setup, _ := rateLimitServer(t, RateLimitConfig{Rate: 0.1, Burst: 1, MaxViolations: 2, GracePeriod: 100 * time.Millisecond})
+	config := core.ProcessorConfig{Logger: log.NewDefaultLogger(), RequestTimeout: time.Second}
+	client, closed := startPair(t, config, setup)
+
+	_, err := client.Request("ping", nil)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = client.Request("ping", nil)
+		assert.True(t, errors.Is(err, core.ErrRateLimited), "unexpected error: %v", err)
+	}
+
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Connection was not closed after too many violations")
+	}
+}
+
+// 测试超限次数按连接统计，并在统计窗口过期后重新计数
+func TestRateLimiter_Violations(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimitConfig{Rate: 1, ViolationWindow: 50 * time.Millisecond})
+	require.NoError(t, err)
+	logger := log.NewDefaultLogger()
+	a := core.NewProcessor(NewMockConnection(), core.ProcessorConfig{Logger: logger}).Session()
+	b := core.NewProcessor(NewMockConnection(), core.ProcessorConfig{Logger: logger}).Session()
+
+	assert.Equal(t, 1, limiter.violate(a))
+	assert.Equal(t, 2, limiter.violate(a))
+	assert.Equal(t, 1, limiter.violate(b))
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, 1, limiter.violate(a))
+}
+
+// 测试共享的令牌桶只关闭反复超限的连接
+func TestRateLimit_DisconnectPerConnection(t *testing.T) {
+	setup, _ := rateLimitServer(t, RateLimitConfig{Rate: 0.1, Burst: 1, Key: KeyByMessageType, MaxViolations: 2, GracePeriod: 100 * time.Millisecond})
+	config := core.ProcessorConfig{Logger: log.NewDefaultLogger(), RequestTimeout: time.Second}
+	first, firstClosed := startPair(t, config, setup)
+	second, secondClosed := startPair(t, config, setup)
+
+	_, err := first.Request("ping", nil)
+	require.NoError(t, err)
+	_, err = first.Request("ping", nil)
+	assert.True(t, errors.Is(err, core.ErrRateLimited), "unexpected error: %v", err)
+	_, err = second.Request("ping", nil)
+	assert.True(t, errors.Is(err, core.ErrRateLimited), "unexpected error: %v", err)
+
+	select {
+	case <-firstClosed:
+		t.Fatal("Connection with one violation should stay open")
+	case <-secondClosed:
+		t.Fatal("Connection with one violation should stay open")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import "github.com/BadKid90s/chilix-msg/core"
+
+// Recovery 将内层处理器的panic转换为 *core.PanicError 返回
+// 处理器分发时总会恢复panic；把该中间件注册在日志、指标等中间件之后，外层中间件就能把panic当作错误观察到。
+// 处理器对返回的 *core.PanicError 与直接panic的处理相同：记录调用栈、回复 CodeInternal 错误帧并计数
+func Recovery() core.Middleware {
+	return func(next core.Handler) core.Handler {
+		return func(ctx core.Context) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = core.NewPanicError(r)
+				}
+			}()
+			return next(ctx)
+		}
+	}
+}
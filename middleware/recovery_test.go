@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/core"
+	"github.com/BadKid90s/chilix-msg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试外层中间件能观察到被转换为错误的panic
+func TestRecovery(t *testing.T) {
+	observed := make(chan error, 1)
+	config := core.ProcessorConfig{Logger: log.NewDefaultLogger(), RequestTimeout: time.Second}
+	client, _ := startPair(t, config, func(server core.Processor) {
+		server.Use(func(next core.Handler) core.Handler {
+			return func(ctx core.Context) error {
+				err := next(ctx)
+				observed <- err
+				return err
+			}
+		})
+		server.Use(Recovery())
+		server.RegisterHandler("boom", func(ctx core.Context) error {
+			panic("boom")
+		})
+	})
+
+	_, err := client.Request("boom", nil)
+	assert.True(t, errors.Is(err, core.ErrInternal), "got %v", err)
+
+	var panicErr *core.PanicError
+	assert.True(t, errors.As(<-observed, &panicErr))
+	assert.Equal(t, "boom", panicErr.Value)
+}
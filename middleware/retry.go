@@ -0,0 +1,248 @@
+package middleware
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/core"
+)
+
+// RetryPolicy 请求的重试策略，只应用于幂等的消息类型
+type RetryPolicy struct {
+	// MaxAttempts 最多尝试次数，包含第一次，默认3
+	MaxAttempts int
+	// InitialBackoff 第一次重试前的等待时间，默认100毫秒
+	InitialBackoff time.Duration
+	// MaxBackoff 等待时间上限，默认2秒
+	MaxBackoff time.Duration
+	// Multiplier 每次重试等待时间的倍数，默认2
+	Multiplier float64
+	// Jitter 等待时间的随机浮动比例，取值0到1，默认0
+	Jitter float64
+	// Retryable 判断错误是否可以重试，默认 IsRetryable
+	Retryable func(err error) bool
+	// OnRetry 每次重试前调用，attempt 为即将进行的尝试序号（从2开始）
+	OnRetry func(msg *core.OutboundMessage, attempt int, err error, backoff time.Duration)
+}
+
+// HedgePolicy 对冲请求策略，用于延迟敏感的幂等请求
+// 请求在 Delay 内没有结果时再发送一份，最先成功的响应生效
+type HedgePolicy struct {
+	// Delay 发送下一份请求前的等待时间，默认50毫秒
+	Delay time.Duration
+	// MaxHedges 除第一份外最多再发送的份数，默认1
+	MaxHedges int
+	// OnHedge 每次发送对冲请求前调用，attempt 为请求序号（从2开始）
+	OnHedge func(msg *core.OutboundMessage, attempt int)
+}
+
+// IsRetryable 默认的可重试错误：请求超时、对端限流和对端内部错误
+func IsRetryable(err error) bool {
+	return errors.Is(err, core.ErrRequestTimeout) || errors.Is(err, core.ErrRateLimited) || errors.Is(err, core.ErrInternal)
+}
+
+// typePolicy 消息类型模式和对应的策略
+type typePolicy[T any] struct {
+	pattern core.Pattern
+	policy  T
+}
+
+// typePolicies 按消息类型模式查找策略，具体程度高的模式优先
+type typePolicies[T any] []typePolicy[T]
+
+func compilePolicies[T any](policies map[string]T) (typePolicies[T], error) {
+	compiled := make(typePolicies[T], 0, len(policies))
+	for raw, policy := range policies {
+		pattern, err := core.ParsePattern(raw)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, typePolicy[T]{pattern: pattern, policy: policy})
+	}
+	slices.SortFunc(compiled, func(a, b typePolicy[T]) int {
+		if c := cmp.Compare(b.pattern.Specificity(), a.pattern.Specificity()); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.pattern.String(), b.pattern.String())
+	})
+	return compiled, nil
+}
+
+func (p typePolicies[T]) match(msgType string) (T, bool) {
+	for _, entry := range p {
+		if entry.pattern.Match(msgType) {
+			return entry.policy, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// Retry 请求重试出站中间件
+// policies 的键为消息类型或模式（语法见 core.Pattern），没有匹配策略的请求和非请求消息不重试。
+// 每次重试使用新的请求ID；与 CircuitBreakerMiddleware 一起使用时应注册在其之前，断路器打开后不再重试。
+// 处理器在退避期间关闭时停止重试，返回最后一次的错误。
+func Retry(policies map[string]RetryPolicy) (core.OutboundMiddleware, error) {
+	compiled, err := compilePolicies(policies)
+	if err != nil {
+		return nil, err
+	}
+	for i := range compiled {
+		compiled[i].policy = compiled[i].policy.withDefaults()
+	}
+
+	return func(next core.OutboundHandler) core.OutboundHandler {
+		return func(msg *core.OutboundMessage) error {
+			policy, ok := compiled.match(msg.MsgType)
+			if msg.Kind != core.OutboundRequest || !ok {
+				return next(msg)
+			}
+
+			err := next(msg)
+			for attempt := 2; err != nil && attempt <= policy.MaxAttempts && policy.Retryable(err); attempt++ {
+				backoff := policy.backoff(attempt - 1)
+				if policy.OnRetry != nil {
+					policy.OnRetry(msg, attempt, err, backoff)
+				}
+				if !wait(msg.Done, backoff) {
+					return err
+				}
+				err = next(msg)
+			}
+			return err
+		}
+	}, nil
+}
+
+// wait 等待 d，done 先关闭时返回 false
+func wait(done <-chan struct{}, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 100 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 2 * time.Second
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+	p.Jitter = math.Min(math.Max(p.Jitter, 0), 1)
+	if p.Retryable == nil {
+		p.Retryable = IsRetryable
+	}
+	return p
+}
+
+// backoff 第 retry 次重试前的等待时间
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(retry-1))
+	backoff = math.Min(backoff, float64(p.MaxBackoff))
+	if p.Jitter > 0 {
+		backoff *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(backoff)
+}
+
+// Hedge 对冲请求出站中间件
+// policies 的键为消息类型或模式，没有匹配策略的请求和非请求消息直接发送。
+// 任一份请求成功或收到对端的协议错误时立即返回，其余请求的响应被丢弃；超时等本地错误不会结束等待，
+// 剩余份数会立即发出。只应用于幂等的消息类型，对端可能处理同一请求多次。
+func Hedge(policies map[string]HedgePolicy) (core.OutboundMiddleware, error) {
+	compiled, err := compilePolicies(policies)
+	if err != nil {
+		return nil, err
+	}
+	for i := range compiled {
+		compiled[i].policy = compiled[i].policy.withDefaults()
+	}
+
+	return func(next core.OutboundHandler) core.OutboundHandler {
+		return func(msg *core.OutboundMessage) error {
+			policy, ok := compiled.match(msg.MsgType)
+			if msg.Kind != core.OutboundRequest || !ok {
+				return next(msg)
+			}
+			return policy.do(next, msg)
+		}
+	}, nil
+}
+
+func (p HedgePolicy) withDefaults() HedgePolicy {
+	if p.Delay <= 0 {
+		p.Delay = 50 * time.Millisecond
+	}
+	if p.MaxHedges <= 0 {
+		p.MaxHedges = 1
+	}
+	return p
+}
+
+type hedgeResult struct {
+	msg *core.OutboundMessage
+	err error
+}
+
+// do 发送对冲请求，每份请求使用消息的副本
+func (p HedgePolicy) do(next core.OutboundHandler, msg *core.OutboundMessage) error {
+	results := make(chan hedgeResult, p.MaxHedges+1)
+	launched, pending := 0, 0
+	launch := func() {
+		launched++
+		pending++
+		if launched > 1 && p.OnHedge != nil {
+			p.OnHedge(msg, launched)
+		}
+		attempt := *msg
+		attempt.Metadata = slices.Clone(msg.Metadata)
+		go func() {
+			err := next(&attempt)
+			results <- hedgeResult{msg: &attempt, err: err}
+		}()
+	}
+
+	launch()
+	timer := time.NewTimer(p.Delay)
+	defer timer.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case <-timer.C:
+			if launched <= p.MaxHedges {
+				launch()
+				timer.Reset(p.Delay)
+			}
+		case result := <-results:
+			pending--
+			var protoErr *core.ProtocolError
+			if result.err == nil || errors.As(result.err, &protoErr) {
+				msg.RequestID = result.msg.RequestID
+				msg.Response = result.msg.Response
+				return result.err
+			}
+			lastErr = result.err
+			if launched <= p.MaxHedges {
+				launch()
+			} else if pending == 0 {
+				return lastErr
+			}
+		}
+	}
+}
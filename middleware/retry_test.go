@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/core"
+	"github.com/BadKid90s/chilix-msg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry(t *testing.T) {
+	var attempts atomic.Int32
+	config := core.ProcessorConfig{Logger: log.NewDefaultLogger(), RequestTimeout: 100 * time.Millisecond}
+	client, _ := startPair(t, config, func(server core.Processor) {
+		server.RegisterHandler("orders.get", func(ctx core.Context) error {
+			// 前两次请求不响应
+			if attempts.Add(1) <= 2 {
+				return nil
+			}
+			return ctx.Reply("order")
+		})
+		server.RegisterHandler("orders.create", func(ctx core.Context) error {
+			attempts.Add(1)
+			return nil
+		})
+		server.RegisterHandler("orders.invalid", func(ctx core.Context) error {
+			attempts.Add(1)
+			return ctx.ReplyError(core.CodeBadRequest, "invalid")
+		})
+	})
+
+	var sent atomic.Int32
+	retry, err := Retry(map[string]RetryPolicy{
+		"orders.get":     {MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond},
+		"orders.invalid": {MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond},
+		"orders.**": {MaxAttempts: 1, OnRetry: func(msg *core.OutboundMessage, attempt int, err error, backoff time.Duration) {
+			t.Errorf("unexpected retry of %s", msg.MsgType)
+		}},
+	})
+	require.NoError(t, err)
+	client.UseOutbound(retry)
+	client.UseOutbound(func(next core.OutboundHandler) core.OutboundHandler {
+		return func(msg *core.OutboundMessage) error {
+			sent.Add(1)
+			return next(msg)
+		}
+	})
+
+	resp, err := client.Request("orders.get", nil)
+	require.NoError(t, err)
+	var order string
+	require.NoError(t, resp.Bind(&order))
+	assert.Equal(t, "order", order)
+	assert.Equal(t, int32(3), sent.Load(), "each attempt passes through inner middleware")
+
+	// 更具体的模式优先，非幂等的类型不重试
+	attempts.Store(0)
+	_, err = client.Request("orders.create", nil)
+	assert.ErrorIs(t, err, core.ErrRequestTimeout)
+	assert.Equal(t, int32(1), attempts.Load())
+
+	// 对端返回的业务错误不重试
+	attempts.Store(0)
+	_, err = client.Request("orders.invalid", nil)
+	assert.True(t, errors.Is(err, core.NewProtocolError(core.CodeBadRequest, "")))
+	assert.Equal(t, int32(1), attempts.Load())
+
+	_, err = Retry(map[string]RetryPolicy{"orders.**.get": {}})
+	assert.ErrorIs(t, err, core.ErrInvalidPattern)
+}
+
+// 测试处理器关闭时中断退避，返回最后一次的错误
+func TestRetry_Close(t *testing.T) {
+	config := core.ProcessorConfig{Logger: log.NewDefaultLogger(), RequestTimeout: 50 * time.Millisecond}
+	client, _ := startPair(t, config, func(server core.Processor) {
+		server.RegisterHandler("orders.get", func(ctx core.Context) error {
+			return nil
+		})
+	})
+
+	var attempts atomic.Int32
+	retry, err := Retry(map[string]RetryPolicy{
+		"orders.get": {MaxAttempts: 3, InitialBackoff: 10 * time.Second},
+	})
+	require.NoError(t, err)
+	client.UseOutbound(retry)
+	client.UseOutbound(func(next core.OutboundHandler) core.OutboundHandler {
+		return func(msg *core.OutboundMessage) error {
+			attempts.Add(1)
+			return next(msg)
+		}
+	})
+
+	time.AfterFunc(200*time.Millisecond, func() { _ = client.Close() })
+	start := time.Now()
+	_, err = client.Request("orders.get", nil)
+	assert.ErrorIs(t, err, core.ErrRequestTimeout)
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}.withDefaults()
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, policy.backoff(2))
+	assert.Equal(t, 300*time.Millisecond, policy.backoff(3))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		backoff := policy.backoff(1)
+		assert.GreaterOrEqual(t, backoff, 50*time.Millisecond)
+		assert.LessOrEqual(t, backoff, 150*time.Millisecond)
+	}
+}
+
+func TestHedge(t *testing.T) {
+	var mu sync.Mutex
+	var hedges []int
+	hedge, err := Hedge(map[string]HedgePolicy{
+		"search": {Delay: 20 * time.Millisecond, MaxHedges: 2, OnHedge: func(msg *core.OutboundMessage, attempt int) {
+			mu.Lock()
+			defer mu.Unlock()
+			hedges = append(hedges, attempt)
+		}},
+	})
+	require.NoError(t, err)
+
+	// 第一份请求很慢，第二份请求最先成功
+	var requestID atomic.Uint64
+	handler := hedge(func(msg *core.OutboundMessage) error {
+		msg.RequestID = requestID.Add(1)
+		if msg.RequestID == 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+		return nil
+	})
+	start := time.Now()
+	msg := &core.OutboundMessage{Kind: core.OutboundRequest, MsgType: "search"}
+	require.NoError(t, handler(msg))
+	assert.Less(t, time.Since(start), 150*time.Millisecond)
+	assert.Equal(t, uint64(2), msg.RequestID)
+	mu.Lock()
+	assert.Equal(t, []int{2}, hedges)
+	mu.Unlock()
+
+	// 本地错误时立即发出剩余份数，全部失败时返回错误
+	var calls atomic.Int32
+	handler = hedge(func(msg *core.OutboundMessage) error {
+		calls.Add(1)
+		return core.ErrRequestTimeout
+	})
+	assert.ErrorIs(t, handler(&core.OutboundMessage{Kind: core.OutboundRequest, MsgType: "search"}), core.ErrRequestTimeout)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// 对端的协议错误立即返回
+	calls.Store(0)
+	handler = hedge(func(msg *core.OutboundMessage) error {
+		calls.Add(1)
+		return core.NewProtocolError(core.CodeNotFound, "")
+	})
+	assert.ErrorIs(t, handler(&core.OutboundMessage{Kind: core.OutboundRequest, MsgType: "search"}), core.ErrNotFound)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// 没有策略的类型直接发送
+	calls.Store(0)
+	assert.ErrorIs(t, handler(&core.OutboundMessage{Kind: core.OutboundRequest, MsgType: "other"}), core.ErrNotFound)
+	assert.Equal(t, int32(1), calls.Load())
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/BadKid90s/chilix-msg/codec"
+	"github.com/BadKid90s/chilix-msg/core"
+)
+
+var (
+	// ErrSignatureRequired 处理器未启用收发管道签名
+	ErrSignatureRequired = errors.New("signing is not enabled on the processor")
+	// ErrUnsignedMessage 消息帧没有经过签名校验
+	ErrUnsignedMessage = errors.New("message signature was not verified")
+)
+
+// NewSigner 创建 HMAC-SHA256 签名器，密钥ID为1，用于 core.ProcessorConfig.Signer 或 Processor.SetSigner
+// 密钥短于16字节时用SHA-256派生；需要轮换密钥时直接使用 codec.HMACSigner
+func NewSigner(key []byte) codec.Signer {
+	if len(key) < 16 {
+		key = generateKey(key)
+	}
+	signer, _ := codec.NewHMACSigner(1, key)
+	return signer
+}
+
+// RequireSignature 只放行签名校验通过的消息的中间件
+// 签名在处理器的收发管道中完成：先加密再签名，接收时先验签再解密，与 Encryptor 的配置顺序无关。
+// RequireSignature 为 false 时收发管道接受未签名的帧，该中间件可以只对部分消息类型要求签名。
+func RequireSignature() core.Middleware {
+	return func(next core.Handler) core.Handler {
+		return func(ctx core.Context) error {
+			if ctx.Processor().Signer() == nil {
+				ctx.Logger().Errorf("Rejected %s: %v", ctx.MessageType(), ErrSignatureRequired)
+				return ErrSignatureRequired
+			}
+			if !ctx.Signed() {
+				ctx.Logger().Errorf("Rejected %s: %v", ctx.MessageType(), ErrUnsignedMessage)
+				return ErrUnsignedMessage
+			}
+			return next(ctx)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/core"
+	"github.com/BadKid90s/chilix-msg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 测试未启用管道签名的处理器和未经签名校验的消息被拒绝
+func TestRequireSignature(t *testing.T) {
+	logger := log.NewDefaultLogger()
+	processor := core.NewProcessor(NewMockConnection(), core.ProcessorConfig{Logger: logger})
+	handler := RequireSignature()(func(ctx core.Context) error { return nil })
+	ctx := &MockContext{msgType: "test", writer: &MockWriter{}, logger: logger, processor: processor}
+
+	assert.ErrorIs(t, handler(ctx), ErrSignatureRequired)
+	processor.SetSigner(NewSigner([]byte("short")), false)
+	assert.ErrorIs(t, handler(ctx), ErrUnsignedMessage)
+	ctx.signed = true
+	assert.NoError(t, handler(ctx))
+}
+
+// 测试不强制签名的收发管道中，中间件拒绝对端未签名的消息
+func TestRequireSignature_UnsignedPeer(t *testing.T) {
+	signer := NewSigner(KeyFromString("signing-key"))
+	config := core.ProcessorConfig{Logger: log.NewDefaultLogger(), RequestTimeout: time.Second}
+	handled := make(chan string, 2)
+	client, _ := startPair(t, config, func(server core.Processor) {
+		server.SetSigner(signer, false)
+		server.Use(func(next core.Handler) core.Handler {
+			return func(ctx core.Context) error {
+				var msg string
+				_ = ctx.Bind(&msg)
+				if err := next(ctx); err != nil {
+					handled <- msg + ": rejected"
+					return err
+				}
+				handled <- msg + ": accepted"
+				return nil
+			}
+		})
+		server.Use(RequireSignature())
+		server.RegisterHandler("notify", func(ctx core.Context) error { return nil })
+	})
+
+	require.NoError(t, client.Send("notify", "unsigned"))
+	client.SetSigner(signer, false)
+	require.NoError(t, client.Send("notify", "signed"))
+
+	var results []string
+	for range 2 {
+		select {
+		case result := <-handled:
+			results = append(results, result)
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for message")
+		}
+	}
+	assert.ElementsMatch(t, []string{"unsigned: rejected", "signed: accepted"}, results)
+}
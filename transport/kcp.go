@@ -2,14 +2,77 @@ package transport
 
 import (
 	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
 	"net"
 
 	"github.com/xtaci/kcp-go/v5"
 	"golang.org/x/crypto/pbkdf2"
 )
 
+var (
+	// ErrKCPKeyRequired 启用加密时未提供预共享密钥
+	ErrKCPKeyRequired = errors.New("kcp: pre-shared key is required unless crypt is none")
+	// ErrKCPUnknownCrypt 不支持的加密算法
+	ErrKCPUnknownCrypt = errors.New("kcp: unknown block cipher")
+	// ErrKCPUnknownMode 不支持的nodelay模式
+	ErrKCPUnknownMode = errors.New("kcp: unknown nodelay mode")
+)
+
+// KCPMode KCP nodelay 参数预设
+type KCPMode string
+
+const (
+	KCPModeNormal KCPMode = "normal" // nodelay=0 interval=40 resend=2 nc=1
+	KCPModeFast   KCPMode = "fast"   // nodelay=0 interval=30 resend=2 nc=1
+	KCPModeFast2  KCPMode = "fast2"  // nodelay=1 interval=20 resend=2 nc=1
+	KCPModeFast3  KCPMode = "fast3"  // nodelay=1 interval=10 resend=2 nc=1
+)
+
+// kcpNoDelay nodelay 参数
+type kcpNoDelay struct {
+	nodelay, interval, resend, nc int
+}
+
+var kcpModes = map[KCPMode]kcpNoDelay{
+	KCPModeNormal: {0, 40, 2, 1},
+	KCPModeFast:   {0, 30, 2, 1},
+	KCPModeFast2:  {1, 20, 2, 1},
+	KCPModeFast3:  {1, 10, 2, 1},
+}
+
+// defaultKCPSalt 未配置盐值时使用的默认值
+const defaultKCPSalt = "chilix-msg kcp salt"
+
+// KCPConfig KCP传输配置
+type KCPConfig struct {
+	// Key 预共享密钥，通信双方必须一致；Crypt 为 none 时可以为空
+	Key []byte
+	// Salt 密钥派生使用的盐值，为空时使用默认值
+	Salt []byte
+	// Crypt 分组加密算法：aes(默认)、aes-128、aes-192、salsa20、blowfish、twofish、
+	// cast5、3des、tea、xtea、sm4、xor、none
+	Crypt string
+	// DataShards 和 ParityShards 为前向纠错（FEC）的数据分片和校验分片数，0 表示不启用
+	DataShards   int
+	ParityShards int
+	// SndWnd 和 RcvWnd 为发送和接收窗口大小（包数），0 表示使用库默认值
+	SndWnd int
+	RcvWnd int
+	// MTU 最大传输单元，0 表示使用库默认值
+	MTU int
+	// Mode nodelay 参数预设，默认 KCPModeFast3
+	Mode KCPMode
+	// DSCP 差分服务代码点，0 表示不设置
+	DSCP int
+	// StreamMode 启用流模式，合并小包以提高吞吐
+	StreamMode bool
+}
+
 type kcpListener struct {
 	*kcp.Listener
+	transport *kcpTransport
 }
 
 func (l *kcpListener) Accept() (Connection, error) {
@@ -18,7 +81,7 @@ func (l *kcpListener) Accept() (Connection, error) {
 		return nil, err
 	}
 	// 为接受的连接设置优化参数
-	conn.SetNoDelay(1, 10, 2, 1)
+	l.transport.configureSession(conn)
 	return conn, nil
 }
 
@@ -27,7 +90,9 @@ func (l *kcpListener) Addr() net.Addr {
 }
 
 type kcpTransport struct {
-	block kcp.BlockCrypt
+	block   kcp.BlockCrypt
+	config  KCPConfig
+	nodelay kcpNoDelay
 }
 
 func (t *kcpTransport) Protocol() string {
@@ -35,26 +100,118 @@ func (t *kcpTransport) Protocol() string {
 }
 
 // NewKCPTransport creates a new KCP transport.
+// 使用内置的固定密钥，所有使用默认配置的实例共享同一密钥，生产环境请使用 NewKCPTransportWithConfig。
 func NewKCPTransport() Transport {
 	key := pbkdf2.Key([]byte("kcp transport pass"), []byte("kcp transport salt"), 1024, 32, sha1.New)
 	block, _ := kcp.NewAESBlockCrypt(key)
-	return &kcpTransport{block: block}
+	return &kcpTransport{block: block, nodelay: kcpModes[KCPModeFast3]}
+}
+
+// NewKCPTransportWithConfig creates a new KCP transport with the given key and session parameters.
+func NewKCPTransportWithConfig(config KCPConfig) (Transport, error) {
+	mode := config.Mode
+	if mode == "" {
+		mode = KCPModeFast3
+	}
+	nodelay, ok := kcpModes[mode]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKCPUnknownMode, mode)
+	}
+
+	block, err := newKCPBlockCrypt(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kcpTransport{block: block, config: config, nodelay: nodelay}, nil
+}
+
+// newKCPBlockCrypt 根据配置派生密钥并创建分组加密器
+func newKCPBlockCrypt(config KCPConfig) (kcp.BlockCrypt, error) {
+	crypt := config.Crypt
+	if crypt == "" {
+		crypt = "aes"
+	}
+	if crypt == "none" {
+		return nil, nil
+	}
+	if len(config.Key) == 0 {
+		return nil, ErrKCPKeyRequired
+	}
+
+	salt := config.Salt
+	if len(salt) == 0 {
+		salt = []byte(defaultKCPSalt)
+	}
+	key := pbkdf2.Key(config.Key, salt, 4096, 32, sha256.New)
+
+	switch crypt {
+	case "aes":
+		return kcp.NewAESBlockCrypt(key)
+	case "aes-128":
+		return kcp.NewAESBlockCrypt(key[:16])
+	case "aes-192":
+		return kcp.NewAESBlockCrypt(key[:24])
+	case "salsa20":
+		return kcp.NewSalsa20BlockCrypt(key)
+	case "blowfish":
+		return kcp.NewBlowfishBlockCrypt(key)
+	case "twofish":
+		return kcp.NewTwofishBlockCrypt(key)
+	case "cast5":
+		return kcp.NewCast5BlockCrypt(key[:16])
+	case "3des":
+		return kcp.NewTripleDESBlockCrypt(key[:24])
+	case "tea":
+		return kcp.NewTEABlockCrypt(key[:16])
+	case "xtea":
+		return kcp.NewXTEABlockCrypt(key[:16])
+	case "sm4":
+		return kcp.NewSM4BlockCrypt(key[:16])
+	case "xor":
+		return kcp.NewSimpleXORBlockCrypt(key)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrKCPUnknownCrypt, crypt)
+	}
+}
+
+// configureSession 应用会话参数
+func (t *kcpTransport) configureSession(conn *kcp.UDPSession) {
+	conn.SetNoDelay(t.nodelay.nodelay, t.nodelay.interval, t.nodelay.resend, t.nodelay.nc)
+	if t.config.SndWnd > 0 || t.config.RcvWnd > 0 {
+		conn.SetWindowSize(t.config.SndWnd, t.config.RcvWnd)
+	}
+	if t.config.MTU > 0 {
+		conn.SetMtu(t.config.MTU)
+	}
+	if t.config.StreamMode {
+		conn.SetStreamMode(true)
+	}
+	if t.config.DSCP > 0 {
+		_ = conn.SetDSCP(t.config.DSCP)
+	}
 }
 
 func (t *kcpTransport) Listen(address string) (Listener, error) {
-	l, err := kcp.ListenWithOptions(address, t.block, 0, 0)
+	l, err := kcp.ListenWithOptions(address, t.block, t.config.DataShards, t.config.ParityShards)
 	if err != nil {
 		return nil, err
 	}
-	return &kcpListener{Listener: l}, nil
+	if t.config.DSCP > 0 {
+		if err := l.SetDSCP(t.config.DSCP); err != nil {
+			_ = l.Close()
+			return nil, err
+		}
+	}
+	return &kcpListener{Listener: l, transport: t}, nil
 }
 
 func (t *kcpTransport) Dial(address string) (Connection, error) {
-	conn, err := kcp.DialWithOptions(address, t.block, 0, 0)
+	conn, err := kcp.DialWithOptions(address, t.block, t.config.DataShards, t.config.ParityShards)
 	if err != nil {
 		return nil, err
 	}
 	// 使用与监听端相同的优化参数
-	conn.SetNoDelay(1, 10, 2, 1)
+	t.configureSession(conn)
 	return conn, nil
 }
@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -226,3 +227,85 @@ func TestKCPTransport_Reliability(t *testing.T) {
 
 	t.Log("KCP reliability test passed")
 }
+
+func TestKCPTransport_WithConfig(t *testing.T) {
+	configs := []struct {
+		name   string
+		config KCPConfig
+	}{
+		{"AES", KCPConfig{Key: []byte("secret"), Salt: []byte("salt")}},
+		{"Salsa20Fast2", KCPConfig{Key: []byte("secret"), Crypt: "salsa20", Mode: KCPModeFast2}},
+		{"NoneNormal", KCPConfig{Crypt: "none", Mode: KCPModeNormal}},
+		{"FEC", KCPConfig{Key: []byte("secret"), DataShards: 10, ParityShards: 3}},
+		{"Tuned", KCPConfig{Key: []byte("secret"), SndWnd: 256, RcvWnd: 256, MTU: 1200, StreamMode: true}},
+	}
+
+	for _, tc := range configs {
+		t.Run(tc.name, func(t *testing.T) {
+			tr, err := NewKCPTransportWithConfig(tc.config)
+			if err != nil {
+				t.Fatalf("Failed to create transport: %v", err)
+			}
+
+			result := RunBasicConnectionTest(TestConfig{
+				ProtocolName:      "KCP",
+				Transport:         tr,
+				ConnectionTimeout: 10 * time.Second,
+			})
+			if !result.Success {
+				t.Fatalf("Basic connection test failed: %s - %v", result.Message, result.Error)
+			}
+		})
+	}
+}
+
+func TestKCPTransport_MismatchedKeys(t *testing.T) {
+	serverTransport, err := NewKCPTransportWithConfig(KCPConfig{Key: []byte("server-key")})
+	if err != nil {
+		t.Fatalf("Failed to create server transport: %v", err)
+	}
+	clientTransport, err := NewKCPTransportWithConfig(KCPConfig{Key: []byte("client-key")})
+	if err != nil {
+		t.Fatalf("Failed to create client transport: %v", err)
+	}
+
+	server, err := NewEchoServer(serverTransport, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer SafeClose(server, "kcp-echo-server")
+
+	conn, err := clientTransport.Dial(server.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(conn, "kcp-client-connection")
+
+	// 密钥不一致时服务端无法解密，客户端收不到回显
+	_ = conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if _, err := conn.Read(make([]byte, 5)); err == nil {
+		t.Fatal("Expected communication to fail with mismatched keys")
+	}
+}
+
+func TestKCPTransport_InvalidConfig(t *testing.T) {
+	if _, err := NewKCPTransportWithConfig(KCPConfig{}); !errors.Is(err, ErrKCPKeyRequired) {
+		t.Errorf("Expected ErrKCPKeyRequired, got %v", err)
+	}
+	if _, err := NewKCPTransportWithConfig(KCPConfig{Key: []byte("k"), Crypt: "rot13"}); !errors.Is(err, ErrKCPUnknownCrypt) {
+		t.Errorf("Expected ErrKCPUnknownCrypt, got %v", err)
+	}
+	if _, err := NewKCPTransportWithConfig(KCPConfig{Key: []byte("k"), Mode: "turbo"}); !errors.Is(err, ErrKCPUnknownMode) {
+		t.Errorf("Expected ErrKCPUnknownMode, got %v", err)
+	}
+
+	// 所有支持的加密算法都能创建
+	for _, crypt := range []string{"aes", "aes-128", "aes-192", "salsa20", "blowfish", "twofish", "cast5", "3des", "tea", "xtea", "sm4", "xor", "none"} {
+		if _, err := NewKCPTransportWithConfig(KCPConfig{Key: []byte("k"), Crypt: crypt}); err != nil {
+			t.Errorf("Crypt %s: unexpected error %v", crypt, err)
+		}
+	}
+}
@@ -0,0 +1,478 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrMemoryAddressInUse 内存地址已被监听
+	ErrMemoryAddressInUse = errors.New("memory address already in use")
+	// ErrMemoryConnectionRefused 内存地址上没有监听器
+	ErrMemoryConnectionRefused = errors.New("memory connection refused")
+)
+
+// memAddr 内存传输的地址
+type memAddr string
+
+func (a memAddr) Network() string {
+	return "memory"
+}
+
+func (a memAddr) String() string {
+	return string(a)
+}
+
+// memoryRegistry 进程内的监听器注册表，不同的 MemoryTransport 实例共享
+var memoryRegistry = struct {
+	sync.Mutex
+	listeners map[string]*memListener
+}{listeners: make(map[string]*memListener)}
+
+// memorySequence 用于生成自动分配的地址和连接编号
+var memorySequence atomic.Uint64
+
+// memSegment 一次写入的数据段及其可被读取的时间
+type memSegment struct {
+	data      []byte
+	deliverAt time.Time
+}
+
+// memPipe 单向缓冲管道，模拟延迟、带宽和丢包重传
+type memPipe struct {
+	mu       sync.Mutex
+	segments []memSegment
+	buffered int
+	capacity int
+
+	// writeClosed 写端关闭，读端读完剩余数据后返回 io.EOF
+	writeClosed bool
+	// readClosed 读端关闭，之后的写入返回 io.ErrClosedPipe
+	readClosed bool
+
+	readable chan struct{}
+	writable chan struct{}
+
+	config      MemoryConfig
+	rng         *rand.Rand
+	linkFree    time.Time
+	lastDeliver time.Time
+}
+
+func newMemPipe(config MemoryConfig, seed int64) *memPipe {
+	return &memPipe{
+		capacity: config.bufferSize(),
+		readable: make(chan struct{}, 1),
+		writable: make(chan struct{}, 1),
+		config:   config,
+		rng:      rand.New(rand.NewSource(seed)),
+	}
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// deliverTime 计算一个数据段的到达时间，调用方需持有锁
+// 数据段按顺序到达，丢失的数据段会阻塞后续数据直到重传完成
+func (p *memPipe) deliverTime(size int) time.Time {
+	now := time.Now()
+	start := now
+	if p.linkFree.After(start) {
+		start = p.linkFree
+	}
+	if p.config.Bandwidth > 0 {
+		start = start.Add(time.Duration(int64(size) * int64(time.Second) / p.config.Bandwidth))
+	}
+	p.linkFree = start
+
+	deliverAt := start.Add(p.config.Latency)
+	if p.config.LossRate > 0 && p.rng.Float64() < p.config.LossRate {
+		deliverAt = deliverAt.Add(p.config.retransmitDelay())
+	}
+	if deliverAt.Before(p.lastDeliver) {
+		deliverAt = p.lastDeliver
+	}
+	p.lastDeliver = deliverAt
+	return deliverAt
+}
+
+func (p *memPipe) read(b []byte, deadline, done <-chan struct{}) (int, error) {
+	for {
+		p.mu.Lock()
+		if len(p.segments) > 0 {
+			wait := time.Until(p.segments[0].deliverAt)
+			if wait <= 0 {
+				n := p.consume(b)
+				p.mu.Unlock()
+				notify(p.writable)
+				return n, nil
+			}
+			p.mu.Unlock()
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+				continue
+			case <-deadline:
+				timer.Stop()
+				return 0, os.ErrDeadlineExceeded
+			case <-done:
+				timer.Stop()
+				return 0, io.ErrClosedPipe
+			}
+		}
+		if p.writeClosed {
+			p.mu.Unlock()
+			return 0, io.EOF
+		}
+		p.mu.Unlock()
+
+		select {
+		case <-p.readable:
+		case <-deadline:
+			return 0, os.ErrDeadlineExceeded
+		case <-done:
+			return 0, io.ErrClosedPipe
+		}
+	}
+}
+
+// consume 从已到达的数据段中读取数据，调用方需持有锁
+func (p *memPipe) consume(b []byte) int {
+	now := time.Now()
+	n := 0
+	for n < len(b) && len(p.segments) > 0 && !p.segments[0].deliverAt.After(now) {
+		seg := &p.segments[0]
+		c := copy(b[n:], seg.data)
+		n += c
+		seg.data = seg.data[c:]
+		if len(seg.data) == 0 {
+			p.segments[0] = memSegment{}
+			p.segments = p.segments[1:]
+		}
+	}
+	p.buffered -= n
+	return n
+}
+
+func (p *memPipe) write(b []byte, deadline, done <-chan struct{}) (int, error) {
+	n := 0
+	for len(b) > 0 {
+		p.mu.Lock()
+		if p.readClosed || p.writeClosed {
+			p.mu.Unlock()
+			return n, io.ErrClosedPipe
+		}
+		if space := p.capacity - p.buffered; space > 0 {
+			chunk := min(space, len(b))
+			data := make([]byte, chunk)
+			copy(data, b)
+			p.segments = append(p.segments, memSegment{data: data, deliverAt: p.deliverTime(chunk)})
+			p.buffered += chunk
+			p.mu.Unlock()
+			notify(p.readable)
+
+			n += chunk
+			b = b[chunk:]
+			continue
+		}
+		p.mu.Unlock()
+
+		// 缓冲区已满，等待读端消费
+		select {
+		case <-p.writable:
+		case <-deadline:
+			return n, os.ErrDeadlineExceeded
+		case <-done:
+			return n, io.ErrClosedPipe
+		}
+	}
+	return n, nil
+}
+
+func (p *memPipe) closeWrite() {
+	p.mu.Lock()
+	p.writeClosed = true
+	p.mu.Unlock()
+	notify(p.readable)
+}
+
+func (p *memPipe) closeRead() {
+	p.mu.Lock()
+	p.readClosed = true
+	p.segments = nil
+	p.buffered = 0
+	p.mu.Unlock()
+	notify(p.writable)
+}
+
+// memConn 内存连接的一端
+type memConn struct {
+	localAddr  memAddr
+	remoteAddr memAddr
+	rx         *memPipe
+	tx         *memPipe
+
+	readDeadline  pipeDeadline
+	writeDeadline pipeDeadline
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// newMemConnPair 创建一对相连的内存连接，每个方向的数据按发送端的配置模拟网络条件
+func newMemConnPair(server, client memAddr, serverConfig, clientConfig MemoryConfig) (*memConn, *memConn) {
+	toClient := newMemPipe(serverConfig, serverConfig.seed())
+	toServer := newMemPipe(clientConfig, clientConfig.seed())
+
+	serverConn := &memConn{
+		localAddr:     server,
+		remoteAddr:    client,
+		rx:            toServer,
+		tx:            toClient,
+		readDeadline:  makePipeDeadline(),
+		writeDeadline: makePipeDeadline(),
+		done:          make(chan struct{}),
+	}
+	clientConn := &memConn{
+		localAddr:     client,
+		remoteAddr:    server,
+		rx:            toClient,
+		tx:            toServer,
+		readDeadline:  makePipeDeadline(),
+		writeDeadline: makePipeDeadline(),
+		done:          make(chan struct{}),
+	}
+	return serverConn, clientConn
+}
+
+func (c *memConn) Read(b []byte) (int, error) {
+	if isClosedChan(c.done) {
+		return 0, io.ErrClosedPipe
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	return c.rx.read(b, c.readDeadline.wait(), c.done)
+}
+
+func (c *memConn) Write(b []byte) (int, error) {
+	if isClosedChan(c.done) {
+		return 0, io.ErrClosedPipe
+	}
+	return c.tx.write(b, c.writeDeadline.wait(), c.done)
+}
+
+// Close 关闭本端：本端后续读写返回 io.ErrClosedPipe；
+// 对端读完关闭前已写入的数据后返回 io.EOF，对端写入返回 io.ErrClosedPipe
+func (c *memConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.tx.closeWrite()
+		c.rx.closeRead()
+	})
+	return nil
+}
+
+func (c *memConn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *memConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func (c *memConn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return nil
+}
+
+func (c *memConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+func (c *memConn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return nil
+}
+
+// memListener implements the transport.Listener interface for in-memory connections.
+type memListener struct {
+	addr      memAddr
+	config    MemoryConfig
+	acceptCh  chan *memConn
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+func (l *memListener) Accept() (Connection, error) {
+	select {
+	case conn := <-l.acceptCh:
+		return conn, nil
+	case <-l.closeCh:
+		return nil, &net.OpError{Op: "accept", Net: "memory", Addr: l.addr, Err: net.ErrClosed}
+	}
+}
+
+// Close 注销地址并关闭尚未被 Accept 取走的连接
+func (l *memListener) Close() error {
+	l.closeOnce.Do(func() {
+		memoryRegistry.Lock()
+		if memoryRegistry.listeners[string(l.addr)] == l {
+			delete(memoryRegistry.listeners, string(l.addr))
+		}
+		close(l.closeCh)
+		memoryRegistry.Unlock()
+		l.drain()
+	})
+	return nil
+}
+
+// drain 关闭尚未被 Accept 取走的连接，对端会读到 io.EOF
+func (l *memListener) drain() {
+	for {
+		select {
+		case conn := <-l.acceptCh:
+			_ = conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+func (l *memListener) Addr() net.Addr {
+	return l.addr
+}
+
+// MemoryConfig 内存传输配置，用于模拟网络条件
+// 每个方向的数据按发送端所在传输的配置处理
+type MemoryConfig struct {
+	// BufferSize 每个方向在途数据的上限（字节），写满后写入阻塞，默认64KB
+	BufferSize int
+	// Latency 单向延迟
+	Latency time.Duration
+	// Bandwidth 带宽（字节/秒），0 表示不限制
+	Bandwidth int64
+	// LossRate 丢包率 [0, 1]。流式连接保证数据完整有序，丢失的数据段在 RetransmitDelay 后到达
+	LossRate float64
+	// RetransmitDelay 丢包后的重传延迟，默认为 2*Latency 且不小于10ms
+	RetransmitDelay time.Duration
+	// Seed 丢包随机数种子，0 表示使用随机种子
+	Seed int64
+	// Backlog 等待 Accept 的连接上限，超过时 Dial 阻塞，默认128
+	Backlog int
+}
+
+func (c MemoryConfig) bufferSize() int {
+	if c.BufferSize > 0 {
+		return c.BufferSize
+	}
+	return 64 * 1024
+}
+
+func (c MemoryConfig) retransmitDelay() time.Duration {
+	if c.RetransmitDelay > 0 {
+		return c.RetransmitDelay
+	}
+	return max(2*c.Latency, 10*time.Millisecond)
+}
+
+func (c MemoryConfig) seed() int64 {
+	if c.Seed != 0 {
+		return c.Seed
+	}
+	return time.Now().UnixNano()
+}
+
+func (c MemoryConfig) backlog() int {
+	if c.Backlog > 0 {
+		return c.Backlog
+	}
+	return 128
+}
+
+type memoryTransport struct {
+	config MemoryConfig
+}
+
+func (t *memoryTransport) Protocol() string {
+	return "memory"
+}
+
+// NewMemoryTransport creates a new in-process transport.
+// 地址是进程内的名称，空地址或以 ":0" 结尾的地址会自动分配唯一名称。
+func NewMemoryTransport() Transport {
+	return &memoryTransport{}
+}
+
+// NewMemoryTransportWithConfig creates a new in-process transport that simulates network conditions.
+func NewMemoryTransportWithConfig(config MemoryConfig) Transport {
+	return &memoryTransport{config: config}
+}
+
+// resolveMemoryAddress 为空地址或 ":0" 结尾的地址分配唯一名称
+func resolveMemoryAddress(address string) string {
+	if address == "" {
+		return fmt.Sprintf("memory-%d", memorySequence.Add(1))
+	}
+	if strings.HasSuffix(address, ":0") {
+		return fmt.Sprintf("%s:%d", strings.TrimSuffix(address, ":0"), memorySequence.Add(1))
+	}
+	return address
+}
+
+func (t *memoryTransport) Listen(address string) (Listener, error) {
+	memoryRegistry.Lock()
+	defer memoryRegistry.Unlock()
+
+	name := resolveMemoryAddress(address)
+	if _, exists := memoryRegistry.listeners[name]; exists {
+		return nil, &net.OpError{Op: "listen", Net: "memory", Addr: memAddr(name), Err: ErrMemoryAddressInUse}
+	}
+
+	l := &memListener{
+		addr:     memAddr(name),
+		config:   t.config,
+		acceptCh: make(chan *memConn, t.config.backlog()),
+		closeCh:  make(chan struct{}),
+	}
+	memoryRegistry.listeners[name] = l
+	return l, nil
+}
+
+func (t *memoryTransport) Dial(address string) (Connection, error) {
+	memoryRegistry.Lock()
+	l, ok := memoryRegistry.listeners[address]
+	memoryRegistry.Unlock()
+	if !ok {
+		return nil, &net.OpError{Op: "dial", Net: "memory", Addr: memAddr(address), Err: ErrMemoryConnectionRefused}
+	}
+
+	clientAddr := memAddr(fmt.Sprintf("%s#%d", address, memorySequence.Add(1)))
+	serverConn, clientConn := newMemConnPair(l.addr, clientAddr, l.config, t.config)
+
+	select {
+	case l.acceptCh <- serverConn:
+		// 入队时监听器可能恰好关闭，再次清理队列避免连接泄漏
+		if isClosedChan(l.closeCh) {
+			l.drain()
+		}
+		return clientConn, nil
+	case <-l.closeCh:
+		return nil, &net.OpError{Op: "dial", Net: "memory", Addr: l.addr, Err: ErrMemoryConnectionRefused}
+	}
+}
@@ -0,0 +1,260 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+// memoryPair 在给定地址上建立一对内存连接
+func memoryPair(t *testing.T, server, client Transport, address string) (Connection, Connection) {
+	t.Helper()
+
+	listener, err := server.Listen(address)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { SafeClose(listener, "memory-listener") })
+
+	clientConn, err := client.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	serverConn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	t.Cleanup(func() {
+		SafeClose(clientConn, "memory-client-connection")
+		SafeClose(serverConn, "memory-server-connection")
+	})
+	return serverConn, clientConn
+}
+
+func TestMemoryTransport_Protocol(t *testing.T) {
+	transport := NewMemoryTransport()
+	if transport.Protocol() != "memory" {
+		t.Errorf("Expected protocol to be 'memory', got '%s'", transport.Protocol())
+	}
+}
+
+func TestMemoryTransport_Suite(t *testing.T) {
+	config := TestConfig{
+		ProtocolName:      "Memory",
+		Transport:         NewMemoryTransport(),
+		ConnectionTimeout: 5 * time.Second,
+		DataTimeout:       2 * time.Second,
+	}
+
+	if result := RunBasicConnectionTest(config); !result.Success {
+		t.Fatalf("Basic connection test failed: %s - %v", result.Message, result.Error)
+	}
+	if result := RunLargeDataTest(config); !result.Success {
+		t.Fatalf("Large data test failed: %s - %v", result.Message, result.Error)
+	}
+	if result := RunTimeoutTest(config); !result.Success {
+		t.Fatalf("Timeout test failed: %s - %v", result.Message, result.Error)
+	}
+	if result := RunInvalidAddressTest(t, config); !result.Success {
+		t.Fatalf("Invalid address test failed: %s - %v", result.Message, result.Error)
+	}
+
+	// 带网络模拟的配置同样满足测试套件
+	config.Transport = NewMemoryTransportWithConfig(MemoryConfig{Latency: time.Millisecond, LossRate: 0.1, Seed: 1})
+	config.Address = "memory-suite"
+	if result := RunLargeDataTest(config); !result.Success {
+		t.Fatalf("Large data test with simulated network failed: %s - %v", result.Message, result.Error)
+	}
+}
+
+func TestMemoryTransport_Registry(t *testing.T) {
+	transport := NewMemoryTransport()
+
+	listener, err := transport.Listen("registry-service")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	if listener.Addr().Network() != "memory" || listener.Addr().String() != "registry-service" {
+		t.Errorf("Unexpected listener address %s/%s", listener.Addr().Network(), listener.Addr())
+	}
+
+	// 不同的传输实例共享注册表
+	if _, err := NewMemoryTransport().Listen("registry-service"); !errors.Is(err, ErrMemoryAddressInUse) {
+		t.Errorf("Expected ErrMemoryAddressInUse, got %v", err)
+	}
+	conn, err := NewMemoryTransport().Dial("registry-service")
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	SafeClose(conn, "memory-client-connection")
+
+	if _, err := transport.Dial("no-such-service"); !errors.Is(err, ErrMemoryConnectionRefused) {
+		t.Errorf("Expected ErrMemoryConnectionRefused, got %v", err)
+	}
+
+	// 关闭后地址可以重新使用
+	SafeClose(listener, "memory-listener")
+	if _, err := transport.Dial("registry-service"); !errors.Is(err, ErrMemoryConnectionRefused) {
+		t.Errorf("Expected ErrMemoryConnectionRefused after close, got %v", err)
+	}
+	listener, err = transport.Listen("registry-service")
+	if err != nil {
+		t.Fatalf("Failed to listen again: %v", err)
+	}
+	SafeClose(listener, "memory-listener")
+
+	// 自动分配的地址互不相同
+	a, _ := transport.Listen("127.0.0.1:0")
+	b, _ := transport.Listen("127.0.0.1:0")
+	defer SafeClose(a, "memory-listener")
+	defer SafeClose(b, "memory-listener")
+	if a.Addr().String() == b.Addr().String() {
+		t.Errorf("Expected unique generated addresses, got %s twice", a.Addr())
+	}
+}
+
+func TestMemoryTransport_CloseSemantics(t *testing.T) {
+	server, client := memoryPair(t, NewMemoryTransport(), NewMemoryTransport(), "")
+
+	// 关闭前写入的数据仍可被对端读到，随后返回EOF
+	if _, err := client.Write([]byte("bye")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	SafeClose(client, "memory-client-connection")
+
+	data, err := io.ReadAll(server)
+	if err != nil || string(data) != "bye" {
+		t.Fatalf("Expected to read %q then EOF, got %q, %v", "bye", data, err)
+	}
+	if _, err := server.Write([]byte("x")); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("Expected write to closed peer to fail with io.ErrClosedPipe, got %v", err)
+	}
+	if _, err := client.Read(make([]byte, 1)); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("Expected read on closed connection to fail with io.ErrClosedPipe, got %v", err)
+	}
+	if err := client.Close(); err != nil {
+		t.Errorf("Expected repeated Close to succeed, got %v", err)
+	}
+}
+
+func TestMemoryListener_CloseSemantics(t *testing.T) {
+	transport := NewMemoryTransport()
+	listener, err := transport.Listen("")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	// 未被Accept的连接在监听器关闭时被关闭
+	pending, err := transport.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(pending, "memory-client-connection")
+
+	SafeClose(listener, "memory-listener")
+	_ = pending.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := pending.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected pending connection to read io.EOF, got %v", err)
+	}
+
+	// 阻塞中的Accept在关闭后返回
+	listener, err = transport.Listen("")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	accepted := make(chan error, 1)
+	go func() {
+		_, err := listener.Accept()
+		accepted <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	SafeClose(listener, "memory-listener")
+
+	select {
+	case err := <-accepted:
+		if err == nil {
+			t.Error("Expected Accept to fail after close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Accept did not return after listener close")
+	}
+}
+
+func TestMemoryTransport_Latency(t *testing.T) {
+	latency := 50 * time.Millisecond
+	server, client := memoryPair(t, NewMemoryTransport(), NewMemoryTransportWithConfig(MemoryConfig{Latency: latency}), "")
+
+	start := time.Now()
+	if _, err := client.Write([]byte("ping")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(server, buf); err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < latency {
+		t.Errorf("Expected delivery after at least %v, got %v", latency, elapsed)
+	}
+
+	// 反方向按服务端的配置，没有延迟
+	start = time.Now()
+	_, _ = server.Write([]byte("pong"))
+	if _, err := io.ReadFull(client, buf); err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= latency {
+		t.Errorf("Expected no latency from server side, got %v", elapsed)
+	}
+}
+
+func TestMemoryTransport_Bandwidth(t *testing.T) {
+	// 100KB/s 传输20KB约需200ms
+	server, client := memoryPair(t, NewMemoryTransport(), NewMemoryTransportWithConfig(MemoryConfig{Bandwidth: 100 * 1024}), "")
+
+	data := bytes.Repeat([]byte("b"), 20*1024)
+	start := time.Now()
+	go func() { _, _ = client.Write(data) }()
+	buf := make([]byte, len(data))
+	if _, err := io.ReadFull(server, buf); err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Errorf("Expected bandwidth limit to delay delivery, took %v", elapsed)
+	}
+}
+
+func TestMemoryTransport_PacketLoss(t *testing.T) {
+	config := MemoryConfig{LossRate: 1, RetransmitDelay: 100 * time.Millisecond, BufferSize: 4}
+	server, client := memoryPair(t, NewMemoryTransport(), NewMemoryTransportWithConfig(config), "")
+
+	// 所有数据段都丢失一次，数据仍然完整有序
+	start := time.Now()
+	go func() { _, _ = client.Write([]byte("12345678")) }()
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(server, buf); err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if string(buf) != "12345678" {
+		t.Errorf("Expected ordered data, got %q", buf)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Expected retransmission delay for each segment, took %v", elapsed)
+	}
+}
+
+func TestMemoryTransport_Backpressure(t *testing.T) {
+	_, client := memoryPair(t, NewMemoryTransport(), NewMemoryTransportWithConfig(MemoryConfig{BufferSize: 16}), "")
+
+	// 对端不读取时，写满缓冲区后阻塞直到超时
+	_ = client.SetWriteDeadline(time.Now().Add(50 * time.Millisecond))
+	n, err := client.Write(make([]byte, 32))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Expected write deadline error, got %v", err)
+	}
+	if n != 16 {
+		t.Errorf("Expected 16 bytes buffered before blocking, got %d", n)
+	}
+}
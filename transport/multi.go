@@ -0,0 +1,222 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// ErrListenerClosed 多协议监听器已关闭
+var ErrListenerClosed = errors.New("listener closed")
+
+// ProtocolConnection 带协议标记的连接
+type ProtocolConnection interface {
+	Connection
+	// Protocol 返回接受该连接的传输协议，即 Transport.Protocol()
+	Protocol() string
+}
+
+// ProtocolOf 返回连接的协议标记，未标记时返回空字符串
+func ProtocolOf(conn Connection) string {
+	if pc, ok := As[ProtocolConnection](conn); ok {
+		return pc.Protocol()
+	}
+	return ""
+}
+
+// taggedConn 记录连接来自哪个传输协议
+type taggedConn struct {
+	Connection
+	protocol string
+}
+
+func (c *taggedConn) Protocol() string {
+	return c.protocol
+}
+
+func (c *taggedConn) Unwrap() Connection {
+	return c.Connection
+}
+
+// multiAddr 多协议监听器的地址，形如 "tcp://127.0.0.1:9000,websocket://127.0.0.1:9001"
+type multiAddr []string
+
+func (a multiAddr) Network() string {
+	return "multi"
+}
+
+func (a multiAddr) String() string {
+	return strings.Join(a, ",")
+}
+
+// acceptResult 子监听器 Accept 的结果
+type acceptResult struct {
+	conn Connection
+	err  error
+}
+
+// multiEntry 一个子监听器
+type multiEntry struct {
+	protocol string
+	listener Listener
+}
+
+// MultiListener 将多个传输的监听器合并为一个 Accept 流
+// 返回的连接带有协议标记，可通过 ProtocolOf 获取；Close 会关闭所有子监听器
+type MultiListener interface {
+	Listener
+	// Listen 在传输上监听并加入到多协议监听器
+	Listen(t Transport, address string) error
+	// Add 加入已创建的监听器，protocol 作为其连接的协议标记
+	Add(protocol string, l Listener) error
+	// AddrOf 返回指定协议的子监听器地址
+	AddrOf(protocol string) (net.Addr, bool)
+}
+
+type multiListener struct {
+	mu      sync.Mutex
+	entries []multiEntry
+	results chan acceptResult
+	closed  bool
+	closeCh chan struct{}
+}
+
+// NewMultiListener 创建空的多协议监听器，通过 Listen 或 Add 添加子监听器
+func NewMultiListener() MultiListener {
+	return newMultiListener()
+}
+
+func newMultiListener() *multiListener {
+	return &multiListener{
+		results: make(chan acceptResult),
+		closeCh: make(chan struct{}),
+	}
+}
+
+// ListenSpec 多协议监听中的一项
+type ListenSpec struct {
+	Transport Transport
+	Address   string
+}
+
+// ListenMulti 在多个传输上同时监听，任何一个失败时关闭已经打开的监听器
+func ListenMulti(specs ...ListenSpec) (MultiListener, error) {
+	m := newMultiListener()
+	for _, spec := range specs {
+		if err := m.Listen(spec.Transport, spec.Address); err != nil {
+			_ = m.Close()
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *multiListener) Listen(t Transport, address string) error {
+	l, err := t.Listen(address)
+	if err != nil {
+		return fmt.Errorf("%s listen on %s: %w", t.Protocol(), address, err)
+	}
+	if err := m.Add(t.Protocol(), l); err != nil {
+		_ = l.Close()
+		return err
+	}
+	return nil
+}
+
+func (m *multiListener) Add(protocol string, l Listener) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrListenerClosed
+	}
+	m.entries = append(m.entries, multiEntry{protocol: protocol, listener: l})
+	go m.acceptLoop(protocol, l)
+	return nil
+}
+
+// acceptLoop 持续接受子监听器的连接，超时等临时错误时重试，其他错误交给 Accept 并停止
+func (m *multiListener) acceptLoop(protocol string, l Listener) {
+	for {
+		conn, err := l.Accept()
+		var result acceptResult
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			result.err = fmt.Errorf("%s accept: %w", protocol, err)
+		} else {
+			result.conn = &taggedConn{Connection: conn, protocol: protocol}
+		}
+
+		select {
+		case m.results <- result:
+		case <-m.closeCh:
+			if conn != nil {
+				_ = conn.Close()
+			}
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Accept 返回任意子监听器接受的下一个连接
+func (m *multiListener) Accept() (Connection, error) {
+	select {
+	case r := <-m.results:
+		return r.conn, r.err
+	case <-m.closeCh:
+		return nil, &net.OpError{Op: "accept", Net: "multi", Addr: m.Addr(), Err: net.ErrClosed}
+	}
+}
+
+// Close 关闭所有子监听器
+func (m *multiListener) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	close(m.closeCh)
+	entries := m.entries
+	m.mu.Unlock()
+
+	var errs []error
+	for _, e := range entries {
+		if err := e.listener.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s close: %w", e.protocol, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Addr 返回所有子监听器的地址
+func (m *multiListener) Addr() net.Addr {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	addrs := make(multiAddr, 0, len(m.entries))
+	for _, e := range m.entries {
+		addrs = append(addrs, e.protocol+"://"+e.listener.Addr().String())
+	}
+	return addrs
+}
+
+func (m *multiListener) AddrOf(protocol string) (net.Addr, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, e := range m.entries {
+		if e.protocol == protocol {
+			return e.listener.Addr(), true
+		}
+	}
+	return nil, false
+}
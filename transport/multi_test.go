@@ -0,0 +1,222 @@
+package transport
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMultiListener_Protocols(t *testing.T) {
+	transports := []Transport{
+		NewTCPTransport(),
+		NewWebSocketTransport(),
+		NewKCPTransport(),
+		NewQUICTransport(),
+	}
+	specs := make([]ListenSpec, 0, len(transports))
+	for _, tr := range transports {
+		specs = append(specs, ListenSpec{Transport: tr, Address: "127.0.0.1:0"})
+	}
+
+	listener, err := ListenMulti(specs...)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "multi-listener")
+
+	if addr := listener.Addr(); addr.Network() != "multi" || strings.Count(addr.String(), ",") != len(transports)-1 {
+		t.Errorf("Unexpected multi address %s/%s", addr.Network(), addr)
+	}
+
+	for _, tr := range transports {
+		t.Run(tr.Protocol(), func(t *testing.T) {
+			addr, ok := listener.AddrOf(tr.Protocol())
+			if !ok {
+				t.Fatalf("No listener for %s", tr.Protocol())
+			}
+			client, err := tr.Dial(addr.String())
+			if err != nil {
+				t.Fatalf("Failed to dial: %v", err)
+			}
+			defer SafeClose(client, "multi-client-connection")
+			// KCP和QUIC在首次写入后服务端才能接受连接
+			if _, err := client.Write([]byte("hi")); err != nil {
+				t.Fatalf("Failed to write: %v", err)
+			}
+
+			server, err := listener.Accept()
+			if err != nil {
+				t.Fatalf("Failed to accept: %v", err)
+			}
+			defer SafeClose(server, "multi-server-connection")
+
+			if got := ProtocolOf(server); got != tr.Protocol() {
+				t.Errorf("Expected protocol %s, got %s", tr.Protocol(), got)
+			}
+			_ = server.SetReadDeadline(time.Now().Add(5 * time.Second))
+			buf := make([]byte, 2)
+			if _, err := io.ReadFull(server, buf); err != nil || string(buf) != "hi" {
+				t.Fatalf("Unexpected read %q: %v", buf, err)
+			}
+		})
+	}
+}
+
+func TestMultiListener_UnwrapHelpers(t *testing.T) {
+	listener := NewMultiListener()
+	defer SafeClose(listener, "multi-listener")
+	if err := listener.Listen(NewWebSocketTransport(), "127.0.0.1:0"); err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	addr, _ := listener.AddrOf("websocket")
+	client, err := NewWebSocketTransport().Dial(addr.String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(client, "multi-client-connection")
+
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	defer SafeClose(server, "multi-server-connection")
+
+	// 带协议标记的连接仍然可以使用各传输提供的辅助函数
+	if _, ok := HTTPRequestOf(server); !ok {
+		t.Error("Expected HTTP request info through tagged connection")
+	}
+	if _, ok := As[WebSocketConnection](server); !ok {
+		t.Error("Expected As to find WebSocketConnection")
+	}
+	if _, ok := As[UnixConnection](server); ok {
+		t.Error("Expected As to fail for UnixConnection")
+	}
+}
+
+func TestMultiListener_Close(t *testing.T) {
+	tcp := NewTCPTransport()
+	listener, err := ListenMulti(
+		ListenSpec{Transport: tcp, Address: "127.0.0.1:0"},
+		ListenSpec{Transport: NewMemoryTransport(), Address: ""},
+	)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	tcpAddr, _ := listener.AddrOf("tcp")
+	memAddr, _ := listener.AddrOf("memory")
+
+	accepted := make(chan error, 1)
+	go func() {
+		_, err := listener.Accept()
+		accepted <- err
+	}()
+
+	if err := listener.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	select {
+	case err := <-accepted:
+		if err == nil {
+			t.Error("Expected Accept to fail after close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Accept did not return after close")
+	}
+
+	// 所有子监听器都已关闭
+	if conn, err := tcp.Dial(tcpAddr.String()); err == nil {
+		SafeClose(conn, "tcp-client-connection")
+		t.Error("Expected TCP listener to be closed")
+	}
+	if _, err := NewMemoryTransport().Dial(memAddr.String()); err == nil {
+		t.Error("Expected memory listener to be closed")
+	}
+	if err := listener.Add("tcp", nil); !errors.Is(err, ErrListenerClosed) {
+		t.Errorf("Expected ErrListenerClosed, got %v", err)
+	}
+}
+
+func TestListenMulti_PartialFailure(t *testing.T) {
+	_, err := ListenMulti(
+		ListenSpec{Transport: NewMemoryTransport(), Address: "multi-partial"},
+		ListenSpec{Transport: NewMemoryTransport(), Address: "multi-partial"},
+	)
+	if !errors.Is(err, ErrMemoryAddressInUse) {
+		t.Fatalf("Expected ErrMemoryAddressInUse, got %v", err)
+	}
+
+	// 已经打开的监听器被关闭，地址可以重新使用
+	l, err := NewMemoryTransport().Listen("multi-partial")
+	if err != nil {
+		t.Fatalf("Expected address to be released, got %v", err)
+	}
+	SafeClose(l, "memory-listener")
+}
+
+func TestMultiListener_SubListenerError(t *testing.T) {
+	memory, err := NewMemoryTransport().Listen("")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	listener := NewMultiListener()
+	defer SafeClose(listener, "multi-listener")
+	if err := listener.Add("memory", memory); err != nil {
+		t.Fatalf("Failed to add listener: %v", err)
+	}
+
+	// 子监听器单独关闭时，错误通过 Accept 返回并带有协议名
+	SafeClose(memory, "memory-listener")
+	_, err = listener.Accept()
+	if err == nil || !strings.Contains(err.Error(), "memory accept") {
+		t.Fatalf("Expected sub-listener error, got %v", err)
+	}
+}
+
+// timeoutError 临时的超时错误
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "accept timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// flakyListener 第一次 Accept 返回超时错误
+type flakyListener struct {
+	Listener
+	failed atomic.Bool
+}
+
+func (l *flakyListener) Accept() (Connection, error) {
+	if !l.failed.Swap(true) {
+		return nil, timeoutError{}
+	}
+	return l.Listener.Accept()
+}
+
+func TestMultiListener_TemporaryError(t *testing.T) {
+	tr := NewMemoryTransport()
+	memory, err := tr.Listen("")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	listener := NewMultiListener()
+	defer SafeClose(listener, "multi-listener")
+	if err := listener.Add("memory", &flakyListener{Listener: memory}); err != nil {
+		t.Fatalf("Failed to add listener: %v", err)
+	}
+
+	// 临时错误不会传给 Accept，子监听器继续工作
+	client, err := tr.Dial(memory.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(client, "memory-client")
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Expected connection after temporary error, got %v", err)
+	}
+	SafeClose(conn, "memory-server")
+}
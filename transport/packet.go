@@ -0,0 +1,48 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ErrPacketTooLarge 帧超过数据报允许的最大长度
+var ErrPacketTooLarge = errors.New("packet exceeds datagram MTU")
+
+// MaxDatagramSize 单个UDP数据报的最大长度，用作接收缓冲区大小
+const MaxDatagramSize = 64 * 1024
+
+// PacketConn 面向消息的不可靠连接
+// 每次 Write 发送一个数据报，每次 Read 读取一个完整数据报（缓冲区不足时截断）。
+// 数据报可能丢失、重复或乱序，超过 MaxPacketSize 的写入返回 ErrPacketTooLarge。
+type PacketConn interface {
+	Connection
+	// MaxPacketSize 单个数据报可发送的最大字节数
+	MaxPacketSize() int
+}
+
+// PacketListener 数据报监听器
+type PacketListener interface {
+	Accept() (PacketConn, error)
+	Close() error
+	Addr() net.Addr
+}
+
+// PacketTransport 数据报传输接口
+// 与 Transport 相比不保证可靠和有序，适合遥测等可以容忍丢失的消息
+type PacketTransport interface {
+	Listen(address string) (PacketListener, error)
+	Dial(address string) (PacketConn, error)
+	Protocol() string
+}
+
+// IsPacketConn 判断连接是否为数据报连接
+func IsPacketConn(conn Connection) bool {
+	_, ok := As[PacketConn](conn)
+	return ok
+}
+
+// packetTooLarge 构建包含实际大小和MTU的错误
+func packetTooLarge(size, mtu int) error {
+	return fmt.Errorf("%w: frame of %d bytes, MTU is %d bytes", ErrPacketTooLarge, size, mtu)
+}
@@ -17,6 +17,7 @@ import (
 
 // quicConn implements the net.Conn interface for a QUIC stream.
 type quicConn struct {
+	conn       *quic.Conn
 	stream     *quic.Stream
 	localAddr  net.Addr
 	remoteAddr net.Addr
@@ -42,6 +43,11 @@ func (c *quicConn) RemoteAddr() net.Addr {
 	return c.remoteAddr
 }
 
+// ConnectionState returns the TLS state of the underlying QUIC connection.
+func (c *quicConn) ConnectionState() tls.ConnectionState {
+	return c.conn.ConnectionState().TLS
+}
+
 func (c *quicConn) SetDeadline(t time.Time) error {
 	return c.stream.SetDeadline(t)
 }
@@ -73,6 +79,7 @@ func (l *quicListener) Accept() (Connection, error) {
 	}
 
 	return &quicConn{
+		conn:       conn,
 		stream:     stream,
 		localAddr:  conn.LocalAddr(),
 		remoteAddr: conn.RemoteAddr(),
@@ -83,31 +90,70 @@ func (l *quicListener) Addr() net.Addr {
 	return l.Listener.Addr()
 }
 
-type quicTransport struct{}
+// DefaultQUICALPN QUIC传输默认使用的ALPN协议名
+const DefaultQUICALPN = "chilix-msg"
+
+// LegacyQUICALPN 早期版本使用的ALPN协议名，未配置 NextProtos 时同时提供，与旧版本的对端保持兼容
+const LegacyQUICALPN = "quic-echo-example"
+
+// QUICConfig QUIC传输配置
+type QUICConfig struct {
+	// TLSConfig 基础TLS配置，以下字段会补充到它的副本上
+	TLSConfig *tls.Config
+	// CertFile 和 KeyFile 为PEM格式的证书与私钥，服务端用作服务证书，客户端用作客户端证书
+	CertFile string
+	KeyFile  string
+	// RootCAs 客户端校验服务端证书使用的CA池，为空时使用系统CA
+	RootCAs *x509.CertPool
+	// ClientCAs 服务端校验客户端证书使用的CA池，设置后要求客户端提供证书（双向TLS）
+	ClientCAs *x509.CertPool
+	// ServerName 客户端校验的服务端名称，为空时取拨号地址中的主机名
+	ServerName string
+	// NextProtos ALPN协议列表，为空时使用 DefaultQUICALPN 和 LegacyQUICALPN
+	NextProtos []string
+	// Config QUIC连接参数（空闲超时、保活、流数量限制等），为空时使用默认值
+	Config *quic.Config
+	// Insecure 开发模式：服务端缺少证书时生成临时自签名证书，客户端跳过证书校验
+	Insecure bool
+	// MaxDatagramSize 数据报模式（NewQUICDatagramTransport）下单个帧的最大字节数，默认1100
+	MaxDatagramSize int
+}
+
+type quicTransport struct {
+	config      QUICConfig
+	certificate *tls.Certificate
+}
 
 func (t *quicTransport) Protocol() string {
 	return "quic"
 }
 
-// NewQUICTransport creates a new QUIC transport.
+// NewQUICTransport creates a new QUIC transport in development mode.
+// 服务端使用临时自签名证书，客户端不校验证书，仅适用于开发和测试。
 func NewQUICTransport() Transport {
-	return &quicTransport{}
+	return &quicTransport{config: QUICConfig{Insecure: true}}
 }
 
-func (t *quicTransport) Listen(address string) (Listener, error) {
-	tlsConf, err := generateTLSConfig()
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate TLS config: %w", err)
+// NewQUICTransportWithConfig creates a new QUIC transport with the given TLS and QUIC settings.
+func NewQUICTransportWithConfig(config QUICConfig) (Transport, error) {
+	t := &quicTransport{config: config}
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate: %w", err)
+		}
+		t.certificate = &cert
 	}
+	return t, nil
+}
 
-	// 优化QUIC配置以加速测试
-	quicConfig := &quic.Config{
-		HandshakeIdleTimeout: 5 * time.Second,
-		MaxIdleTimeout:       5 * time.Second,
-		KeepAlivePeriod:      1 * time.Second,
+func (t *quicTransport) Listen(address string) (Listener, error) {
+	tlsConf, err := t.serverTLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build TLS config: %w", err)
 	}
 
-	listener, err := quic.ListenAddr(address, tlsConf, quicConfig)
+	listener, err := quic.ListenAddr(address, tlsConf, t.quicConfig())
 	if err != nil {
 		return nil, err
 	}
@@ -116,19 +162,7 @@ func (t *quicTransport) Listen(address string) (Listener, error) {
 }
 
 func (t *quicTransport) Dial(address string) (Connection, error) {
-	tlsConf := &tls.Config{
-		InsecureSkipVerify: true,
-		NextProtos:         []string{"quic-echo-example"},
-	}
-
-	// 使用与监听端相同的优化配置
-	quicConfig := &quic.Config{
-		HandshakeIdleTimeout: 5 * time.Second,
-		MaxIdleTimeout:       5 * time.Second,
-		KeepAlivePeriod:      1 * time.Second,
-	}
-
-	conn, err := quic.DialAddr(context.Background(), address, tlsConf, quicConfig)
+	conn, err := quic.DialAddr(context.Background(), address, t.clientTLSConfig(), t.quicConfig())
 	if err != nil {
 		return nil, err
 	}
@@ -139,32 +173,91 @@ func (t *quicTransport) Dial(address string) (Connection, error) {
 	}
 
 	return &quicConn{
+		conn:       conn,
 		stream:     stream,
 		localAddr:  conn.LocalAddr(),
 		remoteAddr: conn.RemoteAddr(),
 	}, nil
 }
 
-// generateTLSConfig sets up a bare-bones TLS config for the server
-func generateTLSConfig() (*tls.Config, error) {
+// serverTLSConfig 构建服务端TLS配置
+func (t *quicTransport) serverTLSConfig() (*tls.Config, error) {
+	conf := cloneTLSConfig(t.config.TLSConfig)
+	if t.certificate != nil {
+		conf.Certificates = append(conf.Certificates, *t.certificate)
+	}
+	if !hasServerCertificate(conf) {
+		if !t.config.Insecure {
+			return nil, ErrNoCertificate
+		}
+		cert, err := generateSelfSignedCertificate()
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	if t.config.ClientCAs != nil {
+		conf.ClientCAs = t.config.ClientCAs
+		conf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	conf.NextProtos = t.nextProtos()
+	return conf, nil
+}
+
+// clientTLSConfig 构建客户端TLS配置
+func (t *quicTransport) clientTLSConfig() *tls.Config {
+	conf := cloneTLSConfig(t.config.TLSConfig)
+	if t.certificate != nil {
+		conf.Certificates = append(conf.Certificates, *t.certificate)
+	}
+	if t.config.RootCAs != nil {
+		conf.RootCAs = t.config.RootCAs
+	}
+	if t.config.ServerName != "" {
+		conf.ServerName = t.config.ServerName
+	}
+	if t.config.Insecure {
+		conf.InsecureSkipVerify = true
+	}
+	conf.NextProtos = t.nextProtos()
+	return conf
+}
+
+func (t *quicTransport) nextProtos() []string {
+	if len(t.config.NextProtos) > 0 {
+		return t.config.NextProtos
+	}
+	if t.config.TLSConfig != nil && len(t.config.TLSConfig.NextProtos) > 0 {
+		return t.config.TLSConfig.NextProtos
+	}
+	return []string{DefaultQUICALPN, LegacyQUICALPN}
+}
+
+// quicConfig 返回QUIC连接参数，未配置时使用默认值
+func (t *quicTransport) quicConfig() *quic.Config {
+	if t.config.Config != nil {
+		return t.config.Config.Clone()
+	}
+	return &quic.Config{
+		HandshakeIdleTimeout: 5 * time.Second,
+		MaxIdleTimeout:       5 * time.Second,
+		KeepAlivePeriod:      1 * time.Second,
+	}
+}
+
+// generateSelfSignedCertificate 生成开发模式使用的临时自签名证书
+func generateSelfSignedCertificate() (tls.Certificate, error) {
 	key, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
-		return nil, err
+		return tls.Certificate{}, err
 	}
 	template := x509.Certificate{SerialNumber: big.NewInt(1)}
 	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
 	if err != nil {
-		return nil, err
+		return tls.Certificate{}, err
 	}
 	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
 	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
 
-	tlsCert, err := tls.X509KeyPair(certPEM, keyPEM)
-	if err != nil {
-		return nil, err
-	}
-	return &tls.Config{
-		Certificates: []tls.Certificate{tlsCert},
-		NextProtos:   []string{"quic-echo-example"},
-	}, nil
+	return tls.X509KeyPair(certPEM, keyPEM)
 }
@@ -0,0 +1,173 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// quicDatagramConn 使用QUIC DATAGRAM帧（RFC 9221）收发消息的连接
+type quicDatagramConn struct {
+	conn          *quic.Conn
+	maxPacketSize int
+	readDeadline  pipeDeadline
+}
+
+func newQUICDatagramConn(conn *quic.Conn, maxPacketSize int) *quicDatagramConn {
+	return &quicDatagramConn{
+		conn:          conn,
+		maxPacketSize: maxPacketSize,
+		readDeadline:  makePipeDeadline(),
+	}
+}
+
+func (c *quicDatagramConn) Read(b []byte) (int, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 截止时间到达时取消接收
+	deadline := c.readDeadline.wait()
+	go func() {
+		select {
+		case <-deadline:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	data, err := c.conn.ReceiveDatagram(ctx)
+	if err != nil {
+		if isClosedChan(deadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		// 接收只会因连接关闭而失败
+		return 0, fmt.Errorf("%w: %v", net.ErrClosed, err)
+	}
+	return copy(b, data), nil
+}
+
+func (c *quicDatagramConn) Write(b []byte) (int, error) {
+	if len(b) > c.maxPacketSize {
+		return 0, packetTooLarge(len(b), c.maxPacketSize)
+	}
+	if err := c.conn.SendDatagram(b); err != nil {
+		var tooLarge *quic.DatagramTooLargeError
+		if errors.As(err, &tooLarge) {
+			return 0, packetTooLarge(len(b), int(tooLarge.MaxDatagramPayloadSize))
+		}
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *quicDatagramConn) Close() error {
+	return c.conn.CloseWithError(0, "")
+}
+
+func (c *quicDatagramConn) MaxPacketSize() int {
+	return c.maxPacketSize
+}
+
+// ConnectionState returns the TLS state of the underlying QUIC connection.
+func (c *quicDatagramConn) ConnectionState() tls.ConnectionState {
+	return c.conn.ConnectionState().TLS
+}
+
+func (c *quicDatagramConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *quicDatagramConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *quicDatagramConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *quicDatagramConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+// SetWriteDeadline 数据报发送只进入发送队列，忽略写超时
+func (c *quicDatagramConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+// quicDatagramListener 每个QUIC连接对应一个数据报连接
+type quicDatagramListener struct {
+	*quic.Listener
+	maxPacketSize int
+}
+
+func (l *quicDatagramListener) Accept() (PacketConn, error) {
+	conn, err := l.Listener.Accept(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return newQUICDatagramConn(conn, l.maxPacketSize), nil
+}
+
+func (l *quicDatagramListener) Addr() net.Addr {
+	return l.Listener.Addr()
+}
+
+type quicDatagramTransport struct {
+	*quicTransport
+}
+
+// NewQUICDatagramTransport creates a datagram transport over QUIC DATAGRAM frames.
+// TLS和QUIC参数与 NewQUICTransportWithConfig 相同，数据报不重传、不保证顺序。
+func NewQUICDatagramTransport(config QUICConfig) (PacketTransport, error) {
+	t, err := NewQUICTransportWithConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &quicDatagramTransport{quicTransport: t.(*quicTransport)}, nil
+}
+
+func (t *quicDatagramTransport) Protocol() string {
+	return "quic-datagram"
+}
+
+func (t *quicDatagramTransport) maxPacketSize() int {
+	if t.config.MaxDatagramSize > 0 {
+		return t.config.MaxDatagramSize
+	}
+	return 1100
+}
+
+// quicConfig 在QUIC参数上开启DATAGRAM扩展
+func (t *quicDatagramTransport) quicConfig() *quic.Config {
+	conf := t.quicTransport.quicConfig()
+	conf.EnableDatagrams = true
+	return conf
+}
+
+func (t *quicDatagramTransport) Listen(address string) (PacketListener, error) {
+	tlsConf, err := t.serverTLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build TLS config: %w", err)
+	}
+
+	listener, err := quic.ListenAddr(address, tlsConf, t.quicConfig())
+	if err != nil {
+		return nil, err
+	}
+	return &quicDatagramListener{Listener: listener, maxPacketSize: t.maxPacketSize()}, nil
+}
+
+func (t *quicDatagramTransport) Dial(address string) (PacketConn, error) {
+	conn, err := quic.DialAddr(context.Background(), address, t.clientTLSConfig(), t.quicConfig())
+	if err != nil {
+		return nil, err
+	}
+	return newQUICDatagramConn(conn, t.maxPacketSize()), nil
+}
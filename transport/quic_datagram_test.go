@@ -0,0 +1,105 @@
+package transport
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func newTestQUICDatagramTransport(t *testing.T, config QUICConfig) PacketTransport {
+	t.Helper()
+	config.Insecure = true
+	tr, err := NewQUICDatagramTransport(config)
+	if err != nil {
+		t.Fatalf("Failed to create transport: %v", err)
+	}
+	return tr
+}
+
+func TestQUICDatagramTransport_Datagrams(t *testing.T) {
+	transport := newTestQUICDatagramTransport(t, QUICConfig{})
+	if transport.Protocol() != "quic-datagram" {
+		t.Errorf("Expected protocol to be 'quic-datagram', got '%s'", transport.Protocol())
+	}
+
+	listener, err := transport.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "quic-datagram-listener")
+
+	accepted := acceptPacket(listener)
+	client, err := transport.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(client, "quic-datagram-client")
+
+	session := <-accepted
+	if session == nil {
+		t.Fatal("Failed to accept connection")
+	}
+	defer SafeClose(session, "quic-datagram-session")
+
+	if _, err := client.Write([]byte("telemetry")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if got := readPacket(t, session); got != "telemetry" {
+		t.Errorf("Expected 'telemetry', got %q", got)
+	}
+	if _, err := session.Write([]byte("ack")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if got := readPacket(t, client); got != "ack" {
+		t.Errorf("Expected 'ack', got %q", got)
+	}
+
+	// 数据报连接同样可以获取对端证书
+	if _, err := PeerCertificate(client); err != nil {
+		t.Errorf("Expected server certificate, got %v", err)
+	}
+}
+
+func TestQUICDatagramTransport_PacketTooLarge(t *testing.T) {
+	// 配置的上限超过QUIC路径允许的大小时，返回QUIC给出的实际上限
+	transport := newTestQUICDatagramTransport(t, QUICConfig{MaxDatagramSize: 4096})
+	listener, err := transport.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "quic-datagram-listener")
+
+	client, err := transport.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(client, "quic-datagram-client")
+
+	if _, err := client.Write(make([]byte, 5000)); !errors.Is(err, ErrPacketTooLarge) {
+		t.Errorf("Expected ErrPacketTooLarge for configured limit, got %v", err)
+	}
+	if _, err := client.Write(make([]byte, 3000)); !errors.Is(err, ErrPacketTooLarge) {
+		t.Errorf("Expected ErrPacketTooLarge for QUIC limit, got %v", err)
+	}
+}
+
+func TestQUICDatagramTransport_ReadDeadline(t *testing.T) {
+	transport := newTestQUICDatagramTransport(t, QUICConfig{})
+	listener, err := transport.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "quic-datagram-listener")
+
+	client, err := transport.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(client, "quic-datagram-client")
+
+	_ = client.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, err := client.Read(make([]byte, 16)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected deadline error, got %v", err)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"testing"
 	"time"
+
+	"github.com/quic-go/quic-go"
 )
 
 func TestQUICTransport_Protocol(t *testing.T) {
@@ -286,3 +288,181 @@ func TestQUICTransport_Multiplexing(t *testing.T) {
 
 	t.Log("QUIC multiplexing test passed")
 }
+
+func TestQUICTransport_WithCertificates(t *testing.T) {
+	pki := newTestPKI(t)
+	certFile, keyFile := pki.issue(t, "server")
+
+	serverTransport, err := NewQUICTransportWithConfig(QUICConfig{
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server transport: %v", err)
+	}
+	server, err := NewEchoServer(serverTransport, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer SafeClose(server, "quic-echo-server")
+
+	clientTransport, err := NewQUICTransportWithConfig(QUICConfig{
+		RootCAs: pki.certPool(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client transport: %v", err)
+	}
+	clientConn, err := clientTransport.Dial(server.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial with verified TLS: %v", err)
+	}
+	defer SafeClose(clientConn, "quic-client-connection")
+
+	_ = clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+	testData := []byte("verified QUIC")
+	if _, err := clientConn.Write(testData); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	buf := make([]byte, len(testData))
+	if _, err := clientConn.Read(buf); err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if string(buf) != string(testData) {
+		t.Errorf("Data mismatch. Expected: %s, Got: %s", testData, buf)
+	}
+}
+
+func TestQUICTransport_UntrustedCertificate(t *testing.T) {
+	pki := newTestPKI(t)
+	certFile, keyFile := pki.issue(t, "server")
+
+	serverTransport, err := NewQUICTransportWithConfig(QUICConfig{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("Failed to create server transport: %v", err)
+	}
+	listener, err := serverTransport.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "quic-listener")
+
+	// 客户端信任另一个CA，握手应失败
+	otherPKI := newTestPKI(t)
+	clientTransport, err := NewQUICTransportWithConfig(QUICConfig{RootCAs: otherPKI.certPool(t)})
+	if err != nil {
+		t.Fatalf("Failed to create client transport: %v", err)
+	}
+	if conn, err := clientTransport.Dial(listener.Addr().String()); err == nil {
+		SafeClose(conn, "quic-client-connection")
+		t.Fatal("Expected dial to fail with untrusted certificate")
+	}
+}
+
+func TestQUICTransport_ALPNMismatch(t *testing.T) {
+	serverTransport, err := NewQUICTransportWithConfig(QUICConfig{
+		Insecure:   true,
+		NextProtos: []string{"server-proto"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server transport: %v", err)
+	}
+	listener, err := serverTransport.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "quic-listener")
+
+	clientTransport, err := NewQUICTransportWithConfig(QUICConfig{
+		Insecure:   true,
+		NextProtos: []string{"client-proto"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client transport: %v", err)
+	}
+	if conn, err := clientTransport.Dial(listener.Addr().String()); err == nil {
+		SafeClose(conn, "quic-client-connection")
+		t.Fatal("Expected dial to fail with mismatched ALPN")
+	}
+}
+
+// 测试默认配置与只使用旧ALPN的对端互通
+func TestQUICTransport_LegacyALPN(t *testing.T) {
+	legacy := QUICConfig{Insecure: true, NextProtos: []string{LegacyQUICALPN}}
+	current := QUICConfig{Insecure: true}
+
+	for _, tt := range []struct {
+		name           string
+		server, client QUICConfig
+	}{
+		{"LegacyServer", legacy, current},
+		{"LegacyClient", current, legacy},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			serverTransport, err := NewQUICTransportWithConfig(tt.server)
+			if err != nil {
+				t.Fatalf("Failed to create server transport: %v", err)
+			}
+			listener, err := serverTransport.Listen("127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Failed to listen: %v", err)
+			}
+			defer SafeClose(listener, "quic-listener")
+			go func() {
+				if conn, err := listener.Accept(); err == nil {
+					defer SafeClose(conn, "quic-server-connection")
+					buf := make([]byte, 4)
+					_, _ = conn.Read(buf)
+				}
+			}()
+
+			clientTransport, err := NewQUICTransportWithConfig(tt.client)
+			if err != nil {
+				t.Fatalf("Failed to create client transport: %v", err)
+			}
+			conn, err := clientTransport.Dial(listener.Addr().String())
+			if err != nil {
+				t.Fatalf("Failed to dial: %v", err)
+			}
+			SafeClose(conn, "quic-client-connection")
+		})
+	}
+}
+
+func TestQUICTransport_MissingCertificate(t *testing.T) {
+	transport, err := NewQUICTransportWithConfig(QUICConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create transport: %v", err)
+	}
+	if listener, err := transport.Listen("127.0.0.1:0"); err == nil {
+		SafeClose(listener, "quic-listener")
+		t.Fatal("Expected listen to fail without certificate")
+	}
+
+	if _, err := NewQUICTransportWithConfig(QUICConfig{CertFile: "missing.pem", KeyFile: "missing-key.pem"}); err == nil {
+		t.Fatal("Expected error for missing certificate files")
+	}
+}
+
+func TestQUICTransport_CustomQUICConfig(t *testing.T) {
+	tr, err := NewQUICTransportWithConfig(QUICConfig{
+		Insecure: true,
+		Config: &quic.Config{
+			HandshakeIdleTimeout: 3 * time.Second,
+			MaxIdleTimeout:       10 * time.Second,
+			KeepAlivePeriod:      2 * time.Second,
+			MaxIncomingStreams:   10,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create transport: %v", err)
+	}
+
+	result := RunBasicConnectionTest(TestConfig{
+		ProtocolName:      "QUIC",
+		Transport:         tr,
+		ConnectionTimeout: 10 * time.Second,
+	})
+	if !result.Success {
+		t.Fatalf("Basic connection test failed: %s - %v", result.Message, result.Error)
+	}
+}
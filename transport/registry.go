@@ -0,0 +1,329 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownScheme URL的scheme没有注册对应的传输
+var ErrUnknownScheme = errors.New("unknown transport scheme")
+
+// Factory 根据URL创建传输，URL的查询参数是传输选项
+type Factory func(u *url.URL) (Transport, error)
+
+var registry = struct {
+	sync.RWMutex
+	factories map[string]Factory
+}{factories: make(map[string]Factory)}
+
+// Register 注册scheme对应的传输工厂，重复注册或工厂为nil时panic
+func Register(scheme string, factory Factory) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if factory == nil {
+		panic("transport: Register factory is nil")
+	}
+	if _, dup := registry.factories[scheme]; dup {
+		panic("transport: Register called twice for scheme " + scheme)
+	}
+	registry.factories[scheme] = factory
+}
+
+// Schemes 返回已注册的scheme列表
+func Schemes() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	schemes := make([]string, 0, len(registry.factories))
+	for scheme := range registry.factories {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// ParseURL 根据URL创建传输，并返回用于 Listen/Dial 的地址
+//
+// 示例:
+//
+//	tcp://127.0.0.1:8080
+//	quic://example.com:4433?idle=30s&ca=/etc/ca.pem
+//	wss://example.com/ws?ca=/etc/ca.pem&subprotocols=chilix.v1
+//	unix:///var/run/app.sock?mode=0600
+//	memory://service?latency=10ms
+func ParseURL(rawURL string) (Transport, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	registry.RLock()
+	factory, ok := registry.factories[u.Scheme]
+	registry.RUnlock()
+	if !ok {
+		return nil, "", fmt.Errorf("%w: %q", ErrUnknownScheme, u.Scheme)
+	}
+
+	t, err := factory(u)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s transport: %w", u.Scheme, err)
+	}
+	return t, urlAddress(u), nil
+}
+
+// DialURL 根据URL选择传输并拨号
+func DialURL(rawURL string) (Connection, error) {
+	t, address, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return t.Dial(address)
+}
+
+// ListenURL 根据URL选择传输并监听
+func ListenURL(rawURL string) (Listener, error) {
+	t, address, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return t.Listen(address)
+}
+
+// urlAddress 从URL中取出传输地址
+// 网络传输使用 host:port；unix 使用路径，"unix:@name" 表示抽象命名空间
+func urlAddress(u *url.URL) string {
+	if u.Opaque != "" {
+		return u.Opaque
+	}
+	if u.Host != "" {
+		return u.Host
+	}
+	return u.Path
+}
+
+func init() {
+	Register("tcp", newTCPFromURL)
+	Register("tls", newTLSFromURL)
+	Register("kcp", newKCPFromURL)
+	Register("quic", newQUICFromURL)
+	Register("ws", newWebSocketFromURL)
+	Register("wss", newWebSocketFromURL)
+	Register("unix", newUnixFromURL)
+	Register("memory", newMemoryFromURL)
+	Register("sniff", newSniffFromURL)
+}
+
+func newTCPFromURL(u *url.URL) (Transport, error) {
+	opts := NewURLOptions(u)
+	if err := opts.Err(); err != nil {
+		return nil, err
+	}
+	return NewTCPTransport(), nil
+}
+
+// tlsURLOptions TLS相关的公共参数
+type tlsURLOptions struct {
+	cert, key  string
+	ca         []string
+	clientCA   []string
+	serverName string
+	insecure   bool
+}
+
+func parseTLSOptions(opts *URLOptions) tlsURLOptions {
+	var o tlsURLOptions
+	opts.String("cert", &o.cert)
+	opts.String("key", &o.key)
+	opts.Strings("ca", &o.ca)
+	opts.Strings("client_ca", &o.clientCA)
+	opts.String("server_name", &o.serverName)
+	opts.Bool("insecure", &o.insecure)
+	return o
+}
+
+// pools 加载CA证书池
+func (o tlsURLOptions) pools() (rootCAs, clientCAs *x509.CertPool, err error) {
+	if len(o.ca) > 0 {
+		if rootCAs, err = LoadCertPool(o.ca...); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(o.clientCA) > 0 {
+		if clientCAs, err = LoadCertPool(o.clientCA...); err != nil {
+			return nil, nil, err
+		}
+	}
+	return rootCAs, clientCAs, nil
+}
+
+func newTLSFromURL(u *url.URL) (Transport, error) {
+	opts := NewURLOptions(u)
+	o := parseTLSOptions(opts)
+	config := TLSTransportConfig{
+		CertFile:           o.cert,
+		KeyFile:            o.key,
+		ServerName:         o.serverName,
+		InsecureSkipVerify: o.insecure,
+	}
+	opts.Duration("reload", &config.ReloadInterval)
+	opts.Duration("handshake_timeout", &config.HandshakeTimeout)
+	opts.TLSVersion("min_version", &config.MinVersion)
+	if err := opts.Err(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if config.RootCAs, config.ClientCAs, err = o.pools(); err != nil {
+		return nil, err
+	}
+	return NewTLSTransport(config)
+}
+
+// newKCPFromURL 必须提供 key，不使用 NewKCPTransport 内置的共享密钥；crypt=none 时可以省略
+func newKCPFromURL(u *url.URL) (Transport, error) {
+	opts := NewURLOptions(u)
+	var config KCPConfig
+	var key, salt, mode string
+	opts.String("key", &key)
+	opts.String("salt", &salt)
+	opts.String("crypt", &config.Crypt)
+	opts.String("mode", &mode)
+	opts.Int("data_shards", &config.DataShards)
+	opts.Int("parity_shards", &config.ParityShards)
+	opts.Int("sndwnd", &config.SndWnd)
+	opts.Int("rcvwnd", &config.RcvWnd)
+	opts.Int("mtu", &config.MTU)
+	opts.Int("dscp", &config.DSCP)
+	opts.Bool("stream", &config.StreamMode)
+	if err := opts.Err(); err != nil {
+		return nil, err
+	}
+
+	config.Key = []byte(key)
+	if salt != "" {
+		config.Salt = []byte(salt)
+	}
+	config.Mode = KCPMode(mode)
+	return NewKCPTransportWithConfig(config)
+}
+
+// newQUICFromURL 与 tls:// 一样，开发模式（临时自签名证书、跳过证书校验）需要显式指定 insecure=true
+func newQUICFromURL(u *url.URL) (Transport, error) {
+	opts := NewURLOptions(u)
+	o := parseTLSOptions(opts)
+	config := QUICConfig{
+		CertFile:   o.cert,
+		KeyFile:    o.key,
+		ServerName: o.serverName,
+		Insecure:   o.insecure,
+	}
+	opts.Strings("alpn", &config.NextProtos)
+
+	// 在默认QUIC参数的基础上应用选项
+	quicConf := (&quicTransport{}).quicConfig()
+	opts.Duration("idle", &quicConf.MaxIdleTimeout)
+	opts.Duration("keepalive", &quicConf.KeepAlivePeriod)
+	opts.Duration("handshake_timeout", &quicConf.HandshakeIdleTimeout)
+	opts.Int64("max_streams", &quicConf.MaxIncomingStreams)
+	config.Config = quicConf
+	if err := opts.Err(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if config.RootCAs, config.ClientCAs, err = o.pools(); err != nil {
+		return nil, err
+	}
+	return NewQUICTransportWithConfig(config)
+}
+
+func newWebSocketFromURL(u *url.URL) (Transport, error) {
+	opts := NewURLOptions(u)
+	o := parseTLSOptions(opts)
+	config := WebSocketConfig{
+		Path:     u.Path,
+		CertFile: o.cert,
+		KeyFile:  o.key,
+		Secure:   u.Scheme == "wss",
+	}
+	opts.Strings("origins", &config.AllowedOrigins)
+	opts.Strings("subprotocols", &config.Subprotocols)
+	opts.Bool("compression", &config.EnableCompression)
+	opts.Int64("read_limit", &config.ReadLimit)
+	opts.Duration("ping", &config.PingInterval)
+	opts.Duration("pong_timeout", &config.PongTimeout)
+	opts.Duration("handshake_timeout", &config.HandshakeTimeout)
+	opts.Int("backlog", &config.AcceptBacklog)
+	opts.Bool("trust_proxy", &config.TrustProxyHeaders)
+	if err := opts.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(o.clientCA) > 0 && o.cert == "" {
+		return nil, fmt.Errorf("client_ca requires a server certificate")
+	}
+	rootCAs, clientCAs, err := o.pools()
+	if err != nil {
+		return nil, err
+	}
+	if rootCAs != nil || clientCAs != nil || o.serverName != "" || o.insecure {
+		config.TLSConfig = &tls.Config{
+			RootCAs:            rootCAs,
+			ServerName:         o.serverName,
+			InsecureSkipVerify: o.insecure,
+		}
+		if clientCAs != nil {
+			config.TLSConfig.ClientCAs = clientCAs
+			config.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return NewWebSocketTransportWithConfig(config)
+}
+
+func newUnixFromURL(u *url.URL) (Transport, error) {
+	opts := NewURLOptions(u)
+	var config UnixConfig
+	opts.FileMode("mode", &config.Mode)
+	if err := opts.Err(); err != nil {
+		return nil, err
+	}
+	return NewUnixTransportWithConfig(config), nil
+}
+
+func newMemoryFromURL(u *url.URL) (Transport, error) {
+	opts := NewURLOptions(u)
+	var config MemoryConfig
+	opts.Int("buffer", &config.BufferSize)
+	opts.Duration("latency", &config.Latency)
+	opts.Int64("bandwidth", &config.Bandwidth)
+	opts.Float("loss", &config.LossRate)
+	opts.Duration("retransmit", &config.RetransmitDelay)
+	opts.Int64("seed", &config.Seed)
+	opts.Int("backlog", &config.Backlog)
+	if err := opts.Err(); err != nil {
+		return nil, err
+	}
+	return NewMemoryTransportWithConfig(config), nil
+}
+
+// newSniffFromURL URL路径作为WebSocket路径，例如 sniff://0.0.0.0:8080/ws
+func newSniffFromURL(u *url.URL) (Transport, error) {
+	opts := NewURLOptions(u)
+	config := SniffConfig{WebSocket: WebSocketConfig{Path: u.Path}}
+	opts.Strings("origins", &config.WebSocket.AllowedOrigins)
+	opts.Strings("subprotocols", &config.WebSocket.Subprotocols)
+	opts.Int64("read_limit", &config.WebSocket.ReadLimit)
+	opts.Duration("ping", &config.WebSocket.PingInterval)
+	opts.Duration("sniff_timeout", &config.SniffTimeout)
+	opts.Int("backlog", &config.Backlog)
+	if err := opts.Err(); err != nil {
+		return nil, err
+	}
+	return NewSniffTransportWithConfig(config), nil
+}
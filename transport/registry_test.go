@@ -0,0 +1,208 @@
+package transport
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// urlRoundTrip 通过 ListenURL/DialURL 建立连接并验证数据收发
+func urlRoundTrip(t *testing.T, listenURL string, dialURL func(Listener) string) {
+	t.Helper()
+
+	listener, err := ListenURL(listenURL)
+	if err != nil {
+		t.Fatalf("ListenURL(%s) failed: %v", listenURL, err)
+	}
+	defer SafeClose(listener, "url-listener")
+
+	accepted := make(chan Connection, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn
+	}()
+
+	conn, err := DialURL(dialURL(listener))
+	if err != nil {
+		t.Fatalf("DialURL failed: %v", err)
+	}
+	defer SafeClose(conn, "url-client-connection")
+
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	server := <-accepted
+	if server == nil {
+		t.Fatal("Failed to accept connection")
+	}
+	defer SafeClose(server, "url-server-connection")
+
+	_ = server.SetDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(server, buf); err != nil || string(buf) != "hello" {
+		t.Fatalf("Unexpected read %q: %v", buf, err)
+	}
+}
+
+func TestSchemes_BuiltIn(t *testing.T) {
+	schemes := strings.Join(Schemes(), ",")
+	for _, scheme := range []string{"tcp", "tls", "kcp", "quic", "ws", "wss", "unix", "memory", "sniff"} {
+		if !strings.Contains(schemes, scheme) {
+			t.Errorf("Expected built-in scheme %s to be registered, got %s", scheme, schemes)
+		}
+	}
+}
+
+func TestURLTransports(t *testing.T) {
+	hostURL := func(scheme, suffix string) func(Listener) string {
+		return func(l Listener) string {
+			return scheme + "://" + l.Addr().String() + suffix
+		}
+	}
+
+	tests := []struct {
+		name   string
+		listen string
+		dial   func(Listener) string
+	}{
+		{"TCP", "tcp://127.0.0.1:0", hostURL("tcp", "")},
+		{"KCP", "kcp://127.0.0.1:0?key=secret&mode=fast2", hostURL("kcp", "?key=secret&mode=fast2")},
+		{"QUIC", "quic://127.0.0.1:0?insecure&idle=30s&keepalive=5s", hostURL("quic", "?insecure&idle=30s")},
+		{"WebSocket", "ws://127.0.0.1:0/msg?compression&ping=1s", hostURL("ws", "/msg?compression")},
+		{"Memory", "memory://url-service?latency=1ms", hostURL("memory", "?latency=1ms")},
+		{"Unix", "unix://" + filepath.Join(t.TempDir(), "url.sock") + "?mode=0600", func(l Listener) string {
+			return "unix://" + l.Addr().String()
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlRoundTrip(t, tt.listen, tt.dial)
+		})
+	}
+}
+
+func TestParseURL_Options(t *testing.T) {
+	tr, address, err := ParseURL("quic://example.com:4433?idle=30s&alpn=a,b&insecure=false&server_name=svc")
+	if err != nil {
+		t.Fatalf("ParseURL failed: %v", err)
+	}
+	if address != "example.com:4433" {
+		t.Errorf("Expected address example.com:4433, got %s", address)
+	}
+	qt := tr.(*quicTransport)
+	if qt.config.Config.MaxIdleTimeout != 30*time.Second {
+		t.Errorf("Expected idle timeout 30s, got %v", qt.config.Config.MaxIdleTimeout)
+	}
+	if qt.config.Insecure || qt.config.ServerName != "svc" || strings.Join(qt.config.NextProtos, ",") != "a,b" {
+		t.Errorf("Unexpected QUIC config: %+v", qt.config)
+	}
+
+	// 开发模式需要显式指定 insecure
+	tr, _, err = ParseURL("quic://127.0.0.1:4433")
+	if err != nil {
+		t.Fatalf("ParseURL failed: %v", err)
+	}
+	if tr.(*quicTransport).config.Insecure {
+		t.Error("Expected QUIC URL without insecure to verify certificates")
+	}
+	tr, _, err = ParseURL("quic://127.0.0.1:4433?insecure=true")
+	if err != nil {
+		t.Fatalf("ParseURL failed: %v", err)
+	}
+	if !tr.(*quicTransport).config.Insecure {
+		t.Error("Expected insecure=true to enable development mode")
+	}
+
+	tr, address, err = ParseURL("sniff://0.0.0.0:8080/ws?sniff_timeout=2s&origins=example.com")
+	if err != nil || address != "0.0.0.0:8080" {
+		t.Fatalf("ParseURL failed: %q, %v", address, err)
+	}
+	if sc := tr.(*sniffTransport).config; sc.SniffTimeout != 2*time.Second || sc.WebSocket.path() != "/ws" || len(sc.WebSocket.AllowedOrigins) != 1 {
+		t.Errorf("Unexpected sniff config: %+v", sc)
+	}
+
+	_, address, err = ParseURL("unix:@abstract-name")
+	if err != nil || address != "@abstract-name" {
+		t.Errorf("Expected abstract unix address, got %q, %v", address, err)
+	}
+}
+
+// 测试 wss 的 client_ca 要求客户端证书
+func TestParseURL_WebSocketClientCA(t *testing.T) {
+	pki := newTestPKI(t)
+	certFile, keyFile := pki.issue(t, "localhost")
+
+	listener, err := ListenURL("wss://127.0.0.1:0/ws?cert=" + certFile + "&key=" + keyFile + "&client_ca=" + pki.caFile)
+	if err != nil {
+		t.Fatalf("ListenURL failed: %v", err)
+	}
+	defer SafeClose(listener, "wss-listener")
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			SafeClose(conn, "wss-server-connection")
+		}
+	}()
+
+	if conn, err := DialURL("wss://" + listener.Addr().String() + "/ws?ca=" + pki.caFile); err == nil {
+		SafeClose(conn, "wss-client-connection")
+		t.Fatal("Expected dial without a client certificate to fail")
+	}
+}
+
+func TestParseURL_Errors(t *testing.T) {
+	if _, _, err := ParseURL("carrier-pigeon://host:1"); !errors.Is(err, ErrUnknownScheme) {
+		t.Errorf("Expected ErrUnknownScheme, got %v", err)
+	}
+	if _, _, err := ParseURL("tcp://host:1?idel=30s"); err == nil || !strings.Contains(err.Error(), "unknown options: idel") {
+		t.Errorf("Expected unknown option error, got %v", err)
+	}
+	if _, _, err := ParseURL("quic://host:1?idle=soon"); err == nil || !strings.Contains(err.Error(), "idle") {
+		t.Errorf("Expected invalid duration error, got %v", err)
+	}
+	if _, _, err := ParseURL("kcp://host:1?mode=fast"); !errors.Is(err, ErrKCPKeyRequired) {
+		t.Errorf("Expected ErrKCPKeyRequired, got %v", err)
+	}
+	// 不再回退到内置的共享密钥
+	if _, _, err := ParseURL("kcp://host:1"); !errors.Is(err, ErrKCPKeyRequired) {
+		t.Errorf("Expected ErrKCPKeyRequired without key, got %v", err)
+	}
+	if _, _, err := ParseURL("ws://host:1/ws?client_ca=ca.pem"); err == nil {
+		t.Error("Expected client_ca without a server certificate to fail")
+	}
+	if _, _, err := ParseURL("tls://host:1?min_version=2.0"); err == nil {
+		t.Error("Expected invalid TLS version error")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("test-memory", func(u *url.URL) (Transport, error) {
+		opts := NewURLOptions(u)
+		var latency time.Duration
+		opts.Duration("latency", &latency)
+		if err := opts.Err(); err != nil {
+			return nil, err
+		}
+		return NewMemoryTransportWithConfig(MemoryConfig{Latency: latency}), nil
+	})
+
+	urlRoundTrip(t, "test-memory://custom-service", func(l Listener) string {
+		return "test-memory://" + l.Addr().String() + "?latency=1ms"
+	})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected duplicate Register to panic")
+		}
+	}()
+	Register("test-memory", func(*url.URL) (Transport, error) { return NewMemoryTransport(), nil })
+}
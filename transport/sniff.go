@@ -0,0 +1,258 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/codec"
+	"github.com/BadKid90s/chilix-msg/log"
+)
+
+// sniffLen 识别协议需要读取的字节数
+const sniffLen = 4
+
+// nativeMagic BalancedCodec帧头的魔数字节 "CHPM"
+var nativeMagic = binary.BigEndian.AppendUint32(nil, codec.MagicNumber)
+
+// httpUpgradePrefix WebSocket升级请求只能使用GET方法
+var httpUpgradePrefix = []byte("GET ")
+
+// SniffConfig 协议嗅探传输配置
+type SniffConfig struct {
+	// WebSocket HTTP连接升级时使用的配置，TLS相关字段不生效
+	WebSocket WebSocketConfig
+	// SniffTimeout 等待客户端发送首部字节的超时，默认5秒
+	SniffTimeout time.Duration
+	// Backlog 已识别但尚未被 Accept 取走的原生连接上限，默认128
+	Backlog int
+}
+
+func (c SniffConfig) sniffTimeout() time.Duration {
+	if c.SniffTimeout > 0 {
+		return c.SniffTimeout
+	}
+	return 5 * time.Second
+}
+
+func (c SniffConfig) backlog() int {
+	if c.Backlog > 0 {
+		return c.Backlog
+	}
+	return 128
+}
+
+type sniffTransport struct {
+	config SniffConfig
+}
+
+func (t *sniffTransport) Protocol() string {
+	return "sniff"
+}
+
+// NewSniffTransport 创建在同一TCP端口上同时服务原生和WebSocket客户端的传输，接受所有路径和Origin
+func NewSniffTransport() Transport {
+	return &sniffTransport{config: SniffConfig{WebSocket: WebSocketConfig{CheckOrigin: allowAllOrigins}}}
+}
+
+// NewSniffTransportWithConfig 按首部字节分流的传输：BalancedCodec魔数交给原生路径，HTTP升级请求交给WebSocket，其他连接直接关闭
+// Accept 返回的连接带有协议标记 "tcp" 或 "websocket"，可通过 ProtocolOf 获取。
+func NewSniffTransportWithConfig(config SniffConfig) Transport {
+	return &sniffTransport{config: config}
+}
+
+func (t *sniffTransport) Listen(address string) (Listener, error) {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	wsConfig := t.config.WebSocket
+	wsConfig.TLSConfig = nil
+	ws := newWSListener(ln.Addr(), wsConfig)
+
+	httpLn := &chanListener{addr: ln.Addr(), conns: make(chan net.Conn), closeCh: make(chan struct{})}
+	var handler http.Handler = ws
+	if path := wsConfig.path(); path != "/" {
+		mux := http.NewServeMux()
+		mux.Handle(path, ws)
+		handler = mux
+	}
+	ws.server = &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: wsConfig.handshakeTimeout(),
+	}
+
+	l := &sniffListener{
+		ln:      ln,
+		config:  t.config,
+		ws:      ws,
+		httpLn:  httpLn,
+		native:  make(chan Connection, t.config.backlog()),
+		closeCh: make(chan struct{}),
+	}
+	go func() {
+		if err := ws.server.Serve(httpLn); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Sniff HTTP server stopped: %v", err)
+		}
+	}()
+	go l.acceptLoop()
+	return l, nil
+}
+
+// Dial 使用原生路径连接，WebSocket客户端请使用 NewWebSocketTransport 拨号
+func (t *sniffTransport) Dial(address string) (Connection, error) {
+	return net.Dial("tcp", address)
+}
+
+// sniffListener 在同一个TCP监听器上按首部字节分流连接
+type sniffListener struct {
+	ln     net.Listener
+	config SniffConfig
+	ws     *wsListener
+	httpLn *chanListener
+	native chan Connection
+
+	once    sync.Once
+	closeCh chan struct{}
+}
+
+func (l *sniffListener) acceptLoop() {
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			// 底层监听器出错时关闭整个监听器，Accept 随之返回
+			_ = l.Close()
+			return
+		}
+		go l.sniff(conn)
+	}
+}
+
+// sniff 读取首部字节并把连接交给对应的路径
+func (l *sniffListener) sniff(conn net.Conn) {
+	_ = conn.SetReadDeadline(time.Now().Add(l.config.sniffTimeout()))
+	r := bufio.NewReader(conn)
+	head, err := r.Peek(sniffLen)
+	if err != nil {
+		log.Debugf("Sniff failed for %s: %v", conn.RemoteAddr(), err)
+		_ = conn.Close()
+		return
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	peeked := &peekedConn{Conn: conn, reader: r}
+
+	switch {
+	case bytes.Equal(head, nativeMagic):
+		select {
+		case l.native <- &taggedConn{Connection: peeked, protocol: "tcp"}:
+			// 入队后监听器若已关闭，清理队列防止连接泄漏
+			select {
+			case <-l.closeCh:
+				l.drain()
+			default:
+			}
+		case <-l.closeCh:
+			_ = conn.Close()
+		default:
+			log.Warnf("Sniff accept backlog full, rejecting %s", conn.RemoteAddr())
+			_ = conn.Close()
+		}
+	case bytes.Equal(head, httpUpgradePrefix):
+		select {
+		case l.httpLn.conns <- peeked:
+		case <-l.closeCh:
+			_ = conn.Close()
+		}
+	default:
+		log.Warnf("Unknown protocol from %s (% x), rejecting", conn.RemoteAddr(), head)
+		_ = conn.Close()
+	}
+}
+
+// drain 关闭尚未被 Accept 取走的原生连接
+func (l *sniffListener) drain() {
+	for {
+		select {
+		case conn := <-l.native:
+			_ = conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+func (l *sniffListener) Accept() (Connection, error) {
+	select {
+	case conn := <-l.native:
+		return conn, nil
+	case conn := <-l.ws.connChan:
+		return &taggedConn{Connection: conn, protocol: "websocket"}, nil
+	case <-l.closeCh:
+		return nil, &net.OpError{Op: "accept", Net: "sniff", Addr: l.ln.Addr(), Err: net.ErrClosed}
+	}
+}
+
+func (l *sniffListener) Close() error {
+	var err error
+	l.once.Do(func() {
+		close(l.closeCh)
+		err = l.ln.Close()
+		// 同时关闭HTTP服务器和内部监听器
+		_ = l.ws.Close()
+		_ = l.httpLn.Close()
+		l.drain()
+	})
+	return err
+}
+
+func (l *sniffListener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+// peekedConn 先返回嗅探时缓冲的字节，再从原连接读取
+type peekedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// chanListener 把嗅探出的HTTP连接交给 http.Server
+type chanListener struct {
+	addr    net.Addr
+	conns   chan net.Conn
+	once    sync.Once
+	closeCh chan struct{}
+}
+
+func (l *chanListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closeCh:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *chanListener) Close() error {
+	l.once.Do(func() {
+		close(l.closeCh)
+	})
+	return nil
+}
+
+func (l *chanListener) Addr() net.Addr {
+	return l.addr
+}
@@ -0,0 +1,169 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/codec"
+	"github.com/BadKid90s/chilix-msg/serializer"
+)
+
+func newTestSniffListener(t *testing.T, config SniffConfig) Listener {
+	t.Helper()
+	if config.WebSocket.CheckOrigin == nil {
+		config.WebSocket.CheckOrigin = allowAllOrigins
+	}
+	listener, err := NewSniffTransportWithConfig(config).Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { SafeClose(listener, "sniff-listener") })
+	return listener
+}
+
+func TestSniffTransport_Native(t *testing.T) {
+	listener := newTestSniffListener(t, SniffConfig{})
+
+	client, err := NewSniffTransport().Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(client, "sniff-client-connection")
+
+	c := codec.NewBalancedCodec(serializer.DefaultSerializer)
+	if err := c.Encode(client, 7, "hello", 42); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	defer SafeClose(server, "sniff-server-connection")
+	if got := ProtocolOf(server); got != "tcp" {
+		t.Errorf("Expected protocol tcp, got %q", got)
+	}
+
+	// 嗅探读取的魔数字节不能丢失
+	_ = server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	typeID, payload, requestID, err := c.Decode(server)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	var msg string
+	if err := serializer.DefaultSerializer.Deserialize(payload, &msg); err != nil {
+		t.Fatalf("Failed to deserialize: %v", err)
+	}
+	if typeID != 7 || requestID != 42 || msg != "hello" {
+		t.Errorf("Unexpected frame: type=%d request=%d payload=%q", typeID, requestID, msg)
+	}
+}
+
+func TestSniffTransport_WebSocket(t *testing.T) {
+	listener := newTestSniffListener(t, SniffConfig{WebSocket: WebSocketConfig{Path: "/ws"}})
+
+	client, err := NewWebSocketTransport().Dial("ws://" + listener.Addr().String() + "/ws")
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(client, "sniff-client-connection")
+
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	defer SafeClose(server, "sniff-server-connection")
+	if got := ProtocolOf(server); got != "websocket" {
+		t.Errorf("Expected protocol websocket, got %q", got)
+	}
+	if info, ok := HTTPRequestOf(server); !ok || info.Path != "/ws" {
+		t.Errorf("Expected HTTP request info for /ws, got %+v", info)
+	}
+
+	if _, err := client.Write([]byte("ping")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	buf := make([]byte, 4)
+	_ = server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(server, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("Unexpected read %q: %v", buf, err)
+	}
+
+	// 其他路径由HTTP服务器拒绝
+	if _, err := NewWebSocketTransport().Dial("ws://" + listener.Addr().String() + "/other"); err == nil {
+		t.Error("Expected dial to other path to fail")
+	}
+}
+
+func TestSniffTransport_Reject(t *testing.T) {
+	listener := newTestSniffListener(t, SniffConfig{SniffTimeout: 200 * time.Millisecond})
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Unknown", []byte("SSH-2.0-OpenSSH\r\n")},
+		{"HTTPPost", []byte("POST / HTTP/1.1\r\n\r\n")},
+		{"Silent", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", listener.Addr().String())
+			if err != nil {
+				t.Fatalf("Failed to dial: %v", err)
+			}
+			defer SafeClose(conn, "sniff-client-connection")
+			if tt.data != nil {
+				if _, err := conn.Write(tt.data); err != nil {
+					t.Fatalf("Failed to write: %v", err)
+				}
+			}
+
+			// 服务端直接关闭连接，不返回任何数据
+			_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+			n, err := conn.Read(make([]byte, 16))
+			if n != 0 || err == nil {
+				t.Fatalf("Expected connection to be closed, got n=%d err=%v", n, err)
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				t.Fatal("Connection was not closed by server")
+			}
+		})
+	}
+}
+
+func TestSniffTransport_Close(t *testing.T) {
+	listener := newTestSniffListener(t, SniffConfig{})
+
+	// 已识别但未被 Accept 取走的连接在关闭时被清理
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(client, "sniff-client-connection")
+	if _, err := client.Write(bytes.Repeat(nativeMagic, 2)); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if err := listener.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if _, err := listener.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Expected net.ErrClosed, got %v", err)
+	}
+
+	_ = client.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := client.Read(make([]byte, 1)); err == nil {
+		t.Error("Expected pending connection to be closed")
+	}
+	if conn, err := net.Dial("tcp", listener.Addr().String()); err == nil {
+		SafeClose(conn, "sniff-client-connection")
+		t.Error("Expected listener to be closed")
+	}
+}
@@ -26,6 +26,16 @@ type TestConfig struct {
 	ConnectionTimeout time.Duration
 	DataTimeout       time.Duration
 	SkipNetworkTests  bool
+	// Address 回显服务器监听地址，默认 127.0.0.1:0
+	Address string
+}
+
+// listenAddress 返回回显服务器的监听地址
+func (c TestConfig) listenAddress() string {
+	if c.Address == "" {
+		return "127.0.0.1:0"
+	}
+	return c.Address
 }
 
 // TestResult 测试结果
@@ -136,7 +146,7 @@ func RunBasicConnectionTest(config TestConfig) TestResult {
 	}
 
 	// 创建回显服务器
-	server, err := NewEchoServer(config.Transport, "127.0.0.1:0")
+	server, err := NewEchoServer(config.Transport, config.listenAddress())
 	if err != nil {
 		return TestResult{Success: false, Error: err, Message: "Failed to create server"}
 	}
@@ -196,7 +206,7 @@ func RunLargeDataTest(config TestConfig) TestResult {
 	}
 
 	// 创建回显服务器
-	server, err := NewEchoServer(config.Transport, "127.0.0.1:0")
+	server, err := NewEchoServer(config.Transport, config.listenAddress())
 	if err != nil {
 		return TestResult{Success: false, Error: err, Message: "Failed to create server"}
 	}
@@ -252,7 +262,7 @@ func RunTimeoutTest(config TestConfig) TestResult {
 	}
 
 	// 创建回显服务器
-	server, err := NewEchoServer(config.Transport, "127.0.0.1:0")
+	server, err := NewEchoServer(config.Transport, config.listenAddress())
 	if err != nil {
 		return TestResult{Success: false, Error: err, Message: "Failed to create server"}
 	}
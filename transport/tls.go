@@ -0,0 +1,290 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/log"
+)
+
+var (
+	// ErrNoCertificate 服务端未配置证书
+	ErrNoCertificate = errors.New("no server certificate configured")
+	// ErrNotTLSConnection 连接不是TLS连接
+	ErrNotTLSConnection = errors.New("connection is not a TLS connection")
+	// ErrNoPeerCertificate 对端未提供证书
+	ErrNoPeerCertificate = errors.New("peer did not present a certificate")
+)
+
+// TLSConnection 可提供TLS连接状态的连接
+// TLS传输返回的 *tls.Conn 和QUIC传输的连接均实现了该接口
+type TLSConnection interface {
+	Connection
+	ConnectionState() tls.ConnectionState
+}
+
+// PeerCertificate 返回对端证书链中的叶子证书，握手未完成时会先完成握手
+// 处理器和中间件可以据此按客户端证书主题进行授权
+func PeerCertificate(conn Connection) (*x509.Certificate, error) {
+	tlsConn, ok := As[TLSConnection](conn)
+	if !ok {
+		return nil, ErrNotTLSConnection
+	}
+	if hs, ok := tlsConn.(interface{ Handshake() error }); ok {
+		if err := hs.Handshake(); err != nil {
+			return nil, err
+		}
+	}
+	state := tlsConn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return nil, ErrNoPeerCertificate
+	}
+	return state.PeerCertificates[0], nil
+}
+
+// LoadCertPool 从一个或多个PEM文件加载CA证书池
+func LoadCertPool(files ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file %s: %w", file, err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no valid certificates found in %s", file)
+		}
+	}
+	return pool, nil
+}
+
+// cloneTLSConfig 复制用户提供的TLS配置，避免修改调用方的对象
+func cloneTLSConfig(config *tls.Config) *tls.Config {
+	if config == nil {
+		return &tls.Config{}
+	}
+	return config.Clone()
+}
+
+// hasServerCertificate 判断TLS配置是否能够提供服务端证书
+func hasServerCertificate(config *tls.Config) bool {
+	return len(config.Certificates) > 0 || config.GetCertificate != nil || config.GetConfigForClient != nil
+}
+
+// certReloader 在证书文件变化时重新加载证书，无需重启监听
+type certReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func newCertReloader(certFile, keyFile string, interval time.Duration) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload 重新加载证书文件
+func (r *certReloader) reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	r.lastCheck = time.Now()
+	return nil
+}
+
+// latestModTime 返回证书和私钥文件中较新的修改时间
+func (r *certReloader) latestModTime() (time.Time, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if keyInfo.ModTime().After(certInfo.ModTime()) {
+		return keyInfo.ModTime(), nil
+	}
+	return certInfo.ModTime(), nil
+}
+
+// certificate 返回当前证书，必要时检查文件是否更新
+func (r *certReloader) certificate() *tls.Certificate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) < r.interval {
+		return r.cert
+	}
+	r.lastCheck = time.Now()
+
+	modTime, err := r.latestModTime()
+	if err != nil || modTime.Equal(r.modTime) {
+		return r.cert
+	}
+	if err := r.reload(); err != nil {
+		// 加载失败时继续使用旧证书
+		log.Errorf("Failed to reload certificate %s: %v", r.certFile, err)
+	}
+	return r.cert
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.certificate(), nil
+}
+
+func (r *certReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.certificate(), nil
+}
+
+// TLSTransportConfig TLS传输配置
+type TLSTransportConfig struct {
+	// TLSConfig 基础TLS配置，以下字段会补充到它的副本上
+	TLSConfig *tls.Config
+	// CertFile 和 KeyFile 为PEM格式的证书与私钥，服务端用作服务证书，客户端用作客户端证书
+	// 文件更新后会在后续握手中自动加载新证书
+	CertFile string
+	KeyFile  string
+	// ReloadInterval 检查证书文件是否更新的最小间隔，0 表示每次握手都检查
+	ReloadInterval time.Duration
+	// RootCAs 客户端校验服务端证书使用的CA池，为空时使用系统CA
+	RootCAs *x509.CertPool
+	// ClientCAs 服务端校验客户端证书使用的CA池
+	ClientCAs *x509.CertPool
+	// ClientAuth 客户端证书校验策略，设置了 ClientCAs 且未指定时为 tls.RequireAndVerifyClientCert
+	ClientAuth tls.ClientAuthType
+	// ServerName 客户端SNI及校验的服务端名称，为空时取拨号地址中的主机名
+	ServerName string
+	// MinVersion 最低TLS版本，默认 tls.VersionTLS12
+	MinVersion uint16
+	// CipherSuites 允许的密码套件（仅对TLS 1.2及以下生效）
+	CipherSuites []uint16
+	// InsecureSkipVerify 客户端跳过证书校验，仅用于测试
+	InsecureSkipVerify bool
+	// HandshakeTimeout 客户端建立TCP连接并完成TLS握手的超时，默认10秒
+	HandshakeTimeout time.Duration
+}
+
+func (c TLSTransportConfig) handshakeTimeout() time.Duration {
+	if c.HandshakeTimeout > 0 {
+		return c.HandshakeTimeout
+	}
+	return 10 * time.Second
+}
+
+type tlsTransport struct {
+	config   TLSTransportConfig
+	reloader *certReloader
+}
+
+func (t *tlsTransport) Protocol() string {
+	return "tls"
+}
+
+// NewTLSTransport creates a new TCP transport secured with TLS.
+func NewTLSTransport(config TLSTransportConfig) (Transport, error) {
+	t := &tlsTransport{config: config}
+	if config.CertFile != "" || config.KeyFile != "" {
+		reloader, err := newCertReloader(config.CertFile, config.KeyFile, config.ReloadInterval)
+		if err != nil {
+			return nil, err
+		}
+		t.reloader = reloader
+	}
+	return t, nil
+}
+
+func (t *tlsTransport) Listen(address string) (Listener, error) {
+	conf, err := t.serverTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &tcpListener{Listener: tls.NewListener(l, conf)}, nil
+}
+
+func (t *tlsTransport) Dial(address string) (Connection, error) {
+	conf := t.clientTLSConfig()
+	if conf.ServerName == "" {
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			conf.ServerName = host
+		}
+	}
+	dialer := &net.Dialer{Timeout: t.config.handshakeTimeout()}
+	return tls.DialWithDialer(dialer, "tcp", address, conf)
+}
+
+// serverTLSConfig 构建服务端TLS配置
+func (t *tlsTransport) serverTLSConfig() (*tls.Config, error) {
+	conf := t.baseTLSConfig()
+	if t.reloader != nil {
+		conf.GetCertificate = t.reloader.GetCertificate
+	}
+	if !hasServerCertificate(conf) {
+		return nil, ErrNoCertificate
+	}
+	if t.config.ClientCAs != nil {
+		conf.ClientCAs = t.config.ClientCAs
+		conf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if t.config.ClientAuth != tls.NoClientCert {
+		conf.ClientAuth = t.config.ClientAuth
+	}
+	return conf, nil
+}
+
+// clientTLSConfig 构建客户端TLS配置
+func (t *tlsTransport) clientTLSConfig() *tls.Config {
+	conf := t.baseTLSConfig()
+	if t.reloader != nil {
+		conf.GetClientCertificate = t.reloader.GetClientCertificate
+	}
+	if t.config.RootCAs != nil {
+		conf.RootCAs = t.config.RootCAs
+	}
+	if t.config.ServerName != "" {
+		conf.ServerName = t.config.ServerName
+	}
+	if t.config.InsecureSkipVerify {
+		conf.InsecureSkipVerify = true
+	}
+	return conf
+}
+
+// baseTLSConfig 应用服务端和客户端共用的配置
+func (t *tlsTransport) baseTLSConfig() *tls.Config {
+	conf := cloneTLSConfig(t.config.TLSConfig)
+	if t.config.MinVersion != 0 {
+		conf.MinVersion = t.config.MinVersion
+	}
+	if conf.MinVersion == 0 {
+		conf.MinVersion = tls.VersionTLS12
+	}
+	if len(t.config.CipherSuites) > 0 {
+		conf.CipherSuites = t.config.CipherSuites
+	}
+	return conf
+}
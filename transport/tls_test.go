@@ -0,0 +1,444 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testPKI 测试用的本地CA
+type testPKI struct {
+	dir    string
+	caCert *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	caFile string
+	serial int64
+}
+
+// newTestPKI 在临时目录中生成CA证书
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "chilix-msg test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse CA certificate: %v", err)
+	}
+
+	pki := &testPKI{
+		dir:    t.TempDir(),
+		caCert: cert,
+		caKey:  key,
+		serial: 1,
+	}
+	pki.caFile = pki.writePEM(t, "ca.pem", "CERTIFICATE", der)
+	return pki
+}
+
+// issue 签发叶子证书，返回证书和私钥文件路径
+func (p *testPKI) issue(t *testing.T, commonName string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	p.serial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(p.serial),
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"chilix-msg"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, p.caCert, &key.PublicKey, p.caKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	certFile = p.writePEM(t, commonName+".pem", "CERTIFICATE", der)
+	keyFile = p.writePEM(t, commonName+"-key.pem", "PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func (p *testPKI) writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(p.dir, name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// certPool 返回只包含测试CA的证书池
+func (p *testPKI) certPool(t *testing.T) *x509.CertPool {
+	t.Helper()
+
+	pool, err := LoadCertPool(p.caFile)
+	if err != nil {
+		t.Fatalf("Failed to load CA pool: %v", err)
+	}
+	return pool
+}
+
+func TestLoadCertPool(t *testing.T) {
+	pki := newTestPKI(t)
+
+	pool, err := LoadCertPool(pki.caFile)
+	if err != nil {
+		t.Fatalf("LoadCertPool failed: %v", err)
+	}
+	if pool == nil {
+		t.Fatal("Expected non-nil pool")
+	}
+
+	// 不存在的文件
+	if _, err := LoadCertPool(filepath.Join(pki.dir, "missing.pem")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+
+	// 非证书内容
+	invalid := filepath.Join(pki.dir, "invalid.pem")
+	if err := os.WriteFile(invalid, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := LoadCertPool(invalid); err == nil {
+		t.Error("Expected error for invalid PEM file")
+	}
+}
+
+// newTestTLSTransport 创建使用测试CA签发证书的TLS传输
+func newTestTLSTransport(t *testing.T, pki *testPKI, commonName string, mutate func(*TLSTransportConfig)) Transport {
+	t.Helper()
+
+	certFile, keyFile := pki.issue(t, commonName)
+	config := TLSTransportConfig{
+		CertFile: certFile,
+		KeyFile:  keyFile,
+		RootCAs:  pki.certPool(t),
+	}
+	if mutate != nil {
+		mutate(&config)
+	}
+	tr, err := NewTLSTransport(config)
+	if err != nil {
+		t.Fatalf("Failed to create TLS transport: %v", err)
+	}
+	return tr
+}
+
+func TestTLSTransport_Protocol(t *testing.T) {
+	tr := newTestTLSTransport(t, newTestPKI(t), "server", nil)
+	if tr.Protocol() != "tls" {
+		t.Errorf("Expected protocol to be 'tls', got '%s'", tr.Protocol())
+	}
+}
+
+func TestTLSTransport_BasicConnection(t *testing.T) {
+	config := TestConfig{
+		ProtocolName:      "TLS",
+		Transport:         newTestTLSTransport(t, newTestPKI(t), "server", nil),
+		ConnectionTimeout: 5 * time.Second,
+		DataTimeout:       2 * time.Second,
+	}
+
+	result := RunBasicConnectionTest(config)
+	if !result.Success {
+		t.Fatalf("Basic connection test failed: %s - %v", result.Message, result.Error)
+	}
+
+	result = RunLargeDataTest(config)
+	if !result.Success {
+		t.Fatalf("Large data test failed: %s - %v", result.Message, result.Error)
+	}
+}
+
+func TestTLSTransport_MutualTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	serverTransport := newTestTLSTransport(t, pki, "server", func(c *TLSTransportConfig) {
+		c.ClientCAs = pki.certPool(t)
+	})
+	clientTransport := newTestTLSTransport(t, pki, "client-app", nil)
+
+	listener, err := serverTransport.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "tls-listener")
+
+	// 服务端握手需要与客户端拨号并发进行
+	type peerResult struct {
+		cert *x509.Certificate
+		err  error
+	}
+	serverPeer := make(chan peerResult, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			serverPeer <- peerResult{err: err}
+			return
+		}
+		defer SafeClose(conn, "tls-server-connection")
+		cert, err := PeerCertificate(conn)
+		serverPeer <- peerResult{cert: cert, err: err}
+	}()
+
+	clientConn, err := clientTransport.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(clientConn, "tls-client-connection")
+
+	// 服务端可以读取客户端证书身份
+	result := <-serverPeer
+	if result.err != nil {
+		t.Fatalf("PeerCertificate failed: %v", result.err)
+	}
+	if result.cert.Subject.CommonName != "client-app" {
+		t.Errorf("Expected client common name 'client-app', got '%s'", result.cert.Subject.CommonName)
+	}
+
+	// 客户端同样可以读取服务端证书身份
+	cert, err := PeerCertificate(clientConn)
+	if err != nil {
+		t.Fatalf("PeerCertificate failed: %v", err)
+	}
+	if cert.Subject.CommonName != "server" {
+		t.Errorf("Expected server common name 'server', got '%s'", cert.Subject.CommonName)
+	}
+}
+
+func TestTLSTransport_MutualTLSRejectsMissingClientCert(t *testing.T) {
+	pki := newTestPKI(t)
+	serverTransport := newTestTLSTransport(t, pki, "server", func(c *TLSTransportConfig) {
+		c.ClientCAs = pki.certPool(t)
+	})
+	clientTransport, err := NewTLSTransport(TLSTransportConfig{RootCAs: pki.certPool(t)})
+	if err != nil {
+		t.Fatalf("Failed to create client transport: %v", err)
+	}
+
+	server, err := NewEchoServer(serverTransport, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer SafeClose(server, "tls-echo-server")
+
+	conn, err := clientTransport.Dial(server.Addr().String())
+	if err != nil {
+		return // TLS 1.2 在握手阶段即失败
+	}
+	defer SafeClose(conn, "tls-client-connection")
+
+	// TLS 1.3 中客户端证书错误在握手后的首次读取时返回
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+	_, _ = conn.Write([]byte("ping"))
+	if _, err := conn.Read(make([]byte, 4)); err == nil {
+		t.Fatal("Expected connection without client certificate to be rejected")
+	}
+}
+
+func TestTLSTransport_CertificateReload(t *testing.T) {
+	pki := newTestPKI(t)
+	certFile, keyFile := pki.issue(t, "server-v1")
+	serverTransport, err := NewTLSTransport(TLSTransportConfig{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("Failed to create server transport: %v", err)
+	}
+	clientTransport, err := NewTLSTransport(TLSTransportConfig{RootCAs: pki.certPool(t)})
+	if err != nil {
+		t.Fatalf("Failed to create client transport: %v", err)
+	}
+
+	server, err := NewEchoServer(serverTransport, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer SafeClose(server, "tls-echo-server")
+
+	serverName := func() string {
+		conn, err := clientTransport.Dial(server.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer SafeClose(conn, "tls-client-connection")
+		cert, err := PeerCertificate(conn)
+		if err != nil {
+			t.Fatalf("PeerCertificate failed: %v", err)
+		}
+		return cert.Subject.CommonName
+	}
+
+	if name := serverName(); name != "server-v1" {
+		t.Fatalf("Expected 'server-v1', got '%s'", name)
+	}
+
+	// 替换证书文件，无需重启监听
+	newCert, newKey := pki.issue(t, "server-v2")
+	if err := os.Rename(newCert, certFile); err != nil {
+		t.Fatalf("Failed to replace certificate: %v", err)
+	}
+	if err := os.Rename(newKey, keyFile); err != nil {
+		t.Fatalf("Failed to replace key: %v", err)
+	}
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(certFile, future, future)
+	_ = os.Chtimes(keyFile, future, future)
+
+	if name := serverName(); name != "server-v2" {
+		t.Fatalf("Expected reloaded certificate 'server-v2', got '%s'", name)
+	}
+}
+
+func TestTLSTransport_MinVersion(t *testing.T) {
+	pki := newTestPKI(t)
+	serverTransport := newTestTLSTransport(t, pki, "server", func(c *TLSTransportConfig) {
+		c.MinVersion = tls.VersionTLS13
+	})
+	clientTransport, err := NewTLSTransport(TLSTransportConfig{
+		TLSConfig: &tls.Config{MaxVersion: tls.VersionTLS12},
+		RootCAs:   pki.certPool(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client transport: %v", err)
+	}
+
+	server, err := NewEchoServer(serverTransport, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer SafeClose(server, "tls-echo-server")
+
+	if conn, err := clientTransport.Dial(server.Addr().String()); err == nil {
+		SafeClose(conn, "tls-client-connection")
+		t.Fatal("Expected handshake to fail below minimum version")
+	}
+}
+
+func TestTLSTransport_HandshakeTimeout(t *testing.T) {
+	// 只接受TCP连接、从不响应握手的服务端
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(l, "silent-listener")
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer SafeClose(conn, "silent-connection")
+		}
+	}()
+
+	tr, err := NewTLSTransport(TLSTransportConfig{HandshakeTimeout: 100 * time.Millisecond, InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("Failed to create transport: %v", err)
+	}
+	start := time.Now()
+	if conn, err := tr.Dial(l.Addr().String()); err == nil {
+		SafeClose(conn, "tls-client-connection")
+		t.Fatal("Expected handshake to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Handshake timeout not applied, took %v", elapsed)
+	}
+}
+
+func TestTLSTransport_MissingCertificate(t *testing.T) {
+	tr, err := NewTLSTransport(TLSTransportConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create transport: %v", err)
+	}
+	if _, err := tr.Listen("127.0.0.1:0"); !errors.Is(err, ErrNoCertificate) {
+		t.Fatalf("Expected ErrNoCertificate, got %v", err)
+	}
+}
+
+func TestPeerCertificate_NotTLS(t *testing.T) {
+	server, err := NewEchoServer(NewTCPTransport(), "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer SafeClose(server, "tcp-echo-server")
+
+	conn, err := NewTCPTransport().Dial(server.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(conn, "tcp-client-connection")
+
+	if _, err := PeerCertificate(conn); !errors.Is(err, ErrNotTLSConnection) {
+		t.Fatalf("Expected ErrNotTLSConnection, got %v", err)
+	}
+}
+
+func TestQUICTransport_PeerCertificate(t *testing.T) {
+	pki := newTestPKI(t)
+	certFile, keyFile := pki.issue(t, "quic-server")
+	serverTransport, err := NewQUICTransportWithConfig(QUICConfig{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("Failed to create server transport: %v", err)
+	}
+	clientTransport, err := NewQUICTransportWithConfig(QUICConfig{RootCAs: pki.certPool(t)})
+	if err != nil {
+		t.Fatalf("Failed to create client transport: %v", err)
+	}
+
+	server, err := NewEchoServer(serverTransport, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer SafeClose(server, "quic-echo-server")
+
+	conn, err := clientTransport.Dial(server.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(conn, "quic-client-connection")
+
+	cert, err := PeerCertificate(conn)
+	if err != nil {
+		t.Fatalf("PeerCertificate failed: %v", err)
+	}
+	if cert.Subject.CommonName != "quic-server" {
+		t.Errorf("Expected 'quic-server', got '%s'", cert.Subject.CommonName)
+	}
+}
@@ -22,3 +22,25 @@ type Transport interface {
 	Dial(address string) (Connection, error)
 	Protocol() string
 }
+
+// ConnectionWrapper 包装了其他连接的连接，例如多协议监听器返回的带协议标记的连接
+type ConnectionWrapper interface {
+	Unwrap() Connection
+}
+
+// As 沿着包装链查找第一个实现了 T 的连接
+// 例如 transport.As[transport.WebSocketConnection](conn)
+func As[T any](conn Connection) (T, bool) {
+	for conn != nil {
+		if t, ok := conn.(T); ok {
+			return t, true
+		}
+		w, ok := conn.(ConnectionWrapper)
+		if !ok {
+			break
+		}
+		conn = w.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
@@ -16,6 +16,7 @@ func TestTransportImplementations(t *testing.T) {
 		{"WebSocket", NewWebSocketTransport()},
 		{"KCP", NewKCPTransport()},
 		{"QUIC", NewQUICTransport()},
+		{"Memory", NewMemoryTransport()},
 	}
 
 	// 验证每个实现都正确实现了Transport接口
@@ -57,6 +58,7 @@ func TestGenericConnectionBehavior(t *testing.T) {
 		{"WebSocket", NewWebSocketTransport()},
 		{"KCP", NewKCPTransport()},
 		{"QUIC", NewQUICTransport()},
+		{"Memory", NewMemoryTransport()},
 	}
 
 	for _, tt := range transports {
@@ -90,6 +92,7 @@ func TestConnectionInterface(t *testing.T) {
 		{"WebSocket", NewWebSocketTransport()},
 		{"KCP", NewKCPTransport()},
 		{"QUIC", NewQUICTransport()},
+		{"Memory", NewMemoryTransport()},
 	}
 
 	for _, tt := range transports {
@@ -183,6 +186,7 @@ func TestTransportPerformance(t *testing.T) {
 		{"WebSocket", NewWebSocketTransport()},
 		{"KCP", NewKCPTransport()},
 		{"QUIC", NewQUICTransport()},
+		{"Memory", NewMemoryTransport()},
 	}
 
 	for _, tt := range transports {
@@ -230,6 +234,7 @@ func TestTransportErrorHandling(t *testing.T) {
 		{"WebSocket", NewWebSocketTransport()},
 		{"KCP", NewKCPTransport()},
 		{"QUIC", NewQUICTransport()},
+		{"Memory", NewMemoryTransport()},
 	}
 
 	for _, tt := range transports {
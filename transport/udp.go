@@ -0,0 +1,337 @@
+package transport
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BadKid90s/chilix-msg/log"
+)
+
+// UDPConfig UDP数据报传输配置
+type UDPConfig struct {
+	// MaxPacketSize 单个数据报的最大字节数（帧头+负载），默认1200，可以避免大多数网络上的IP分片
+	MaxPacketSize int
+	// Backlog 等待 Accept 的新会话上限，超过时丢弃新对端的数据报，默认128
+	Backlog int
+	// QueueSize 每个服务端会话的接收队列长度，队列满时丢弃数据报，默认256
+	QueueSize int
+	// IdleTimeout 服务端会话在没有收到数据时自动关闭的时间，0 表示不超时
+	IdleTimeout time.Duration
+}
+
+func (c UDPConfig) maxPacketSize() int {
+	if c.MaxPacketSize > 0 {
+		return c.MaxPacketSize
+	}
+	return 1200
+}
+
+func (c UDPConfig) backlog() int {
+	if c.Backlog > 0 {
+		return c.Backlog
+	}
+	return 128
+}
+
+func (c UDPConfig) queueSize() int {
+	if c.QueueSize > 0 {
+		return c.QueueSize
+	}
+	return 256
+}
+
+// udpConn 客户端使用的已连接UDP socket
+type udpConn struct {
+	*net.UDPConn
+	maxPacketSize int
+}
+
+func (c *udpConn) Write(b []byte) (int, error) {
+	if len(b) > c.maxPacketSize {
+		return 0, packetTooLarge(len(b), c.maxPacketSize)
+	}
+	return c.UDPConn.Write(b)
+}
+
+func (c *udpConn) MaxPacketSize() int {
+	return c.maxPacketSize
+}
+
+// udpSession 服务端与一个远端地址之间的会话，共享监听socket
+type udpSession struct {
+	listener   *udpListener
+	remoteAddr *net.UDPAddr
+	queue      chan []byte
+
+	readDeadline pipeDeadline
+	lastActive   atomic.Int64
+	closeOnce    sync.Once
+	done         chan struct{}
+}
+
+// deliver 将数据报放入接收队列，队列满时丢弃
+func (s *udpSession) deliver(data []byte) {
+	s.lastActive.Store(time.Now().UnixNano())
+	select {
+	case s.queue <- data:
+	default:
+		log.Debugf("UDP session %s queue full, dropping datagram", s.remoteAddr)
+	}
+}
+
+func (s *udpSession) Read(b []byte) (int, error) {
+	select {
+	case <-s.done:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	select {
+	case data := <-s.queue:
+		return copy(b, data), nil
+	case <-s.readDeadline.wait():
+		return 0, os.ErrDeadlineExceeded
+	case <-s.done:
+		return 0, net.ErrClosed
+	}
+}
+
+func (s *udpSession) Write(b []byte) (int, error) {
+	select {
+	case <-s.done:
+		return 0, net.ErrClosed
+	default:
+	}
+	if len(b) > s.listener.config.maxPacketSize() {
+		return 0, packetTooLarge(len(b), s.listener.config.maxPacketSize())
+	}
+	return s.listener.conn.WriteToUDP(b, s.remoteAddr)
+}
+
+func (s *udpSession) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.listener.removeSession(s)
+	})
+	return nil
+}
+
+func (s *udpSession) MaxPacketSize() int {
+	return s.listener.config.maxPacketSize()
+}
+
+func (s *udpSession) LocalAddr() net.Addr {
+	return s.listener.conn.LocalAddr()
+}
+
+func (s *udpSession) RemoteAddr() net.Addr {
+	return s.remoteAddr
+}
+
+func (s *udpSession) SetDeadline(t time.Time) error {
+	return s.SetReadDeadline(t)
+}
+
+func (s *udpSession) SetReadDeadline(t time.Time) error {
+	s.readDeadline.set(t)
+	return nil
+}
+
+// SetWriteDeadline 会话共享监听socket，UDP写入不会阻塞，忽略写超时
+func (s *udpSession) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+// udpListener 按远端地址将数据报分发到各个会话
+type udpListener struct {
+	conn   *net.UDPConn
+	config UDPConfig
+
+	mu       sync.Mutex
+	sessions map[string]*udpSession
+
+	acceptCh  chan *udpSession
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+func (l *udpListener) readLoop() {
+	buf := make([]byte, MaxDatagramSize)
+	for {
+		n, addr, err := l.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				_ = l.Close()
+				return
+			}
+			// ICMP不可达等错误不影响其他会话
+			log.Debugf("UDP read error: %v", err)
+			continue
+		}
+		data := make([]byte, n)
+		copy(data, buf[:n])
+
+		session, ok := l.session(addr)
+		if ok {
+			session.deliver(data)
+		}
+	}
+}
+
+// session 查找远端地址对应的会话，不存在时创建并交给 Accept
+func (l *udpListener) session(addr *net.UDPAddr) (*udpSession, bool) {
+	key := addr.String()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if s, ok := l.sessions[key]; ok {
+		return s, true
+	}
+	if l.sessions == nil {
+		return nil, false // 已关闭
+	}
+
+	s := &udpSession{
+		listener:     l,
+		remoteAddr:   addr,
+		queue:        make(chan []byte, l.config.queueSize()),
+		readDeadline: makePipeDeadline(),
+		done:         make(chan struct{}),
+	}
+	s.lastActive.Store(time.Now().UnixNano())
+	select {
+	case l.acceptCh <- s:
+		l.sessions[key] = s
+		return s, true
+	default:
+		log.Warnf("UDP accept backlog full, dropping datagram from %s", addr)
+		return nil, false
+	}
+}
+
+func (l *udpListener) removeSession(s *udpSession) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.sessions[s.remoteAddr.String()] == s {
+		delete(l.sessions, s.remoteAddr.String())
+	}
+}
+
+// reapIdle 定期关闭空闲会话
+func (l *udpListener) reapIdle(timeout time.Duration) {
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.closeCh:
+			return
+		case <-ticker.C:
+			var idle []*udpSession
+			l.mu.Lock()
+			for _, s := range l.sessions {
+				if time.Since(time.Unix(0, s.lastActive.Load())) > timeout {
+					idle = append(idle, s)
+				}
+			}
+			l.mu.Unlock()
+			for _, s := range idle {
+				_ = s.Close()
+			}
+		}
+	}
+}
+
+func (l *udpListener) Accept() (PacketConn, error) {
+	select {
+	case s := <-l.acceptCh:
+		return s, nil
+	case <-l.closeCh:
+		return nil, &net.OpError{Op: "accept", Net: "udp", Addr: l.conn.LocalAddr(), Err: net.ErrClosed}
+	}
+}
+
+// Close 关闭监听socket和所有会话
+func (l *udpListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closeCh)
+		err = l.conn.Close()
+		if errors.Is(err, net.ErrClosed) {
+			err = nil
+		}
+
+		l.mu.Lock()
+		sessions := l.sessions
+		l.sessions = nil
+		l.mu.Unlock()
+		for _, s := range sessions {
+			s.closeOnce.Do(func() { close(s.done) })
+		}
+	})
+	return err
+}
+
+func (l *udpListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+type udpTransport struct {
+	config UDPConfig
+}
+
+func (t *udpTransport) Protocol() string {
+	return "udp"
+}
+
+// NewUDPTransport creates a new datagram transport over UDP.
+// 每个帧对应一个数据报，发送是尽力而为的，不保证送达和顺序。
+func NewUDPTransport() PacketTransport {
+	return &udpTransport{}
+}
+
+// NewUDPTransportWithConfig creates a new UDP datagram transport with the given settings.
+func NewUDPTransportWithConfig(config UDPConfig) PacketTransport {
+	return &udpTransport{config: config}
+}
+
+func (t *udpTransport) Listen(address string) (PacketListener, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &udpListener{
+		conn:     conn,
+		config:   t.config,
+		sessions: make(map[string]*udpSession),
+		acceptCh: make(chan *udpSession, t.config.backlog()),
+		closeCh:  make(chan struct{}),
+	}
+	go l.readLoop()
+	if t.config.IdleTimeout > 0 {
+		go l.reapIdle(t.config.IdleTimeout)
+	}
+	return l, nil
+}
+
+func (t *udpTransport) Dial(address string) (PacketConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &udpConn{UDPConn: conn, maxPacketSize: t.config.maxPacketSize()}, nil
+}
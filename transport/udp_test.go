@@ -0,0 +1,208 @@
+package transport
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// acceptPacket 在协程中接受一个数据报会话
+func acceptPacket(listener PacketListener) <-chan PacketConn {
+	ch := make(chan PacketConn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(ch)
+			return
+		}
+		ch <- conn
+	}()
+	return ch
+}
+
+// readPacket 在超时内读取一个数据报
+func readPacket(t *testing.T, conn PacketConn) string {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, MaxDatagramSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to read datagram: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestUDPTransport_Protocol(t *testing.T) {
+	if p := NewUDPTransport().Protocol(); p != "udp" {
+		t.Errorf("Expected protocol to be 'udp', got '%s'", p)
+	}
+}
+
+func TestUDPTransport_Datagrams(t *testing.T) {
+	transport := NewUDPTransport()
+	listener, err := transport.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "udp-listener")
+
+	accepted := acceptPacket(listener)
+	client, err := transport.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(client, "udp-client")
+
+	// 每次写入是一个独立的数据报，读取时保留边界
+	for _, msg := range []string{"first", "second"} {
+		if _, err := client.Write([]byte(msg)); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+	}
+
+	var session PacketConn
+	select {
+	case session = <-accepted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for session")
+	}
+	defer SafeClose(session, "udp-session")
+
+	if got := readPacket(t, session); got != "first" {
+		t.Errorf("Expected 'first', got %q", got)
+	}
+	if got := readPacket(t, session); got != "second" {
+		t.Errorf("Expected 'second', got %q", got)
+	}
+	if session.RemoteAddr().String() != client.LocalAddr().String() {
+		t.Errorf("Expected session remote %s, got %s", client.LocalAddr(), session.RemoteAddr())
+	}
+
+	if _, err := session.Write([]byte("reply")); err != nil {
+		t.Fatalf("Failed to write reply: %v", err)
+	}
+	if got := readPacket(t, client); got != "reply" {
+		t.Errorf("Expected 'reply', got %q", got)
+	}
+}
+
+func TestUDPTransport_SessionDemux(t *testing.T) {
+	transport := NewUDPTransport()
+	listener, err := transport.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "udp-listener")
+
+	sessions := make(map[string]PacketConn)
+	for _, name := range []string{"a", "b"} {
+		accepted := acceptPacket(listener)
+		client, err := transport.Dial(listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer SafeClose(client, "udp-client")
+		if _, err := client.Write([]byte(name)); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+		session := <-accepted
+		if session == nil {
+			t.Fatal("Failed to accept session")
+		}
+		defer SafeClose(session, "udp-session")
+		sessions[name] = session
+	}
+
+	// 每个对端有独立的会话
+	if got := readPacket(t, sessions["a"]); got != "a" {
+		t.Errorf("Expected session a to receive 'a', got %q", got)
+	}
+	if got := readPacket(t, sessions["b"]); got != "b" {
+		t.Errorf("Expected session b to receive 'b', got %q", got)
+	}
+}
+
+func TestUDPTransport_PacketTooLarge(t *testing.T) {
+	transport := NewUDPTransportWithConfig(UDPConfig{MaxPacketSize: 512})
+	listener, err := transport.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "udp-listener")
+
+	client, err := transport.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(client, "udp-client")
+
+	if client.MaxPacketSize() != 512 {
+		t.Errorf("Expected MaxPacketSize 512, got %d", client.MaxPacketSize())
+	}
+	_, err = client.Write(make([]byte, 513))
+	if !errors.Is(err, ErrPacketTooLarge) {
+		t.Fatalf("Expected ErrPacketTooLarge, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "513") || !strings.Contains(err.Error(), "512") {
+		t.Errorf("Expected error to include frame size and MTU, got %v", err)
+	}
+}
+
+func TestUDPListener_Close(t *testing.T) {
+	transport := NewUDPTransport()
+	listener, err := transport.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	accepted := acceptPacket(listener)
+	client, err := transport.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(client, "udp-client")
+	_, _ = client.Write([]byte("hello"))
+	session := <-accepted
+	if session == nil {
+		t.Fatal("Failed to accept session")
+	}
+	readPacket(t, session)
+
+	// 关闭监听器后会话读取返回 net.ErrClosed
+	SafeClose(listener, "udp-listener")
+	if _, err := session.Read(make([]byte, 16)); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Expected net.ErrClosed, got %v", err)
+	}
+	if _, err := listener.Accept(); err == nil {
+		t.Error("Expected Accept to fail after close")
+	}
+}
+
+func TestUDPListener_IdleTimeout(t *testing.T) {
+	transport := NewUDPTransportWithConfig(UDPConfig{IdleTimeout: 100 * time.Millisecond})
+	listener, err := transport.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "udp-listener")
+
+	accepted := acceptPacket(listener)
+	client, err := transport.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(client, "udp-client")
+	_, _ = client.Write([]byte("hello"))
+	session := <-accepted
+	if session == nil {
+		t.Fatal("Failed to accept session")
+	}
+	readPacket(t, session)
+
+	_ = session.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := session.Read(make([]byte, 16)); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Expected idle session to be closed, got %v", err)
+	}
+}
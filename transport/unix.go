@@ -0,0 +1,198 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrAbstractUnixUnsupported 当前平台不支持抽象命名空间socket
+	ErrAbstractUnixUnsupported = errors.New("abstract unix sockets are only supported on linux")
+	// ErrNotUnixConnection 连接不是Unix域socket连接
+	ErrNotUnixConnection = errors.New("connection is not a unix socket connection")
+	// ErrPeerCredentialsUnsupported 当前平台不支持获取对端凭证
+	ErrPeerCredentialsUnsupported = errors.New("peer credentials are not supported on this platform")
+)
+
+// PeerCredentials Unix域socket对端进程的凭证
+type PeerCredentials struct {
+	PID int32
+	UID uint32
+	GID uint32
+}
+
+// UnixConnection 可提供对端凭证的Unix域socket连接
+type UnixConnection interface {
+	Connection
+	// PeerCredentials 返回对端进程的凭证（Linux上通过 SO_PEERCRED 获取）
+	PeerCredentials() (*PeerCredentials, error)
+}
+
+// PeerCredentialsOf 返回Unix域socket连接对端进程的凭证
+// 处理器和中间件可以据此按uid/gid进行授权
+func PeerCredentialsOf(conn Connection) (*PeerCredentials, error) {
+	uc, ok := As[UnixConnection](conn)
+	if !ok {
+		return nil, ErrNotUnixConnection
+	}
+	return uc.PeerCredentials()
+}
+
+// unixConn wraps a net.UnixConn to expose peer credentials.
+type unixConn struct {
+	*net.UnixConn
+}
+
+func (c *unixConn) PeerCredentials() (*PeerCredentials, error) {
+	return peerCredentials(c.UnixConn)
+}
+
+// unixListener implements the transport.Listener interface for unix sockets.
+type unixListener struct {
+	*net.UnixListener
+	path string // 重命名到的socket路径，关闭时删除；为空时由 UnixListener 自行处理
+}
+
+func (l *unixListener) Accept() (Connection, error) {
+	conn, err := l.UnixListener.AcceptUnix()
+	if err != nil {
+		return nil, err
+	}
+	return &unixConn{UnixConn: conn}, nil
+}
+
+func (l *unixListener) Addr() net.Addr {
+	if l.path != "" {
+		return &net.UnixAddr{Name: l.path, Net: "unix"}
+	}
+	return l.UnixListener.Addr()
+}
+
+func (l *unixListener) Close() error {
+	err := l.UnixListener.Close()
+	if l.path != "" {
+		if removeErr := os.Remove(l.path); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) && err == nil {
+			err = removeErr
+		}
+	}
+	return err
+}
+
+// UnixConfig Unix域socket传输配置
+type UnixConfig struct {
+	// Mode socket文件权限，例如 0600，0 表示保持umask决定的默认权限
+	// 抽象命名空间socket没有文件，忽略该配置
+	Mode os.FileMode
+}
+
+type unixTransport struct {
+	config UnixConfig
+}
+
+func (t *unixTransport) Protocol() string {
+	return "unix"
+}
+
+// NewUnixTransport creates a new transport over unix stream sockets.
+// 地址为socket文件路径，以 "@" 开头时使用Linux抽象命名空间。
+func NewUnixTransport() Transport {
+	return &unixTransport{}
+}
+
+// NewUnixTransportWithConfig creates a new unix socket transport with the given settings.
+func NewUnixTransportWithConfig(config UnixConfig) Transport {
+	return &unixTransport{config: config}
+}
+
+// isAbstractUnixAddress 判断是否为抽象命名空间地址
+func isAbstractUnixAddress(address string) bool {
+	return strings.HasPrefix(address, "@")
+}
+
+func (t *unixTransport) Listen(address string) (Listener, error) {
+	abstract := isAbstractUnixAddress(address)
+	if abstract && !abstractUnixSupported {
+		return nil, ErrAbstractUnixUnsupported
+	}
+	if !abstract {
+		if err := removeStaleSocket(address); err != nil {
+			return nil, err
+		}
+	}
+
+	if !abstract && t.config.Mode != 0 {
+		return listenUnixWithMode(address, t.config.Mode)
+	}
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: address, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	return &unixListener{UnixListener: l}, nil
+}
+
+// listenUnixWithMode 在权限为0700的临时目录中创建socket并设置权限，再重命名到目标路径，
+// 权限设置完成之前其他用户无法连接
+func listenUnixWithMode(address string, mode os.FileMode) (Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(address), ".chilix-sock-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	tmp := filepath.Join(dir, "s")
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	l.SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, mode); err != nil {
+		_ = l.Close()
+		return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+	if err := os.Rename(tmp, address); err != nil {
+		_ = l.Close()
+		return nil, fmt.Errorf("failed to move socket into place: %w", err)
+	}
+	return &unixListener{UnixListener: l, path: address}, nil
+}
+
+func (t *unixTransport) Dial(address string) (Connection, error) {
+	if isAbstractUnixAddress(address) && !abstractUnixSupported {
+		return nil, ErrAbstractUnixUnsupported
+	}
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: address, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	return &unixConn{UnixConn: conn}, nil
+}
+
+// removeStaleSocket 删除上次进程异常退出遗留的socket文件
+// 仍有进程在监听的socket和非socket文件不会被删除
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("socket %s is already in use", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package transport
+
+import (
+	"net"
+	"syscall"
+)
+
+// abstractUnixSupported Linux支持抽象命名空间socket
+const abstractUnixSupported = true
+
+// peerCredentials 通过 SO_PEERCRED 获取对端进程凭证
+func peerCredentials(conn *net.UnixConn) (*PeerCredentials, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if credErr != nil {
+		return nil, credErr
+	}
+	return &PeerCredentials{PID: cred.Pid, UID: cred.Uid, GID: cred.Gid}, nil
+}
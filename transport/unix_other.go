@@ -0,0 +1,13 @@
+//go:build !linux
+
+package transport
+
+import "net"
+
+// abstractUnixSupported 抽象命名空间socket仅在Linux上可用
+const abstractUnixSupported = false
+
+// peerCredentials 非Linux平台暂不支持获取对端凭证
+func peerCredentials(*net.UnixConn) (*PeerCredentials, error) {
+	return nil, ErrPeerCredentialsUnsupported
+}
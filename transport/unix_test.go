@@ -0,0 +1,201 @@
+package transport
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// unixSocketPath 返回测试用的socket路径
+func unixSocketPath(t *testing.T) string {
+	t.Helper()
+	return filepath.Join(t.TempDir(), "chilix.sock")
+}
+
+func TestUnixTransport_Protocol(t *testing.T) {
+	transport := NewUnixTransport()
+	if transport.Protocol() != "unix" {
+		t.Errorf("Expected protocol to be 'unix', got '%s'", transport.Protocol())
+	}
+}
+
+func TestUnixTransport_BasicConnection(t *testing.T) {
+	config := TestConfig{
+		ProtocolName:      "Unix",
+		Transport:         NewUnixTransport(),
+		ConnectionTimeout: 5 * time.Second,
+		DataTimeout:       2 * time.Second,
+		Address:           unixSocketPath(t),
+	}
+
+	result := RunBasicConnectionTest(config)
+	if !result.Success {
+		t.Fatalf("Basic connection test failed: %s - %v", result.Message, result.Error)
+	}
+}
+
+func TestUnixTransport_LargeData(t *testing.T) {
+	config := TestConfig{
+		ProtocolName:      "Unix",
+		Transport:         NewUnixTransport(),
+		ConnectionTimeout: 10 * time.Second,
+		DataTimeout:       5 * time.Second,
+		Address:           unixSocketPath(t),
+	}
+
+	result := RunLargeDataTest(config)
+	if !result.Success {
+		t.Fatalf("Large data test failed: %s - %v", result.Message, result.Error)
+	}
+}
+
+func TestUnixTransport_Permissions(t *testing.T) {
+	path := unixSocketPath(t)
+	listener, err := NewUnixTransportWithConfig(UnixConfig{Mode: 0600}).Listen(path)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat socket: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected socket mode 0600, got %o", info.Mode().Perm())
+	}
+	if listener.Addr().String() != path {
+		t.Errorf("Expected listener address %s, got %s", path, listener.Addr())
+	}
+
+	// socket在临时目录中创建后移动到目标路径，临时目录不会遗留
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("Failed to read socket directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the socket in its directory, got %d entries", len(entries))
+	}
+
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			SafeClose(conn, "unix-server-connection")
+		}
+	}()
+	conn, err := NewUnixTransport().Dial(path)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	SafeClose(conn, "unix-client-connection")
+
+	SafeClose(listener, "unix-listener")
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected socket file to be removed on close, got %v", err)
+	}
+}
+
+func TestUnixTransport_StaleSocketCleanup(t *testing.T) {
+	path := unixSocketPath(t)
+
+	// 模拟进程异常退出遗留的socket文件
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatalf("Failed to create stale socket: %v", err)
+	}
+	l.SetUnlinkOnClose(false)
+	_ = l.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("Expected stale socket file to exist: %v", err)
+	}
+
+	transport := NewUnixTransport()
+	listener, err := transport.Listen(path)
+	if err != nil {
+		t.Fatalf("Expected stale socket to be replaced, got %v", err)
+	}
+	defer SafeClose(listener, "unix-listener")
+
+	// 正在使用的socket不会被删除
+	if _, err := transport.Listen(path); err == nil {
+		t.Fatal("Expected listen on an active socket to fail")
+	}
+
+	// 非socket文件不会被删除
+	file := filepath.Join(t.TempDir(), "regular")
+	if err := os.WriteFile(file, []byte("data"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := transport.Listen(file); err == nil {
+		t.Fatal("Expected listen on a regular file to fail")
+	}
+}
+
+func TestUnixTransport_AbstractNamespace(t *testing.T) {
+	address := "@chilix-msg-test-" + filepath.Base(t.TempDir())
+	if runtime.GOOS != "linux" {
+		if _, err := NewUnixTransport().Listen(address); !errors.Is(err, ErrAbstractUnixUnsupported) {
+			t.Fatalf("Expected ErrAbstractUnixUnsupported, got %v", err)
+		}
+		return
+	}
+
+	config := TestConfig{
+		ProtocolName: "Unix",
+		Transport:    NewUnixTransport(),
+		Address:      address,
+	}
+	result := RunBasicConnectionTest(config)
+	if !result.Success {
+		t.Fatalf("Basic connection test failed: %s - %v", result.Message, result.Error)
+	}
+}
+
+func TestUnixTransport_PeerCredentials(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("SO_PEERCRED is only available on linux")
+	}
+
+	transport := NewUnixTransport()
+	listener, err := transport.Listen(unixSocketPath(t))
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "unix-listener")
+
+	accepted := make(chan Connection, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+		close(accepted)
+	}()
+
+	clientConn, err := transport.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(clientConn, "unix-client-connection")
+
+	serverConn := <-accepted
+	if serverConn == nil {
+		t.Fatal("Failed to accept connection")
+	}
+	defer SafeClose(serverConn, "unix-server-connection")
+
+	cred, err := PeerCredentialsOf(serverConn)
+	if err != nil {
+		t.Fatalf("Failed to get peer credentials: %v", err)
+	}
+	if int(cred.PID) != os.Getpid() || int(cred.UID) != os.Getuid() || int(cred.GID) != os.Getgid() {
+		t.Errorf("Unexpected peer credentials: %+v", cred)
+	}
+
+	if _, err := PeerCredentialsOf(&net.TCPConn{}); !errors.Is(err, ErrNotUnixConnection) {
+		t.Errorf("Expected ErrNotUnixConnection, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package transport
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// URLOptions 解析URL查询参数形式的传输选项
+// 解析失败时记录第一个错误，Err 还会报告未被读取的参数，避免配置拼写错误被静默忽略
+type URLOptions struct {
+	values url.Values
+	used   map[string]bool
+	err    error
+}
+
+// NewURLOptions 从URL的查询参数创建选项
+func NewURLOptions(u *url.URL) *URLOptions {
+	return &URLOptions{values: u.Query(), used: make(map[string]bool)}
+}
+
+// Has 判断是否提供了参数
+func (o *URLOptions) Has(key string) bool {
+	_, ok := o.values[key]
+	return ok
+}
+
+// Empty 判断是否没有任何参数
+func (o *URLOptions) Empty() bool {
+	return len(o.values) == 0
+}
+
+func (o *URLOptions) lookup(key string) (string, bool) {
+	if !o.Has(key) {
+		return "", false
+	}
+	o.used[key] = true
+	return o.values.Get(key), true
+}
+
+func (o *URLOptions) fail(key, value string, err error) {
+	if o.err == nil {
+		o.err = fmt.Errorf("invalid option %s=%q: %w", key, value, err)
+	}
+}
+
+// String 读取字符串参数
+func (o *URLOptions) String(key string, dst *string) {
+	if v, ok := o.lookup(key); ok {
+		*dst = v
+	}
+}
+
+// Strings 读取逗号分隔的字符串列表
+func (o *URLOptions) Strings(key string, dst *[]string) {
+	if v, ok := o.lookup(key); ok && v != "" {
+		*dst = strings.Split(v, ",")
+	}
+}
+
+// Bool 读取布尔参数，只写参数名（如 "?insecure"）视为 true
+func (o *URLOptions) Bool(key string, dst *bool) {
+	v, ok := o.lookup(key)
+	if !ok {
+		return
+	}
+	if v == "" {
+		*dst = true
+		return
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		o.fail(key, v, err)
+		return
+	}
+	*dst = b
+}
+
+// Int 读取整数参数
+func (o *URLOptions) Int(key string, dst *int) {
+	if v, ok := o.lookup(key); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			o.fail(key, v, err)
+			return
+		}
+		*dst = n
+	}
+}
+
+// Int64 读取64位整数参数
+func (o *URLOptions) Int64(key string, dst *int64) {
+	if v, ok := o.lookup(key); ok {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			o.fail(key, v, err)
+			return
+		}
+		*dst = n
+	}
+}
+
+// Float 读取浮点数参数
+func (o *URLOptions) Float(key string, dst *float64) {
+	if v, ok := o.lookup(key); ok {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			o.fail(key, v, err)
+			return
+		}
+		*dst = f
+	}
+}
+
+// Duration 读取时间参数，格式同 time.ParseDuration，例如 "30s"
+func (o *URLOptions) Duration(key string, dst *time.Duration) {
+	if v, ok := o.lookup(key); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			o.fail(key, v, err)
+			return
+		}
+		*dst = d
+	}
+}
+
+// FileMode 读取八进制文件权限，例如 "0600"
+func (o *URLOptions) FileMode(key string, dst *os.FileMode) {
+	if v, ok := o.lookup(key); ok {
+		n, err := strconv.ParseUint(v, 8, 32)
+		if err != nil {
+			o.fail(key, v, err)
+			return
+		}
+		*dst = os.FileMode(n)
+	}
+}
+
+// TLSVersion 读取TLS版本，支持 "1.0" "1.1" "1.2" "1.3"
+func (o *URLOptions) TLSVersion(key string, dst *uint16) {
+	v, ok := o.lookup(key)
+	if !ok {
+		return
+	}
+	versions := map[string]uint16{
+		"1.0": tls.VersionTLS10,
+		"1.1": tls.VersionTLS11,
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	}
+	version, ok := versions[v]
+	if !ok {
+		o.fail(key, v, fmt.Errorf("unknown TLS version"))
+		return
+	}
+	*dst = version
+}
+
+// Err 返回解析过程中的第一个错误，或者未被识别的参数
+func (o *URLOptions) Err() error {
+	if o.err != nil {
+		return o.err
+	}
+	var unknown []string
+	for key := range o.values {
+		if !o.used[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown options: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
@@ -1,22 +1,135 @@
 package transport
 
 import (
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/BadKid90s/chilix-msg/log"
 	"github.com/gorilla/websocket"
 )
 
+// WebSocketConnection 可提供WebSocket握手信息的连接
+type WebSocketConnection interface {
+	Connection
+	// Subprotocol 返回握手协商出的子协议，未协商时为空
+	Subprotocol() string
+	// Request 返回服务端升级时的HTTP请求信息，客户端连接返回nil
+	Request() *HTTPRequestInfo
+}
+
+// HTTPRequestInfo WebSocket升级请求的元数据
+type HTTPRequestInfo struct {
+	// RemoteIP 客户端IP
+	RemoteIP string
+	// Host 请求的Host
+	Host string
+	// Path 请求路径
+	Path string
+	// Header 请求头
+	Header http.Header
+	// Query 查询参数
+	Query url.Values
+}
+
+func newHTTPRequestInfo(r *http.Request, trustProxy bool) *HTTPRequestInfo {
+	return &HTTPRequestInfo{
+		RemoteIP: remoteIP(r, trustProxy),
+		Host:     r.Host,
+		Path:     r.URL.Path,
+		Header:   r.Header.Clone(),
+		Query:    r.URL.Query(),
+	}
+}
+
+// remoteIP 返回请求的客户端IP
+func remoteIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			first, _, _ := strings.Cut(xff, ",")
+			return strings.TrimSpace(first)
+		}
+		if ip := r.Header.Get("X-Real-IP"); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// HTTPRequestOf 返回WebSocket服务端连接的HTTP升级请求信息
+func HTTPRequestOf(conn Connection) (*HTTPRequestInfo, bool) {
+	wsc, ok := As[WebSocketConnection](conn)
+	if !ok || wsc.Request() == nil {
+		return nil, false
+	}
+	return wsc.Request(), true
+}
+
 // wsConn is a wrapper around a gorilla/websocket Conn that implements the net.Conn interface.
 type wsConn struct {
-	conn   *websocket.Conn
-	reader io.Reader
-	readMu sync.Mutex
+	conn    *websocket.Conn
+	reader  io.Reader
+	readMu  sync.Mutex
+	writeMu sync.Mutex
+	request *HTTPRequestInfo
+
+	// 心跳相关
+	lastPong  atomic.Int64
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+// newWSConn 包装WebSocket连接并应用读取限制和心跳配置
+func newWSConn(conn *websocket.Conn, config WebSocketConfig) *wsConn {
+	c := &wsConn{conn: conn, closeCh: make(chan struct{})}
+	if config.ReadLimit > 0 {
+		conn.SetReadLimit(config.ReadLimit)
+	}
+	if config.PingInterval > 0 {
+		c.lastPong.Store(time.Now().UnixNano())
+		conn.SetPongHandler(func(string) error {
+			c.lastPong.Store(time.Now().UnixNano())
+			return nil
+		})
+		go c.keepalive(config.PingInterval, config.pongTimeout())
+	}
+	return c
+}
+
+// keepalive 定时发送Ping，超过 timeout 未收到Pong时关闭连接
+// Pong在读取消息时处理，因此需要有协程持续读取连接
+func (c *wsConn) keepalive(interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.closeCh:
+			return
+		case <-ticker.C:
+			if time.Since(time.Unix(0, c.lastPong.Load())) > timeout {
+				log.Warnf("WebSocket peer %s did not answer ping within %v, closing", c.RemoteAddr(), timeout)
+				_ = c.Close()
+				return
+			}
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(interval)); err != nil {
+				_ = c.Close()
+				return
+			}
+		}
+	}
 }
 
 func (c *wsConn) Read(b []byte) (n int, err error) {
@@ -54,6 +167,10 @@ func (c *wsConn) Read(b []byte) (n int, err error) {
 }
 
 func (c *wsConn) Write(b []byte) (n int, err error) {
+	// gorilla/websocket 不支持并发写
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
 	err = c.conn.WriteMessage(websocket.BinaryMessage, b)
 	if err != nil {
 		return 0, err
@@ -62,9 +179,27 @@ func (c *wsConn) Write(b []byte) (n int, err error) {
 }
 
 func (c *wsConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closeCh)
+	})
 	return c.conn.Close()
 }
 
+// closeWithFrame 发送关闭帧后关闭连接
+func (c *wsConn) closeWithFrame(code int, text string) {
+	msg := websocket.FormatCloseMessage(code, text)
+	_ = c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	_ = c.Close()
+}
+
+func (c *wsConn) Subprotocol() string {
+	return c.conn.Subprotocol()
+}
+
+func (c *wsConn) Request() *HTTPRequestInfo {
+	return c.request
+}
+
 func (c *wsConn) LocalAddr() net.Addr {
 	return c.conn.LocalAddr()
 }
@@ -88,9 +223,30 @@ func (c *wsConn) SetWriteDeadline(t time.Time) error {
 	return c.conn.SetWriteDeadline(t)
 }
 
+// WebSocketListener 既是监听器也是 http.Handler，可挂载到已有的 http.ServeMux 上
+type WebSocketListener interface {
+	Listener
+	http.Handler
+}
+
+// wsAddr 挂载模式下监听器的地址，只包含路径
+type wsAddr struct {
+	path string
+}
+
+func (a wsAddr) Network() string {
+	return "websocket"
+}
+
+func (a wsAddr) String() string {
+	return a.path
+}
+
 // wsListener implements the net.Listener interface for WebSocket connections.
 type wsListener struct {
 	addr     net.Addr
+	config   WebSocketConfig
+	upgrader websocket.Upgrader
 	connChan chan Connection
 	server   *http.Server
 	once     sync.Once
@@ -98,6 +254,94 @@ type wsListener struct {
 	ln       net.Listener
 }
 
+func newWSListener(addr net.Addr, config WebSocketConfig) *wsListener {
+	return &wsListener{
+		addr:   addr,
+		config: config,
+		upgrader: websocket.Upgrader{
+			HandshakeTimeout:  config.handshakeTimeout(),
+			ReadBufferSize:    config.readBufferSize(),
+			WriteBufferSize:   config.writeBufferSize(),
+			Subprotocols:      config.Subprotocols,
+			EnableCompression: config.EnableCompression,
+			CheckOrigin:       config.checkOrigin(),
+		},
+		connChan: make(chan Connection, config.acceptBacklog()),
+		closeCh:  make(chan struct{}),
+	}
+}
+
+// ServeHTTP 将HTTP请求升级为WebSocket连接，并交给 Accept 返回
+func (l *wsListener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// 升级前先检查，避免为注定被拒绝的连接完成握手
+	select {
+	case <-l.closeCh:
+		http.Error(w, "listener closed", http.StatusServiceUnavailable)
+		return
+	default:
+	}
+	if l.config.BacklogPolicy != BacklogWait && len(l.connChan) >= cap(l.connChan) {
+		log.Warnf("WebSocket accept backlog full, rejecting %s", r.RemoteAddr)
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "accept backlog full", http.StatusServiceUnavailable)
+		return
+	}
+
+	conn, err := l.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// upgrader writes http error response
+		return
+	}
+	wsc := newWSConn(conn, l.config)
+	wsc.request = newHTTPRequestInfo(r, l.config.TrustProxyHeaders)
+
+	if l.config.BacklogPolicy != BacklogWait {
+		select {
+		case l.connChan <- wsc:
+			l.enqueued()
+		case <-l.closeCh:
+			wsc.closeWithFrame(websocket.CloseGoingAway, "listener closed")
+		default:
+			log.Warnf("WebSocket accept backlog full, rejecting %s", r.RemoteAddr)
+			wsc.closeWithFrame(websocket.CloseTryAgainLater, "accept backlog full")
+		}
+		return
+	}
+
+	timer := time.NewTimer(l.config.backlogTimeout())
+	defer timer.Stop()
+	select {
+	case l.connChan <- wsc:
+		l.enqueued()
+	case <-l.closeCh:
+		wsc.closeWithFrame(websocket.CloseGoingAway, "listener closed")
+	case <-timer.C:
+		log.Warnf("WebSocket connection from %s not accepted within %v, rejecting", r.RemoteAddr, l.config.backlogTimeout())
+		wsc.closeWithFrame(websocket.CloseTryAgainLater, "accept backlog full")
+	}
+}
+
+// drain 关闭尚未被 Accept 取走的连接
+func (l *wsListener) drain() {
+	for {
+		select {
+		case conn := <-l.connChan:
+			conn.(*wsConn).closeWithFrame(websocket.CloseGoingAway, "listener closed")
+		default:
+			return
+		}
+	}
+}
+
+// enqueued 连接入队后监听器若已关闭，清理队列防止连接泄漏
+func (l *wsListener) enqueued() {
+	select {
+	case <-l.closeCh:
+		l.drain()
+	default:
+	}
+}
+
 func (l *wsListener) Accept() (Connection, error) {
 	select {
 	case conn := <-l.connChan:
@@ -110,6 +354,7 @@ func (l *wsListener) Accept() (Connection, error) {
 func (l *wsListener) Close() error {
 	l.once.Do(func() {
 		close(l.closeCh)
+		l.drain()
 		if l.server != nil {
 			// 在协程中关闭服务器，单独处理错误
 			go func() {
@@ -135,52 +380,220 @@ func (l *wsListener) Addr() net.Addr {
 	return l.addr
 }
 
-type wsTransport struct{}
+// WebSocketConfig WebSocket传输配置
+type WebSocketConfig struct {
+	// Path URL路径，默认 "/"。监听时为 "/" 表示接受所有路径，否则只接受该路径
+	Path string
+	// TLSConfig 服务端设置后使用 wss 监听，客户端设置后使用 wss 拨号并以此校验服务端
+	TLSConfig *tls.Config
+	// CertFile 和 KeyFile 为PEM格式的服务端证书与私钥，设置后使用 wss 监听
+	CertFile string
+	KeyFile  string
+	// Secure 客户端使用 wss 拨号（未设置 TLSConfig 时使用系统CA校验）
+	Secure bool
+	// Header 拨号时附加的HTTP请求头，例如认证令牌
+	Header http.Header
+	// AllowedOrigins 允许的Origin列表，可以是完整Origin或主机名，"*" 表示全部允许
+	// 为空时只允许同源请求和未携带Origin的非浏览器客户端
+	AllowedOrigins []string
+	// CheckOrigin 自定义Origin校验，设置后忽略 AllowedOrigins
+	CheckOrigin func(r *http.Request) bool
+	// Subprotocols 支持的子协议：服务端按此顺序选择客户端也支持的第一个，客户端按此顺序提供
+	Subprotocols []string
+	// EnableCompression 启用 permessage-deflate 压缩协商
+	EnableCompression bool
+	// ReadLimit 单条消息的最大字节数，超过时连接被关闭，0 表示不限制
+	ReadLimit int64
+	// PingInterval 发送Ping的间隔，0 表示不发送
+	PingInterval time.Duration
+	// PongTimeout 未收到Pong时关闭连接的时间，默认为 PingInterval 的2倍
+	PongTimeout time.Duration
+	// HandshakeTimeout 握手超时，默认10秒
+	HandshakeTimeout time.Duration
+	// ReadBufferSize 和 WriteBufferSize 读写缓冲区大小，默认1024
+	ReadBufferSize  int
+	WriteBufferSize int
+	// AcceptBacklog 已升级但尚未被 Accept 取走的连接上限，默认128
+	AcceptBacklog int
+	// BacklogPolicy 积压队列已满时的处理策略，默认 BacklogReject
+	BacklogPolicy BacklogPolicy
+	// BacklogTimeout BacklogWait 策略下等待 Accept 的最长时间，默认5秒
+	BacklogTimeout time.Duration
+	// TrustProxyHeaders 使用 X-Forwarded-For / X-Real-IP 确定客户端IP，仅在可信代理之后开启
+	TrustProxyHeaders bool
+}
+
+// BacklogPolicy 积压队列已满时的处理策略
+type BacklogPolicy int
+
+const (
+	// BacklogReject 立即拒绝：升级前返回 503，升级后发送 1013 (Try Again Later) 关闭帧
+	BacklogReject BacklogPolicy = iota
+	// BacklogWait 等待 Accept 取走连接，超过 BacklogTimeout 后以 1013 关闭帧拒绝
+	BacklogWait
+)
+
+func (c WebSocketConfig) acceptBacklog() int {
+	if c.AcceptBacklog > 0 {
+		return c.AcceptBacklog
+	}
+	return 128
+}
+
+func (c WebSocketConfig) backlogTimeout() time.Duration {
+	if c.BacklogTimeout > 0 {
+		return c.BacklogTimeout
+	}
+	return 5 * time.Second
+}
+
+func (c WebSocketConfig) path() string {
+	if c.Path == "" {
+		return "/"
+	}
+	if !strings.HasPrefix(c.Path, "/") {
+		return "/" + c.Path
+	}
+	return c.Path
+}
+
+func (c WebSocketConfig) pongTimeout() time.Duration {
+	if c.PongTimeout > 0 {
+		return c.PongTimeout
+	}
+	return 2 * c.PingInterval
+}
+
+func (c WebSocketConfig) handshakeTimeout() time.Duration {
+	if c.HandshakeTimeout > 0 {
+		return c.HandshakeTimeout
+	}
+	return 10 * time.Second
+}
+
+func (c WebSocketConfig) readBufferSize() int {
+	if c.ReadBufferSize > 0 {
+		return c.ReadBufferSize
+	}
+	return 1024
+}
+
+func (c WebSocketConfig) writeBufferSize() int {
+	if c.WriteBufferSize > 0 {
+		return c.WriteBufferSize
+	}
+	return 1024
+}
+
+// checkOrigin 根据配置构建Origin校验函数，返回nil时使用gorilla默认的同源校验
+func (c WebSocketConfig) checkOrigin() func(r *http.Request) bool {
+	if c.CheckOrigin != nil {
+		return c.CheckOrigin
+	}
+	if len(c.AllowedOrigins) == 0 {
+		return nil
+	}
+	allowed := c.AllowedOrigins
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		for _, a := range allowed {
+			if a == "*" || strings.EqualFold(a, origin) || strings.EqualFold(a, u.Host) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func allowAllOrigins(*http.Request) bool {
+	return true
+}
+
+type wsTransport struct {
+	config      WebSocketConfig
+	certificate *tls.Certificate
+}
 
 func (t *wsTransport) Protocol() string {
 	return "websocket"
 }
 
 // NewWebSocketTransport creates new transport that uses WebSockets.
+// 接受所有路径和Origin，拨号使用 ws://。
 func NewWebSocketTransport() Transport {
-	return &wsTransport{}
+	return &wsTransport{config: WebSocketConfig{CheckOrigin: allowAllOrigins}}
 }
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins
-	},
+// NewWebSocketTransportWithConfig creates a new WebSocket transport with the given settings.
+func NewWebSocketTransportWithConfig(config WebSocketConfig) (Transport, error) {
+	t := &wsTransport{config: config}
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate: %w", err)
+		}
+		t.certificate = &cert
+	}
+	return t, nil
+}
+
+// NewWebSocketHandler creates a WebSocket listener that is served by an existing HTTP server.
+// 返回值需要挂载到 http.ServeMux 上，例如 mux.Handle("/ws", l)，然后通过 Accept 获取连接。
+func NewWebSocketHandler(config WebSocketConfig) WebSocketListener {
+	return newWSListener(wsAddr{path: config.path()}, config)
+}
+
+// secure 判断是否使用 wss
+func (t *wsTransport) secure() bool {
+	return t.config.Secure || t.config.TLSConfig != nil || t.certificate != nil
 }
 
 func (t *wsTransport) Listen(address string) (Listener, error) {
+	var tlsConf *tls.Config
+	if t.config.TLSConfig != nil || t.certificate != nil {
+		tlsConf = cloneTLSConfig(t.config.TLSConfig)
+		if t.certificate != nil {
+			tlsConf.Certificates = append(tlsConf.Certificates, *t.certificate)
+		}
+		if !hasServerCertificate(tlsConf) {
+			return nil, ErrNoCertificate
+		}
+	}
+
 	tcpListener, err := net.Listen("tcp", address)
 	if err != nil {
 		return nil, err
 	}
 
-	listener := &wsListener{
-		addr:     tcpListener.Addr(),
-		connChan: make(chan Connection, 128),
-		closeCh:  make(chan struct{}),
-		ln:       tcpListener,
-	}
+	listener := newWSListener(tcpListener.Addr(), t.config)
+	listener.ln = tcpListener
 
+	var handler http.Handler = listener
+	if path := t.config.path(); path != "/" {
+		mux := http.NewServeMux()
+		mux.Handle(path, listener)
+		handler = mux
+	}
 	server := &http.Server{
-		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			conn, err := upgrader.Upgrade(w, r, nil)
-			if err != nil {
-				// upgrader writes http error response
-				return
-			}
-			listener.connChan <- &wsConn{conn: conn}
-		}),
+		Handler:           handler,
+		ReadHeaderTimeout: t.config.handshakeTimeout(),
 	}
 	listener.server = server
 
+	var ln net.Listener = tcpListener
+	if tlsConf != nil {
+		ln = tls.NewListener(tcpListener, tlsConf)
+	}
+
 	go func() {
-		if err := server.Serve(tcpListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			return
 		}
 	}()
@@ -189,10 +602,35 @@ func (t *wsTransport) Listen(address string) (Listener, error) {
 }
 
 func (t *wsTransport) Dial(address string) (Connection, error) {
-	dialer := websocket.Dialer{}
-	conn, _, err := dialer.Dial("ws://"+address, nil)
+	dialer := websocket.Dialer{
+		HandshakeTimeout:  t.config.handshakeTimeout(),
+		ReadBufferSize:    t.config.readBufferSize(),
+		WriteBufferSize:   t.config.writeBufferSize(),
+		Subprotocols:      t.config.Subprotocols,
+		EnableCompression: t.config.EnableCompression,
+	}
+	if t.config.TLSConfig != nil {
+		dialer.TLSClientConfig = cloneTLSConfig(t.config.TLSConfig)
+	}
+
+	conn, resp, err := dialer.Dial(t.dialURL(address), t.config.Header)
 	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("websocket handshake failed with status %s: %w", resp.Status, err)
+		}
 		return nil, err
 	}
-	return &wsConn{conn: conn}, nil
+	return newWSConn(conn, t.config), nil
+}
+
+// dialURL 将拨号地址转换为URL，已经是 ws:// 或 wss:// 形式的地址原样使用
+func (t *wsTransport) dialURL(address string) string {
+	if strings.Contains(address, "://") {
+		return address
+	}
+	scheme := "ws"
+	if t.secure() {
+		scheme = "wss"
+	}
+	return (&url.URL{Scheme: scheme, Host: address, Path: t.config.path()}).String()
 }
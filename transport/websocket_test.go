@@ -1,11 +1,18 @@
 package transport
 
 import (
+	"crypto/tls"
+	"errors"
+	"io"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 func TestWebSocketTransport_Protocol(t *testing.T) {
@@ -203,3 +210,501 @@ func TestWebSocketTransport_ConnectionInterface(t *testing.T) {
 
 	t.Log("WebSocket connection interface test passed")
 }
+
+// newTestWSTransport 使用给定配置创建WebSocket传输
+func newTestWSTransport(t *testing.T, config WebSocketConfig) Transport {
+	t.Helper()
+	tr, err := NewWebSocketTransportWithConfig(config)
+	if err != nil {
+		t.Fatalf("Failed to create WebSocket transport: %v", err)
+	}
+	return tr
+}
+
+// acceptWS 在协程中接受一个连接
+func acceptWS(listener Listener) <-chan Connection {
+	ch := make(chan Connection, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(ch)
+			return
+		}
+		ch <- conn
+	}()
+	return ch
+}
+
+func TestWebSocketTransport_Path(t *testing.T) {
+	tr := newTestWSTransport(t, WebSocketConfig{Path: "/msg"})
+
+	result := RunBasicConnectionTest(TestConfig{
+		ProtocolName:      "WebSocket",
+		Transport:         tr,
+		ConnectionTimeout: 5 * time.Second,
+	})
+	if !result.Success {
+		t.Fatalf("Basic connection test failed: %s - %v", result.Message, result.Error)
+	}
+
+	listener, err := tr.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "websocket-listener")
+
+	other := newTestWSTransport(t, WebSocketConfig{Path: "/other"})
+	if _, err := other.Dial(listener.Addr().String()); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("Expected 404 handshake error for wrong path, got %v", err)
+	}
+
+	// 完整URL原样使用
+	conn, err := other.Dial("ws://" + listener.Addr().String() + "/msg")
+	if err != nil {
+		t.Fatalf("Failed to dial full URL: %v", err)
+	}
+	SafeClose(conn, "websocket-client-connection")
+}
+
+func TestWebSocketTransport_WSS(t *testing.T) {
+	pki := newTestPKI(t)
+	certFile, keyFile := pki.issue(t, "server")
+	tr := newTestWSTransport(t, WebSocketConfig{
+		CertFile:  certFile,
+		KeyFile:   keyFile,
+		TLSConfig: &tls.Config{RootCAs: pki.certPool(t)},
+	})
+
+	config := TestConfig{
+		ProtocolName:      "WebSocket",
+		Transport:         tr,
+		ConnectionTimeout: 5 * time.Second,
+	}
+	result := RunBasicConnectionTest(config)
+	if !result.Success {
+		t.Fatalf("Basic connection test failed: %s - %v", result.Message, result.Error)
+	}
+	result = RunLargeDataTest(config)
+	if !result.Success {
+		t.Fatalf("Large data test failed: %s - %v", result.Message, result.Error)
+	}
+
+	// 明文客户端无法连接 wss 服务端
+	listener, err := tr.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "websocket-listener")
+	if _, err := NewWebSocketTransport().Dial(listener.Addr().String()); err == nil {
+		t.Fatal("Expected plain ws dial to wss server to fail")
+	}
+}
+
+func TestWebSocketTransport_MissingCertificate(t *testing.T) {
+	tr := newTestWSTransport(t, WebSocketConfig{TLSConfig: &tls.Config{}})
+	if _, err := tr.Listen("127.0.0.1:0"); !errors.Is(err, ErrNoCertificate) {
+		t.Fatalf("Expected ErrNoCertificate, got %v", err)
+	}
+}
+
+func TestWebSocketHandler_MountWithHeaders(t *testing.T) {
+	handler := NewWebSocketHandler(WebSocketConfig{})
+	defer SafeClose(handler, "websocket-handler")
+
+	// 挂载到已有的ServeMux，由外层处理器校验认证头
+	mux := http.NewServeMux()
+	mux.Handle("/ws", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	if handler.Addr().Network() != "websocket" || handler.Addr().String() != "/" {
+		t.Errorf("Unexpected handler address %s/%s", handler.Addr().Network(), handler.Addr())
+	}
+
+	address := strings.TrimPrefix(server.URL, "http://")
+	anonymous := newTestWSTransport(t, WebSocketConfig{Path: "/ws"})
+	if _, err := anonymous.Dial(address); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("Expected 401 handshake error, got %v", err)
+	}
+
+	client := newTestWSTransport(t, WebSocketConfig{
+		Path:   "/ws",
+		Header: http.Header{"Authorization": []string{"Bearer token"}},
+	})
+	accepted := acceptWS(handler)
+	conn, err := client.Dial(address)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(conn, "websocket-client-connection")
+
+	serverConn := <-accepted
+	if serverConn == nil {
+		t.Fatal("Failed to accept connection")
+	}
+	defer SafeClose(serverConn, "websocket-server-connection")
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	buf := make([]byte, 4)
+	_ = serverConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.ReadFull(serverConn, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("Unexpected read %q: %v", buf, err)
+	}
+}
+
+func TestWebSocketTransport_AllowedOrigins(t *testing.T) {
+	server := newTestWSTransport(t, WebSocketConfig{AllowedOrigins: []string{"https://app.example.com"}})
+	listener, err := server.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "websocket-listener")
+	address := listener.Addr().String()
+
+	dialWithOrigin := func(origin string) error {
+		client := newTestWSTransport(t, WebSocketConfig{Header: http.Header{"Origin": []string{origin}}})
+		conn, err := client.Dial(address)
+		if err == nil {
+			SafeClose(conn, "websocket-client-connection")
+		}
+		return err
+	}
+
+	if err := dialWithOrigin("https://app.example.com"); err != nil {
+		t.Errorf("Expected allowed origin to connect, got %v", err)
+	}
+	if err := dialWithOrigin("https://evil.example.com"); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Expected 403 for disallowed origin, got %v", err)
+	}
+
+	// 未配置允许列表时只接受同源请求
+	strict := newTestWSTransport(t, WebSocketConfig{})
+	strictListener, err := strict.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(strictListener, "websocket-listener")
+	address = strictListener.Addr().String()
+	if err := dialWithOrigin("https://evil.example.com"); err == nil {
+		t.Error("Expected cross-origin request to be rejected by default")
+	}
+	if err := dialWithOrigin("http://" + address); err != nil {
+		t.Errorf("Expected same-origin request to connect, got %v", err)
+	}
+}
+
+func TestWebSocketTransport_Subprotocols(t *testing.T) {
+	server := newTestWSTransport(t, WebSocketConfig{Subprotocols: []string{"chilix.v2", "chilix.v1"}})
+	listener, err := server.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "websocket-listener")
+
+	client := newTestWSTransport(t, WebSocketConfig{Subprotocols: []string{"chilix.v1", "chilix.v2"}})
+	accepted := acceptWS(listener)
+	conn, err := client.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(conn, "websocket-client-connection")
+	serverConn := <-accepted
+	if serverConn == nil {
+		t.Fatal("Failed to accept connection")
+	}
+	defer SafeClose(serverConn, "websocket-server-connection")
+
+	// 服务端按自己的优先级选择
+	for name, c := range map[string]Connection{"client": conn, "server": serverConn} {
+		wsc, ok := c.(WebSocketConnection)
+		if !ok {
+			t.Fatalf("%s connection does not implement WebSocketConnection", name)
+		}
+		if wsc.Subprotocol() != "chilix.v2" {
+			t.Errorf("Expected %s subprotocol chilix.v2, got %q", name, wsc.Subprotocol())
+		}
+	}
+}
+
+func TestWebSocketTransport_Compression(t *testing.T) {
+	config := TestConfig{
+		ProtocolName:      "WebSocket",
+		Transport:         newTestWSTransport(t, WebSocketConfig{EnableCompression: true}),
+		ConnectionTimeout: 5 * time.Second,
+	}
+	result := RunLargeDataTest(config)
+	if !result.Success {
+		t.Fatalf("Large data test failed: %s - %v", result.Message, result.Error)
+	}
+}
+
+func TestWebSocketTransport_ReadLimit(t *testing.T) {
+	server := newTestWSTransport(t, WebSocketConfig{ReadLimit: 1024})
+	listener, err := server.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "websocket-listener")
+
+	accepted := acceptWS(listener)
+	conn, err := NewWebSocketTransport().Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(conn, "websocket-client-connection")
+	serverConn := <-accepted
+	if serverConn == nil {
+		t.Fatal("Failed to accept connection")
+	}
+	defer SafeClose(serverConn, "websocket-server-connection")
+
+	if _, err := conn.Write(make([]byte, 4096)); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	_ = serverConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.ReadAll(serverConn); !errors.Is(err, websocket.ErrReadLimit) {
+		t.Fatalf("Expected ErrReadLimit, got %v", err)
+	}
+}
+
+func TestWebSocketTransport_PingPong(t *testing.T) {
+	server := newTestWSTransport(t, WebSocketConfig{
+		PingInterval: 50 * time.Millisecond,
+		PongTimeout:  200 * time.Millisecond,
+	})
+	listener, err := server.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "websocket-listener")
+
+	t.Run("Alive", func(t *testing.T) {
+		accepted := acceptWS(listener)
+		conn, err := NewWebSocketTransport().Dial(listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer SafeClose(conn, "websocket-client-connection")
+		serverConn := <-accepted
+		defer SafeClose(serverConn, "websocket-server-connection")
+
+		// 客户端持续读取，自动回复Pong
+		received := make(chan string, 1)
+		go func() {
+			buf := make([]byte, 2)
+			if _, err := io.ReadFull(conn, buf); err == nil {
+				received <- string(buf)
+			}
+		}()
+		// 服务端也需要读取才能处理Pong
+		go func() { _, _ = io.Copy(io.Discard, serverConn) }()
+
+		time.Sleep(500 * time.Millisecond)
+		if _, err := serverConn.Write([]byte("hi")); err != nil {
+			t.Fatalf("Connection closed despite pong replies: %v", err)
+		}
+		select {
+		case msg := <-received:
+			if msg != "hi" {
+				t.Errorf("Unexpected message %q", msg)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for message")
+		}
+	})
+
+	t.Run("Dead", func(t *testing.T) {
+		accepted := acceptWS(listener)
+		// 客户端不读取，不会回复Pong
+		conn, err := NewWebSocketTransport().Dial(listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer SafeClose(conn, "websocket-client-connection")
+		serverConn := <-accepted
+		defer SafeClose(serverConn, "websocket-server-connection")
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := serverConn.Read(make([]byte, 1))
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Fatal("Expected read to fail after pong timeout")
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Connection was not closed after pong timeout")
+		}
+	})
+}
+
+// expectCloseCode 读取连接直到收到关闭帧，并校验关闭码
+func expectCloseCode(t *testing.T, conn Connection, code int) {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, err := conn.Read(make([]byte, 1))
+	if !websocket.IsCloseError(err, code) {
+		t.Fatalf("Expected close frame %d, got %v", code, err)
+	}
+}
+
+func TestWebSocketListener_BacklogReject(t *testing.T) {
+	tr := newTestWSTransport(t, WebSocketConfig{AcceptBacklog: 1})
+	listener, err := tr.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "websocket-listener")
+
+	// 不调用Accept，第一个连接占满积压队列
+	first, err := tr.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(first, "websocket-client-connection")
+
+	if _, err := tr.Dial(listener.Addr().String()); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("Expected 503 when backlog is full, got %v", err)
+	}
+
+	// 取走后可以再次连接
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	defer SafeClose(conn, "websocket-server-connection")
+	second, err := tr.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Expected dial to succeed after accept, got %v", err)
+	}
+	SafeClose(second, "websocket-client-connection")
+}
+
+func TestWebSocketListener_BacklogWaitTimeout(t *testing.T) {
+	tr := newTestWSTransport(t, WebSocketConfig{
+		AcceptBacklog:  1,
+		BacklogPolicy:  BacklogWait,
+		BacklogTimeout: 100 * time.Millisecond,
+	})
+	listener, err := tr.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "websocket-listener")
+
+	first, err := tr.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(first, "websocket-client-connection")
+
+	// 第二个连接完成握手，但等待超时后以1013关闭
+	second, err := tr.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(second, "websocket-client-connection")
+	expectCloseCode(t, second, websocket.CloseTryAgainLater)
+}
+
+func TestWebSocketListener_CloseRejectsPending(t *testing.T) {
+	tr := newTestWSTransport(t, WebSocketConfig{
+		AcceptBacklog:  1,
+		BacklogPolicy:  BacklogWait,
+		BacklogTimeout: time.Minute,
+	})
+	listener, err := tr.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	queued, err := tr.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(queued, "websocket-client-connection")
+	waiting, err := tr.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(waiting, "websocket-client-connection")
+
+	// 关闭监听器后，排队和等待中的连接都收到1001关闭帧，处理协程不会阻塞
+	SafeClose(listener, "websocket-listener")
+	expectCloseCode(t, queued, websocket.CloseGoingAway)
+	expectCloseCode(t, waiting, websocket.CloseGoingAway)
+}
+
+func TestWebSocketConnection_HTTPRequest(t *testing.T) {
+	tr := newTestWSTransport(t, WebSocketConfig{})
+	listener, err := tr.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer SafeClose(listener, "websocket-listener")
+
+	client := newTestWSTransport(t, WebSocketConfig{
+		Header: http.Header{"X-Client-Id": []string{"c-1"}, "X-Forwarded-For": []string{"203.0.113.7"}},
+	})
+	accepted := acceptWS(listener)
+	conn, err := client.Dial("ws://" + listener.Addr().String() + "/chat?token=abc")
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(conn, "websocket-client-connection")
+	serverConn := <-accepted
+	if serverConn == nil {
+		t.Fatal("Failed to accept connection")
+	}
+	defer SafeClose(serverConn, "websocket-server-connection")
+
+	info, ok := HTTPRequestOf(serverConn)
+	if !ok {
+		t.Fatal("Expected HTTP request info on server connection")
+	}
+	// 未开启 TrustProxyHeaders 时忽略代理头
+	if info.RemoteIP != "127.0.0.1" {
+		t.Errorf("Expected remote IP 127.0.0.1, got %s", info.RemoteIP)
+	}
+	if info.Path != "/chat" || info.Query.Get("token") != "abc" || info.Header.Get("X-Client-Id") != "c-1" {
+		t.Errorf("Unexpected request info: %+v", info)
+	}
+
+	if _, ok := HTTPRequestOf(conn); ok {
+		t.Error("Expected no HTTP request info on client connection")
+	}
+}
+
+func TestWebSocketConnection_TrustProxyHeaders(t *testing.T) {
+	handler := NewWebSocketHandler(WebSocketConfig{TrustProxyHeaders: true})
+	defer SafeClose(handler, "websocket-handler")
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client := newTestWSTransport(t, WebSocketConfig{
+		Header: http.Header{"X-Forwarded-For": []string{"203.0.113.7, 10.0.0.1"}},
+	})
+	accepted := acceptWS(handler)
+	conn, err := client.Dial(strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer SafeClose(conn, "websocket-client-connection")
+	serverConn := <-accepted
+	defer SafeClose(serverConn, "websocket-server-connection")
+
+	info, _ := HTTPRequestOf(serverConn)
+	if info == nil || info.RemoteIP != "203.0.113.7" {
+		t.Fatalf("Expected remote IP from X-Forwarded-For, got %+v", info)
+	}
+}